@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/database"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/secret"
+)
+
+// 使用当前主密钥（encryption.active_key）重新加密数据源密码、智能体密钥及DIFY密钥字典，
+// 用于首次启用加密或更换主密钥后处理历史数据
+func main() {
+	configFile := flag.String("config", "config.yaml", "配置文件路径")
+	flag.Parse()
+
+	// 初始化配置
+	if err := config.Init(*configFile); err != nil {
+		log.Fatalf("初始化配置失败: %v", err)
+	}
+
+	// 初始化日志
+	logger.Init()
+
+	// 连接数据库
+	if err := database.Init(); err != nil {
+		log.Fatalf("连接数据库失败: %v", err)
+	}
+	defer database.Close()
+
+	// 确保字段长度足够存放密文
+	model.AutoMigrate(database.GetDB())
+
+	count, err := model.ReencryptSecrets(database.GetDB())
+	if err != nil {
+		log.Fatalf("重新加密失败: %v", err)
+	}
+	log.Printf("重新加密完成，主密钥: %s，处理记录数: %d", secret.ActiveKeyID(), count)
+}
@@ -100,7 +100,7 @@ func (h *ChatHandler) SendMessage(c *fiber.Ctx) error {
 	}
 	apiSecret := ""
 	if appAgent != nil {
-		apiSecret = appAgent.Agent.ApiSecret
+		apiSecret = string(appAgent.Agent.ApiSecret)
 	}
 
 	c.Set("Content-Type", "text/event-stream")
@@ -195,7 +195,7 @@ func (h *ChatHandler) GetSessionList(c *fiber.Ctx) error {
 	}
 	apiSecret := ""
 	if appAgent != nil {
-		apiSecret = appAgent.Agent.ApiSecret
+		apiSecret = string(appAgent.Agent.ApiSecret)
 	}
 
 	list, err := chatClient.GetConversations(customID, apiSecret)
@@ -227,7 +227,7 @@ func (h *ChatHandler) GetSessionHistory(c *fiber.Ctx) error {
 	}
 	apiSecret := ""
 	if appAgent != nil {
-		apiSecret = appAgent.Agent.ApiSecret
+		apiSecret = string(appAgent.Agent.ApiSecret)
 	}
 
 	conversationID := c.Query("conversation_id")
@@ -281,7 +281,7 @@ func (h *ChatHandler) StopChatFlow(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrInternalError))
 	}
 
-	err = chatClient.StopStreamingChat(req.TaskID, req.CustomID, appAgent.Agent.ApiSecret)
+	err = chatClient.StopStreamingChat(req.TaskID, req.CustomID, string(appAgent.Agent.ApiSecret))
 	if err != nil {
 		logger.Error("停止会话失败", logger.F("err", err))
 		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrInternalError))
@@ -402,7 +402,7 @@ func (h *ChatHandler) UploadFile(c *fiber.Ctx) error {
 		}
 		apiSecret := ""
 		if appAgent != nil {
-			apiSecret = appAgent.Agent.ApiSecret
+			apiSecret = string(appAgent.Agent.ApiSecret)
 		}
 
 		files := form.File["files"]
@@ -497,7 +497,7 @@ func (h *ChatHandler) DeleteConversation(c *fiber.Ctx) error {
 	}
 	apiSecret := ""
 	if appAgent != nil {
-		apiSecret = appAgent.Agent.ApiSecret
+		apiSecret = string(appAgent.Agent.ApiSecret)
 	}
 
 	_, err = chatClient.DeleteConversation(req.ConversationID, req.CustomID, apiSecret)
@@ -0,0 +1,111 @@
+package appapi
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/internal/service"
+	"github.com/yockii/dify_tools/pkg/logger"
+)
+
+type DataSourceHandler struct {
+	dataSourceService service.DataSourceService
+	textToSqlService  service.TextToSqlService
+}
+
+func RegisterDataSourceHandler(
+	dataSourceService service.DataSourceService,
+	textToSqlService service.TextToSqlService,
+) {
+	handler := &DataSourceHandler{
+		dataSourceService: dataSourceService,
+		textToSqlService:  textToSqlService,
+	}
+	Handlers = append(Handlers, handler)
+}
+
+func (h *DataSourceHandler) RegisterRoutes(router fiber.Router) {
+	router.Post("/data_source/import", h.ImportDataSource)
+	router.Post("/data_source/text_to_sql", h.TextToSql)
+}
+
+// ImportDataSource 上传CSV/XLSX文件导入为当前应用的数据源，传入id时替换该导入数据源的数据
+func (h *DataSourceHandler) ImportDataSource(c *fiber.Ctx) error {
+	application, ok := c.Locals("application").(*model.Application)
+	// 未携带应用密钥的请求为本系统（ID为0），不能访问应用数据源
+	if !ok || application.ID == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(service.Error(constant.ErrUnauthorized))
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	files := form.File["files"]
+	if len(files) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	dataSource := &model.DataSource{
+		ApplicationID: application.ID,
+		Name:          c.FormValue("name"),
+	}
+	if id := c.FormValue("id"); id != "" {
+		if dataSource.ID, err = strconv.ParseUint(id, 10, 64); err != nil {
+			logger.Error("请求参数解析失败", logger.F("err", err))
+			return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+		}
+	}
+
+	if err := h.dataSourceService.Import(c.Context(), dataSource, files[0]); err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	return c.JSON(service.OK(dataSource))
+}
+
+// TextToSql 根据自然语言问题生成SQL并在当前应用的数据源上执行，返回SQL及结果
+func (h *DataSourceHandler) TextToSql(c *fiber.Ctx) error {
+	application, ok := c.Locals("application").(*model.Application)
+	if !ok || application.ID == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(service.Error(constant.ErrUnauthorized))
+	}
+
+	type Req struct {
+		DataSourceID uint64            `json:"dataSourceId,string"`
+		Question     string            `json:"question"`
+		CustomID     string            `json:"customId"`
+		Variables    map[string]string `json:"variables"`
+	}
+	req := new(Req)
+	if err := c.BodyParser(req); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	if req.Question == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	dataSource, err := h.dataSourceService.Get(c.Context(), req.DataSourceID)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+	if dataSource.ApplicationID != application.ID {
+		return c.Status(fiber.StatusForbidden).JSON(service.Error(constant.ErrForbidden))
+	}
+
+	variables := service.RowFilterVariables(req.Variables, req.CustomID)
+
+	result, err := h.textToSqlService.Ask(c.Context(), dataSource, &service.TextToSqlRequest{
+		Question:  req.Question,
+		Variables: variables,
+	})
+	if err != nil {
+		// 失败时仍返回最后一次生成的SQL及拒绝原因
+		return c.Status(constant.GetErrorCode(err)).JSON(service.NewResponse(result, err))
+	}
+
+	return c.JSON(service.OK(result))
+}
@@ -0,0 +1,92 @@
+package appapi
+
+import (
+	"errors"
+	"mime"
+	"net/url"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/service"
+	"github.com/yockii/dify_tools/pkg/filestore"
+	"github.com/yockii/dify_tools/pkg/logger"
+)
+
+// 系统mime表中可能没有的类型
+var fileContentTypes = map[string]string{
+	".csv":  "text/csv; charset=utf-8",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// 直接在页面中显示而非下载的文件，如图表图片
+var inlineExts = map[string]bool{
+	".png": true,
+	".svg": true,
+}
+
+type FileHandler struct{}
+
+func RegisterFileHandler() {
+	handler := &FileHandler{}
+	Handlers = append(Handlers, handler)
+}
+
+func (h *FileHandler) RegisterRoutes(router fiber.Router) {
+	router.Get("/files/:id/:name", h.Download)
+}
+
+// Download 下载文件存储中的文件（如导出的查询结果、图表），凭链接中的过期时间及签名访问，不需要应用密钥
+func (h *FileHandler) Download(c *fiber.Ctx) error {
+	id := c.Params("id")
+	name, err := url.PathUnescape(c.Params("name"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(service.Error(constant.ErrDownloadLinkInvalid))
+	}
+	if err := filestore.Verify(id, name, expires, c.Query("sign")); err != nil {
+		if errors.Is(err, filestore.ErrLinkExpired) {
+			return c.Status(fiber.StatusGone).JSON(service.Error(constant.ErrDownloadLinkExpired))
+		}
+		return c.Status(fiber.StatusForbidden).JSON(service.Error(constant.ErrDownloadLinkInvalid))
+	}
+
+	f, err := filestore.Open(id, name)
+	if err != nil {
+		if errors.Is(err, filestore.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(service.Error(constant.ErrFileNotFound))
+		}
+		logger.Error("打开文件失败", logger.F("id", id), logger.F("error", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		logger.Error("读取文件信息失败", logger.F("id", id), logger.F("error", err))
+		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrInternalError))
+	}
+
+	contentType, ok := fileContentTypes[filepath.Ext(name)]
+	if !ok {
+		if contentType = mime.TypeByExtension(filepath.Ext(name)); contentType == "" {
+			contentType = fiber.MIMEOctetStream
+		}
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	dispositionType := "attachment"
+	if inlineExts[filepath.Ext(name)] {
+		dispositionType = "inline"
+	}
+	// 中文文件名按 RFC 2231 编码
+	disposition := mime.FormatMediaType(dispositionType, map[string]string{"filename": name})
+	if disposition == "" {
+		disposition = dispositionType
+	}
+	c.Set(fiber.HeaderContentDisposition, disposition)
+	// 响应结束后由fasthttp关闭文件
+	return c.SendStream(f, int(info.Size()))
+}
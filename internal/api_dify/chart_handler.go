@@ -0,0 +1,173 @@
+package difyapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/middleware"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/internal/service"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/sqlguard"
+)
+
+type ChartHandler struct {
+	applicationService service.ApplicationService
+	dataSourceService  service.DataSourceService
+	chartService       service.ChartService
+}
+
+func RegisterChartHandler(
+	applicationService service.ApplicationService,
+	dataSourceService service.DataSourceService,
+	chartService service.ChartService,
+) {
+	handler := &ChartHandler{
+		applicationService: applicationService,
+		dataSourceService:  dataSourceService,
+		chartService:       chartService,
+	}
+	Handlers = append(Handlers, handler)
+}
+
+func (h *ChartHandler) RegisterRoutesV1_1(router fiber.Router) {
+	h.RegisterRoutesV1(router)
+}
+
+func (h *ChartHandler) RegisterRoutesV1(router fiber.Router) {
+	router.Post("/chart", middleware.NewAppMiddleware(h.applicationService), h.RenderChart)
+}
+
+// RenderChart 将结果集或SQL的执行结果绘制为柱状图、折线图或饼图，返回图片地址或mermaid代码，
+// 返回的 markdown 可直接放在回答中
+func (h *ChartHandler) RenderChart(c *fiber.Ctx) error {
+	application, _ := c.Locals("application").(*model.Application)
+	if application == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidCredential))
+	}
+
+	type Req struct {
+		DataSourceID uint64            `json:"datasourceId,string"`
+		Sql          string            `json:"sql"`
+		CustomID     string            `json:"customId"`
+		Variables    map[string]string `json:"variables"`
+		Question     string            `json:"question"`
+		Rows         json.RawMessage   `json:"rows"` // JSON数组，或内容为JSON数组的字符串
+		Type         string            `json:"type"`
+		Title        string            `json:"title"`
+		X            string            `json:"x"`
+		Y            string            `json:"y"` // 多个字段以逗号分隔
+		Format       string            `json:"format"`
+	}
+	req := new(Req)
+	if err := c.BodyParser(req); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	rows, err := parseChartRows(req.Rows)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.NewResponse(
+			&service.ChartSpecError{Field: "rows", Reason: "应为对象组成的JSON数组"}, constant.ErrChartSpecInvalid))
+	}
+	if len(rows) == 0 && req.Sql == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	chartReq := &service.ChartRequest{
+		Rows:    rows,
+		Type:    req.Type,
+		Title:   req.Title,
+		X:       req.X,
+		Format:  req.Format,
+		BaseURL: publicBaseURL(c),
+	}
+	if req.Y != "" {
+		chartReq.Y = strings.Split(req.Y, ",")
+	}
+
+	// 直接传入结果集时不需要数据源
+	var dataSource *model.DataSource
+	if len(rows) == 0 {
+		// 检查datasource是否该应用
+		dataSource, err = h.dataSourceService.Get(c.Context(), req.DataSourceID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrDatabaseError))
+		}
+		if dataSource.ApplicationID != application.ID {
+			return c.Status(fiber.StatusForbidden).JSON(service.Error(constant.ErrForbidden))
+		}
+
+		variables := service.RowFilterVariables(req.Variables, req.CustomID)
+		chartReq.Query = &service.QueryRequest{
+			Sql:       req.Sql,
+			Variables: variables,
+			Question:  req.Question,
+		}
+	}
+
+	result, err := h.chartService.Render(c.Context(), dataSource, chartReq)
+	if err != nil {
+		var specErr *service.ChartSpecError
+		if errors.As(err, &specErr) {
+			return c.Status(fiber.StatusBadRequest).JSON(service.NewResponse(specErr, constant.ErrChartSpecInvalid))
+		}
+		var violation *sqlguard.Violation
+		if errors.As(err, &violation) {
+			return c.Status(fiber.StatusBadRequest).JSON(service.NewResponse(violation, constant.ErrSqlNotAllowed))
+		}
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	return c.JSON(service.OK(result))
+}
+
+// parseChartRows 解析结果集，智能体常将JSON作为字符串传入，两种形式都支持；数字保留原始精度
+func parseChartRows(raw json.RawMessage) ([]map[string]interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		if raw = bytes.TrimSpace([]byte(s)); len(raw) == 0 {
+			return nil, nil
+		}
+	}
+	var rows []map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (h *ChartHandler) OpenAPIPaths(ctx context.Context, application *model.Application) map[string]*OpenAPIPathItem {
+	return map[string]*OpenAPIPathItem{
+		"/chart": {Post: &OpenAPIOperation{
+			Description: "将查询结果绘制为柱状图、折线图或饼图。传入rows（已有结果）或sql及datasourceId，返回的markdown（图片链接或mermaid代码块）可直接放在回答中",
+			OperationID: "renderChart",
+			Parameters:  []*OpenAPIParameter{openAPIAuthorization()},
+			RequestBody: openAPIJSONBody(map[string]*OpenAPISchema{
+				"type":         {Type: "string", Description: "图表类型", Enum: []string{"bar", "line", "pie"}},
+				"x":            {Type: "string", Description: "分类字段名（x轴或饼图扇区名称）"},
+				"y":            {Type: "string", Description: "数值字段名，多个以逗号分隔，为空时使用所有数值字段，饼图只使用第一个"},
+				"title":        {Type: "string", Description: "图表标题"},
+				"format":       {Type: "string", Description: "输出格式，默认svg", Enum: []string{"svg", "png", "mermaid"}},
+				"rows":         {Type: "string", Description: "结果集，对象组成的JSON数组，如 executeSql 返回的rows"},
+				"sql":          {Type: "string", Description: "未传rows时执行的SQL语句"},
+				"datasourceId": {Type: "string", Description: "数据源ID，传sql时必填"},
+				"customId":     {Type: "string", Description: "终端用户ID，用于行级权限过滤"},
+				"question":     {Type: "string", Description: "图表所回答的用户问题"},
+			}, "type", "x"),
+		}},
+	}
+}
@@ -1,33 +1,49 @@
 package difyapi
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/yockii/dify_tools/internal/constant"
-	"github.com/yockii/dify_tools/internal/datasource"
 	"github.com/yockii/dify_tools/internal/middleware"
 	"github.com/yockii/dify_tools/internal/model"
 	"github.com/yockii/dify_tools/internal/service"
+	"github.com/yockii/dify_tools/pkg/config"
 	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/sqlguard"
 )
 
 type DatabaseHandler struct {
 	applicationService service.ApplicationService
 	dataSourceService  service.DataSourceService
-	tableInfoService   service.TableInfoService
-	columnInfoService  service.ColumnInfoService
+	schemaService      service.SchemaService
+	queryService       service.QueryService
+	savedQueryService  service.SavedQueryService
+	textToSqlService   service.TextToSqlService
+	sqlExampleService  service.SqlExampleService
 }
 
 func RegisterDatabaseHandler(
 	applicationService service.ApplicationService,
 	dataSourceService service.DataSourceService,
-	tableInfoService service.TableInfoService,
-	columnInfoService service.ColumnInfoService,
+	schemaService service.SchemaService,
+	queryService service.QueryService,
+	savedQueryService service.SavedQueryService,
+	textToSqlService service.TextToSqlService,
+	sqlExampleService service.SqlExampleService,
 ) {
 	handler := &DatabaseHandler{
 		applicationService: applicationService,
 		dataSourceService:  dataSourceService,
-		tableInfoService:   tableInfoService,
-		columnInfoService:  columnInfoService,
+		schemaService:      schemaService,
+		queryService:       queryService,
+		savedQueryService:  savedQueryService,
+		textToSqlService:   textToSqlService,
+		sqlExampleService:  sqlExampleService,
 	}
 	Handlers = append(Handlers, handler)
 }
@@ -39,7 +55,14 @@ func (h *DatabaseHandler) RegisterRoutesV1_1(router fiber.Router) {
 func (h *DatabaseHandler) RegisterRoutesV1(router fiber.Router) {
 	router.Get("/databases", middleware.NewAppMiddleware(h.applicationService), h.GetDatabases)
 	router.Get("/schema", middleware.NewAppMiddleware(h.applicationService), h.GetDatabaseSchema)
+	router.Post("/schema/search", middleware.NewAppMiddleware(h.applicationService), h.SearchDatabaseSchema)
 	router.Post("/executeSql", middleware.NewAppMiddleware(h.applicationService), h.ExecuteSqlForDatabase)
+	router.Post("/exportSql", middleware.NewAppMiddleware(h.applicationService), h.ExportSql)
+	router.Get("/savedQueries", middleware.NewAppMiddleware(h.applicationService), h.GetSavedQueries)
+	router.Post("/executeSavedQuery", middleware.NewAppMiddleware(h.applicationService), h.ExecuteSavedQuery)
+	router.Post("/savedQueries/:datasourceId/:name", middleware.NewAppMiddleware(h.applicationService), h.ExecuteSavedQueryTool)
+	router.Post("/textToSql", middleware.NewAppMiddleware(h.applicationService), h.TextToSql)
+	router.Post("/sqlExamples/search", middleware.NewAppMiddleware(h.applicationService), h.SearchSqlExamples)
 }
 
 func (h *DatabaseHandler) GetDatabases(c *fiber.Ctx) error {
@@ -65,6 +88,8 @@ func (h *DatabaseHandler) GetDatabaseSchema(c *fiber.Ctx) error {
 	}
 	type Req struct {
 		DatasourceID uint64 `json:"datasourceId,string"`
+		Format       string `query:"format"`    // json（默认）, compact, ddl, markdown
+		MaxTokens    int    `query:"maxTokens"` // 输出的token预算，0为不限制
 	}
 	req := new(Req)
 	if err := c.QueryParser(req); err != nil {
@@ -79,38 +104,67 @@ func (h *DatabaseHandler) GetDatabaseSchema(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusForbidden).JSON(service.Error(constant.ErrForbidden))
 	}
 
-	type TableWithColumns struct {
-		*model.TableInfo
-		Columns []*model.ColumnInfo `json:"columns"`
+	tables, err := h.schemaService.ListTables(c.Context(), dataSource.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrDatabaseError))
 	}
 
-	// 获取database同步好的数据记录
-	var tables []*TableWithColumns
-	var list []*model.TableInfo
-	list, err = h.tableInfoService.ListSchemaForDify(c.Context(), &model.TableInfo{
-		DataSourceID: dataSource.ID,
-	})
+	result, err := h.schemaService.Render(tables, req.Format, req.MaxTokens)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	return c.JSON(service.OK(result))
+}
+
+// SearchDatabaseSchema 按用户问题检索最相关的表及列，适用于表很多、无法提供完整结构的数据源
+func (h *DatabaseHandler) SearchDatabaseSchema(c *fiber.Ctx) error {
+	application, _ := c.Locals("application").(*model.Application)
+	if application == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidCredential))
+	}
+	type Req struct {
+		DatasourceID uint64 `json:"datasourceId,string"`
+		Question     string `json:"question"`
+		TopN         int    `json:"topN"`       // 最相关的表数量，默认 schema.search_top_n
+		MaxColumns   int    `json:"maxColumns"` // 每张表最多返回的列数，默认 schema.search_max_columns
+		Format       string `json:"format"`     // json（默认）, compact, ddl, markdown
+		MaxTokens    int    `json:"maxTokens"`  // 输出的token预算，0为不限制
+	}
+	req := new(Req)
+	if err := c.BodyParser(req); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	if req.Question == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	if req.TopN <= 0 {
+		req.TopN = config.GetInt("schema.search_top_n")
+	}
+	if req.MaxColumns <= 0 {
+		req.MaxColumns = config.GetInt("schema.search_max_columns")
+	}
+
+	dataSource, err := h.dataSourceService.Get(c.Context(), req.DatasourceID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrDatabaseError))
 	}
+	if dataSource.ApplicationID != application.ID {
+		return c.Status(fiber.StatusForbidden).JSON(service.Error(constant.ErrForbidden))
+	}
 
-	for _, t := range list {
-		twc := new(TableWithColumns)
-		twc.TableInfo = t
-		var cl []*model.ColumnInfo
+	tables, err := h.schemaService.Search(c.Context(), dataSource.ID, req.Question, req.TopN, req.MaxColumns)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrDatabaseError))
+	}
 
-		cl, err = h.columnInfoService.ListSchemaForDify(c.Context(), &model.ColumnInfo{
-			TableID: t.ID,
-		})
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrDatabaseError))
-		}
-		twc.Columns = cl
-		twc.ID = 0
-		tables = append(tables, twc)
+	result, err := h.schemaService.Render(tables, req.Format, req.MaxTokens)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
 	}
 
-	return c.JSON(service.OK(tables))
+	return c.JSON(service.OK(result))
 }
 
 func (h *DatabaseHandler) ExecuteSqlForDatabase(c *fiber.Ctx) error {
@@ -120,8 +174,12 @@ func (h *DatabaseHandler) ExecuteSqlForDatabase(c *fiber.Ctx) error {
 	}
 
 	type Req struct {
-		Sql          string `json:"sql"`
-		DataSourceID uint64 `json:"datasourceId,string"`
+		Sql          string            `json:"sql"`
+		DataSourceID uint64            `json:"datasourceId,string"`
+		CustomID     string            `json:"customId"`
+		Variables    map[string]string `json:"variables"`
+		Cursor       string            `json:"cursor"`
+		Question     string            `json:"question"`
 	}
 	req := new(Req)
 	if err := c.BodyParser(req); err != nil {
@@ -142,19 +200,464 @@ func (h *DatabaseHandler) ExecuteSqlForDatabase(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusForbidden).JSON(service.Error(constant.ErrForbidden))
 	}
 
-	// 得到datasource的连接
-	db, err := datasource.GetDB(dataSource)
+	variables := service.RowFilterVariables(req.Variables, req.CustomID)
+
+	// 只读检查并执行，结果放到map[string]interface{}
+	result, err := h.queryService.ExecuteSql(c.Context(), dataSource, &service.QueryRequest{
+		Sql:       req.Sql,
+		Variables: variables,
+		Cursor:    req.Cursor,
+		Source:    model.SqlAuditSourceExecuteSql,
+		Question:  req.Question,
+	})
+	if err != nil {
+		// 告知智能体拒绝原因，便于其修正SQL
+		var violation *sqlguard.Violation
+		if errors.As(err, &violation) {
+			return c.Status(fiber.StatusBadRequest).JSON(service.NewResponse(violation, constant.ErrSqlNotAllowed))
+		}
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	return c.JSON(service.OK(result))
+}
+
+// ExportSql 执行SQL并将完整结果导出为CSV或XLSX文件，返回限时有效的下载地址，可直接放在回答中供用户下载
+func (h *DatabaseHandler) ExportSql(c *fiber.Ctx) error {
+	application, _ := c.Locals("application").(*model.Application)
+	if application == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidCredential))
+	}
+
+	type Req struct {
+		Sql          string            `json:"sql"`
+		DataSourceID uint64            `json:"datasourceId,string"`
+		CustomID     string            `json:"customId"`
+		Variables    map[string]string `json:"variables"`
+		Question     string            `json:"question"`
+		Format       string            `json:"format"`
+		FileName     string            `json:"fileName"`
+	}
+	req := new(Req)
+	if err := c.BodyParser(req); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if req.Sql == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	// 检查datasource是否该应用
+	dataSource, err := h.dataSourceService.Get(c.Context(), req.DataSourceID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrDatabaseError))
+	}
+	if dataSource.ApplicationID != application.ID {
+		return c.Status(fiber.StatusForbidden).JSON(service.Error(constant.ErrForbidden))
+	}
+
+	variables := service.RowFilterVariables(req.Variables, req.CustomID)
+
+	result, err := h.queryService.Export(c.Context(), dataSource, &service.ExportRequest{
+		QueryRequest: service.QueryRequest{
+			Sql:       req.Sql,
+			Variables: variables,
+			Question:  req.Question,
+		},
+		Format:   strings.ToLower(req.Format),
+		FileName: req.FileName,
+	})
+	if err != nil {
+		var violation *sqlguard.Violation
+		if errors.As(err, &violation) {
+			return c.Status(fiber.StatusBadRequest).JSON(service.NewResponse(violation, constant.ErrSqlNotAllowed))
+		}
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+	result.Url = publicBaseURL(c) + result.Url
+
+	return c.JSON(service.OK(result))
+}
+
+// GetSavedQueries 获取数据源的预置查询及参数说明，供智能体选择
+func (h *DatabaseHandler) GetSavedQueries(c *fiber.Ctx) error {
+	application, _ := c.Locals("application").(*model.Application)
+	if application == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidCredential))
+	}
+	type Req struct {
+		DatasourceID uint64 `query:"datasourceId"`
+	}
+	req := new(Req)
+	if err := c.QueryParser(req); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	dataSource, err := h.dataSourceService.Get(c.Context(), req.DatasourceID)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+	if dataSource.ApplicationID != application.ID {
+		return c.Status(fiber.StatusForbidden).JSON(service.Error(constant.ErrForbidden))
+	}
+
+	list, err := h.savedQueryService.ListForDify(c.Context(), dataSource.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(err))
+	}
+
+	return c.JSON(service.OK(list))
+}
+
+// ExecuteSavedQuery 按名称执行预置查询，参数按定义的类型代入
+func (h *DatabaseHandler) ExecuteSavedQuery(c *fiber.Ctx) error {
+	application, _ := c.Locals("application").(*model.Application)
+	if application == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidCredential))
+	}
+
+	type Req struct {
+		DataSourceID uint64                 `json:"datasourceId,string"`
+		Name         string                 `json:"name"`
+		Params       map[string]interface{} `json:"params"`
+		CustomID     string                 `json:"customId"`
+		Variables    map[string]string      `json:"variables"`
+		Cursor       string                 `json:"cursor"`
+	}
+	req := new(Req)
+	if err := c.BodyParser(req); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	variables := service.RowFilterVariables(req.Variables, req.CustomID)
+
+	return h.executeSavedQuery(c, application, req.DataSourceID, &service.SavedQueryRequest{
+		Name:      req.Name,
+		Params:    req.Params,
+		Variables: variables,
+		Cursor:    req.Cursor,
+	})
+}
+
+// ExecuteSavedQueryTool 执行为单个预置查询生成的工具，参数值直接放在请求体中，customId、cursor 通过查询参数传入
+func (h *DatabaseHandler) ExecuteSavedQueryTool(c *fiber.Ctx) error {
+	application, _ := c.Locals("application").(*model.Application)
+	if application == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidCredential))
+	}
+
+	dataSourceID, err := strconv.ParseUint(c.Params("datasourceId"), 10, 64)
+	if err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	params := make(map[string]interface{})
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&params); err != nil {
+			logger.Error("请求参数解析失败", logger.F("err", err))
+			return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+		}
+	}
+	variables := service.RowFilterVariables(nil, c.Query("customId"))
+
+	return h.executeSavedQuery(c, application, dataSourceID, &service.SavedQueryRequest{
+		Name:      c.Params("name"),
+		Params:    params,
+		Variables: variables,
+		Cursor:    c.Query("cursor"),
+	})
+}
+
+func (h *DatabaseHandler) executeSavedQuery(c *fiber.Ctx, application *model.Application, dataSourceID uint64, req *service.SavedQueryRequest) error {
+	// 检查datasource是否该应用
+	dataSource, err := h.dataSourceService.Get(c.Context(), dataSourceID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrDatabaseError))
 	}
+	if dataSource.ApplicationID != application.ID {
+		return c.Status(fiber.StatusForbidden).JSON(service.Error(constant.ErrForbidden))
+	}
+
+	result, err := h.savedQueryService.Execute(c.Context(), dataSource, req)
+	if err != nil {
+		// 告知智能体参数或SQL的问题，便于其修正参数
+		var paramErr *service.QueryParamError
+		if errors.As(err, &paramErr) {
+			return c.Status(fiber.StatusBadRequest).JSON(service.NewResponse(paramErr, constant.ErrQueryParamInvalid))
+		}
+		var violation *sqlguard.Violation
+		if errors.As(err, &violation) {
+			return c.Status(fiber.StatusBadRequest).JSON(service.NewResponse(violation, constant.ErrSqlNotAllowed))
+		}
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
 
-	// 进行查询，并把结果放到map[string]interface{}
-	var result []map[string]interface{}
-	err = db.Raw(req.Sql).Find(&result).Error
+	return c.JSON(service.OK(result))
+}
+
+// TextToSql 由内置的SQL构建器根据问题生成SQL并执行，返回SQL及结果
+func (h *DatabaseHandler) TextToSql(c *fiber.Ctx) error {
+	application, _ := c.Locals("application").(*model.Application)
+	if application == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidCredential))
+	}
+
+	type Req struct {
+		DataSourceID uint64            `json:"datasourceId,string"`
+		Question     string            `json:"question"`
+		CustomID     string            `json:"customId"`
+		Variables    map[string]string `json:"variables"`
+	}
+	req := new(Req)
+	if err := c.BodyParser(req); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if req.Question == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	// 检查datasource是否该应用
+	dataSource, err := h.dataSourceService.Get(c.Context(), req.DataSourceID)
 	if err != nil {
-		logger.Error("执行sql失败", logger.F("err", err))
 		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrDatabaseError))
 	}
+	if dataSource.ApplicationID != application.ID {
+		return c.Status(fiber.StatusForbidden).JSON(service.Error(constant.ErrForbidden))
+	}
+
+	variables := service.RowFilterVariables(req.Variables, req.CustomID)
+
+	result, err := h.textToSqlService.Ask(c.Context(), dataSource, &service.TextToSqlRequest{
+		Question:  req.Question,
+		Variables: variables,
+	})
+	if err != nil {
+		// 失败时仍返回最后一次生成的SQL及拒绝原因
+		return c.Status(constant.GetErrorCode(err)).JSON(service.NewResponse(result, err))
+	}
 
 	return c.JSON(service.OK(result))
 }
+
+// SearchSqlExamples 检索与问题最相似的已确认问题及SQL，供智能体作为少样本示例参考
+func (h *DatabaseHandler) SearchSqlExamples(c *fiber.Ctx) error {
+	application, _ := c.Locals("application").(*model.Application)
+	if application == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidCredential))
+	}
+
+	type Req struct {
+		DataSourceID uint64 `json:"datasourceId,string"`
+		Question     string `json:"question"`
+		TopN         int    `json:"topN"`
+	}
+	req := new(Req)
+	if err := c.BodyParser(req); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if req.Question == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	// 检查datasource是否该应用
+	dataSource, err := h.dataSourceService.Get(c.Context(), req.DataSourceID)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+	if dataSource.ApplicationID != application.ID {
+		return c.Status(fiber.StatusForbidden).JSON(service.Error(constant.ErrForbidden))
+	}
+
+	list, err := h.sqlExampleService.Search(c.Context(), dataSource.ID, req.Question, req.TopN)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	return c.JSON(service.OK(list))
+}
+
+// OpenAPIPaths 数据查询相关工具的接口描述，带应用密钥时为该应用的每个预置查询生成一个工具
+func (h *DatabaseHandler) OpenAPIPaths(ctx context.Context, application *model.Application) map[string]*OpenAPIPathItem {
+	datasourceID := &OpenAPISchema{Type: "string", Description: "数据源ID"}
+	customID := &OpenAPISchema{Type: "string", Description: "终端用户ID，用于行级权限过滤"}
+	format := &OpenAPISchema{Type: "string", Description: "输出格式", Enum: []string{"json", "compact", "ddl", "markdown"}}
+
+	paths := map[string]*OpenAPIPathItem{
+		"/databases": {Get: &OpenAPIOperation{
+			Description: "获取应用所有数据源",
+			OperationID: "GetDatabasesForApp",
+			Parameters:  []*OpenAPIParameter{openAPIAuthorization()},
+		}},
+		"/schema": {Get: &OpenAPIOperation{
+			Description: "获取应用数据库结构信息",
+			OperationID: "GetDatabaseSchema",
+			Parameters: []*OpenAPIParameter{
+				openAPIAuthorization(),
+				openAPIQuery("datasourceId", "string", "数据源ID", true),
+				openAPIQuery("format", "string", "输出格式：json、compact、ddl、markdown", false),
+				openAPIQuery("maxTokens", "integer", "输出的token预算，超出时省略次要的列和表", false),
+			},
+		}},
+		"/schema/search": {Post: &OpenAPIOperation{
+			Description: "根据问题检索相关的表和列",
+			OperationID: "SearchDatabaseSchema",
+			Parameters:  []*OpenAPIParameter{openAPIAuthorization()},
+			RequestBody: openAPIJSONBody(map[string]*OpenAPISchema{
+				"datasourceId": datasourceID,
+				"question":     {Type: "string", Description: "用户问题"},
+				"topN":         {Type: "integer", Description: "返回最相关的表数量"},
+				"format":       format,
+			}, "datasourceId", "question"),
+		}},
+		"/executeSql": {Post: &OpenAPIOperation{
+			Description: "执行SQL",
+			OperationID: "executeSql",
+			Parameters:  []*OpenAPIParameter{openAPIAuthorization()},
+			RequestBody: openAPIJSONBody(map[string]*OpenAPISchema{
+				"sql":          {Type: "string", Description: "SQL语句"},
+				"datasourceId": datasourceID,
+				"customId":     customID,
+				"cursor":       {Type: "string", Description: "续查游标，结果被截断时返回的nextCursor，需与原SQL一起传入"},
+				"question":     {Type: "string", Description: "SQL所回答的用户问题，记录后可供管理员确认为示例"},
+			}, "sql", "datasourceId"),
+		}},
+		"/exportSql": {Post: &OpenAPIOperation{
+			Description: "执行SQL并将完整结果导出为CSV或XLSX文件，返回限时有效的下载地址（url），用户需要完整数据时使用，回答中以链接形式给出",
+			OperationID: "exportSql",
+			Parameters:  []*OpenAPIParameter{openAPIAuthorization()},
+			RequestBody: openAPIJSONBody(map[string]*OpenAPISchema{
+				"sql":          {Type: "string", Description: "SQL语句"},
+				"datasourceId": datasourceID,
+				"customId":     customID,
+				"format":       {Type: "string", Description: "文件格式，默认xlsx", Enum: []string{"xlsx", "csv"}},
+				"fileName":     {Type: "string", Description: "文件名，不含扩展名"},
+				"question":     {Type: "string", Description: "SQL所回答的用户问题"},
+			}, "sql", "datasourceId"),
+		}},
+		"/sqlExamples/search": {Post: &OpenAPIOperation{
+			Description: "检索与问题相似的已确认问题及SQL，编写SQL前可作为参考示例",
+			OperationID: "searchSqlExamples",
+			Parameters:  []*OpenAPIParameter{openAPIAuthorization()},
+			RequestBody: openAPIJSONBody(map[string]*OpenAPISchema{
+				"datasourceId": datasourceID,
+				"question":     {Type: "string", Description: "用户问题"},
+				"topN":         {Type: "integer", Description: "返回最相似的示例数量"},
+			}, "datasourceId", "question"),
+		}},
+		"/textToSql": {Post: &OpenAPIOperation{
+			Description: "根据问题自动生成SQL并执行，返回SQL及结果，适用于不便自行编写SQL的场景",
+			OperationID: "textToSql",
+			Parameters:  []*OpenAPIParameter{openAPIAuthorization()},
+			RequestBody: openAPIJSONBody(map[string]*OpenAPISchema{
+				"datasourceId": datasourceID,
+				"question":     {Type: "string", Description: "用户问题"},
+				"customId":     customID,
+			}, "datasourceId", "question"),
+		}},
+		"/savedQueries": {Get: &OpenAPIOperation{
+			Description: "获取数据源的预置查询及参数说明，能满足问题时优先使用预置查询",
+			OperationID: "GetSavedQueries",
+			Parameters: []*OpenAPIParameter{
+				openAPIAuthorization(),
+				openAPIQuery("datasourceId", "string", "数据源ID", true),
+			},
+		}},
+		"/executeSavedQuery": {Post: &OpenAPIOperation{
+			Description: "按名称执行预置查询",
+			OperationID: "executeSavedQuery",
+			Parameters:  []*OpenAPIParameter{openAPIAuthorization()},
+			RequestBody: openAPIJSONBody(map[string]*OpenAPISchema{
+				"datasourceId": datasourceID,
+				"name":         {Type: "string", Description: "预置查询名称"},
+				"params":       {Type: "object", Description: "参数值，键为参数名"},
+				"customId":     customID,
+				"cursor":       {Type: "string", Description: "续查游标，结果被截断时返回的nextCursor，需与相同的参数一起传入"},
+			}, "datasourceId", "name"),
+		}},
+	}
+
+	if application.ID == 0 {
+		return paths
+	}
+	dataSources, err := h.dataSourceService.ListForDify(ctx, &model.DataSource{ApplicationID: application.ID})
+	if err != nil {
+		return paths
+	}
+	operationIDs := make(map[string]bool)
+	for _, dataSource := range dataSources {
+		queries, err := h.savedQueryService.ListForDify(ctx, dataSource.ID)
+		if err != nil {
+			continue
+		}
+		for _, q := range queries {
+			// 不同数据源的预置查询可能同名，工具名重复时带上数据源ID
+			operationID := "savedQuery_" + q.Name
+			if operationIDs[operationID] {
+				operationID = fmt.Sprintf("%s_%d", operationID, dataSource.ID)
+			}
+			operationIDs[operationID] = true
+
+			properties := make(map[string]*OpenAPISchema, len(q.Params))
+			var required []string
+			for _, p := range q.Params {
+				properties[p.Name] = savedQueryParamSchema(p)
+				if p.Required {
+					required = append(required, p.Name)
+				}
+			}
+			description := q.Description
+			if description == "" {
+				description = q.Name
+			}
+			paths[fmt.Sprintf("/savedQueries/%d/%s", dataSource.ID, q.Name)] = &OpenAPIPathItem{Post: &OpenAPIOperation{
+				Description: fmt.Sprintf("%s（数据源：%s）", description, dataSource.Name),
+				OperationID: operationID,
+				Parameters: []*OpenAPIParameter{
+					openAPIAuthorization(),
+					openAPIQuery("customId", "string", "终端用户ID，用于行级权限过滤", false),
+					openAPIQuery("cursor", "string", "续查游标，结果被截断时返回的nextCursor，需与相同的参数一起传入", false),
+				},
+				RequestBody: openAPIJSONBody(properties, required...),
+			}}
+		}
+	}
+	return paths
+}
+
+// savedQueryParamSchema 预置查询参数对应的OpenAPI类型
+func savedQueryParamSchema(param model.QueryParam) *OpenAPISchema {
+	schema := &OpenAPISchema{Type: "string"}
+	notes := []string{}
+	if param.Description != "" {
+		notes = append(notes, param.Description)
+	}
+	switch param.Type {
+	case model.QueryParamInteger:
+		schema.Type = "integer"
+	case model.QueryParamNumber:
+		schema.Type = "number"
+	case model.QueryParamBoolean:
+		schema.Type = "boolean"
+	case model.QueryParamDate:
+		schema.Format = "date"
+		notes = append(notes, "格式 2006-01-02")
+	case model.QueryParamDateTime:
+		schema.Format = "date-time"
+		notes = append(notes, "格式 2006-01-02 15:04:05")
+	}
+	if param.Default != "" {
+		notes = append(notes, "默认 "+param.Default)
+	}
+	schema.Description = strings.Join(notes, "，")
+	return schema
+}
@@ -0,0 +1,160 @@
+package difyapi
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/middleware"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/internal/service"
+	"github.com/yockii/dify_tools/pkg/config"
+)
+
+// OpenAPIDescriber 处理器实现该接口后，其接口会出现在 /openapi.json 中，供dify自定义工具直接导入
+type OpenAPIDescriber interface {
+	// OpenAPIPaths 返回接口描述，键为相对于 /dify_api/v1 的路径；application 为调用方应用，未带密钥时ID为0
+	OpenAPIPaths(ctx context.Context, application *model.Application) map[string]*OpenAPIPathItem
+}
+
+// OpenAPIPathItem 同一路径下各方法的接口描述
+type OpenAPIPathItem struct {
+	Get  *OpenAPIOperation `json:"get,omitempty"`
+	Post *OpenAPIOperation `json:"post,omitempty"`
+}
+
+// OpenAPIOperation 接口描述，dify将每个接口作为一个工具
+type OpenAPIOperation struct {
+	Description string              `json:"description"`
+	OperationID string              `json:"operationId"`
+	Parameters  []*OpenAPIParameter `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody `json:"requestBody,omitempty"`
+	Deprecated  bool                `json:"deprecated"`
+}
+
+type OpenAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"` // header, query
+	Description string         `json:"description"`
+	Required    bool           `json:"required"`
+	Schema      *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Content map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPISchema struct {
+	Type        string                    `json:"type"`
+	Format      string                    `json:"format,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Enum        []string                  `json:"enum,omitempty"`
+	Properties  map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Required    []string                  `json:"required,omitempty"`
+}
+
+// openAPIAuthorization 应用密钥请求头，dify工具中配置为固定值
+func openAPIAuthorization() *OpenAPIParameter {
+	return &OpenAPIParameter{
+		Name:        "Authorization",
+		In:          "header",
+		Description: "应用密钥",
+		Required:    true,
+		Schema:      &OpenAPISchema{Type: "string"},
+	}
+}
+
+// openAPIQuery 查询参数
+func openAPIQuery(name, typ, description string, required bool) *OpenAPIParameter {
+	return &OpenAPIParameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Required:    required,
+		Schema:      &OpenAPISchema{Type: typ},
+	}
+}
+
+// openAPIJSONBody JSON请求体
+func openAPIJSONBody(properties map[string]*OpenAPISchema, required ...string) *OpenAPIRequestBody {
+	return &OpenAPIRequestBody{
+		Content: map[string]*OpenAPIMediaType{
+			"application/json": {
+				Schema: &OpenAPISchema{
+					Type:       "object",
+					Properties: properties,
+					Required:   required,
+				},
+			},
+		},
+	}
+}
+
+// publicBaseURL 对外访问地址，取自 server.public_url，未配置时使用请求地址
+func publicBaseURL(c *fiber.Ctx) string {
+	if baseURL := strings.TrimRight(config.GetString("server.public_url"), "/"); baseURL != "" {
+		return baseURL
+	}
+	return c.BaseURL()
+}
+
+type OpenAPIHandler struct {
+	applicationService service.ApplicationService
+}
+
+func RegisterOpenAPIHandler(applicationService service.ApplicationService) {
+	handler := &OpenAPIHandler{
+		applicationService: applicationService,
+	}
+	Handlers = append(Handlers, handler)
+}
+
+func (h *OpenAPIHandler) RegisterRoutesV1_1(router fiber.Router) {
+	h.RegisterRoutesV1(router)
+}
+
+func (h *OpenAPIHandler) RegisterRoutesV1(router fiber.Router) {
+	router.Get("/openapi.json", middleware.NewAppMiddleware(h.applicationService), h.GetOpenAPI)
+}
+
+// GetOpenAPI 根据已注册的处理器生成dify自定义工具的OpenAPI描述，服务地址取自 server.public_url，未配置时使用请求地址；
+// 带应用密钥请求时，该应用的每个预置查询会额外生成一个工具
+func (h *OpenAPIHandler) GetOpenAPI(c *fiber.Ctx) error {
+	application, _ := c.Locals("application").(*model.Application)
+	if application == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidCredential))
+	}
+
+	paths := make(map[string]*OpenAPIPathItem)
+	for _, handler := range Handlers {
+		if describer, ok := handler.(OpenAPIDescriber); ok {
+			for path, item := range describer.OpenAPIPaths(c.Context(), application) {
+				paths[path] = item
+			}
+		}
+	}
+
+	// 同时用于 /dify_api/v1 及 /dify_api/v1_1
+	prefix := strings.TrimSuffix(c.Path(), "/openapi.json")
+
+	return c.JSON(fiber.Map{
+		"openapi": "3.1.0",
+		"info": fiber.Map{
+			"title":       "应用数据查询",
+			"description": "查询应用数据信息.",
+			"version":     "v1.0.0",
+		},
+		"servers": []fiber.Map{{
+			"url": publicBaseURL(c) + prefix,
+		}},
+		"paths": paths,
+		"components": fiber.Map{
+			"schemas": fiber.Map{},
+		},
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/yockii/dify_tools/internal/model"
 	"github.com/yockii/dify_tools/internal/service"
 	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/secret"
 )
 
 type AgentHandler struct {
@@ -34,12 +35,20 @@ func (h *AgentHandler) RegisterRoutesV1(router fiber.Router, authMiddleware fibe
 	}
 }
 
+// agentRequest 智能体创建/更新请求，ApiSecret 在模型中不参与JSON序列化，需单独接收
+type agentRequest struct {
+	model.Agent
+	ApiSecret string `json:"apiSecret"`
+}
+
 func (h *AgentHandler) CreateAgent(c *fiber.Ctx) error {
-	var record model.Agent
-	if err := c.BodyParser(&record); err != nil {
+	var req agentRequest
+	if err := c.BodyParser(&req); err != nil {
 		logger.Error("解析参数失败", logger.F("err", err))
 		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
 	}
+	record := req.Agent
+	record.ApiSecret = secret.String(req.ApiSecret)
 
 	if record.Code == "" || record.Name == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
@@ -53,11 +62,14 @@ func (h *AgentHandler) CreateAgent(c *fiber.Ctx) error {
 }
 
 func (h *AgentHandler) UpdateAgent(c *fiber.Ctx) error {
-	var record model.Agent
-	if err := c.BodyParser(&record); err != nil {
+	var req agentRequest
+	if err := c.BodyParser(&req); err != nil {
 		logger.Error("解析参数失败", logger.F("err", err))
 		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
 	}
+	// 未传入密钥时保持原值
+	record := req.Agent
+	record.ApiSecret = secret.String(req.ApiSecret)
 
 	if record.ID == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
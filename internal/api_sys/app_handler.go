@@ -1,13 +1,22 @@
 package sysapi
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/datasource"
 	"github.com/yockii/dify_tools/internal/model"
 	"github.com/yockii/dify_tools/internal/service"
+	"github.com/yockii/dify_tools/pkg/docgen"
 	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/masking"
+	"github.com/yockii/dify_tools/pkg/secret"
+	"github.com/yockii/dify_tools/pkg/sqlguard"
 	"github.com/yockii/dify_tools/pkg/util"
 )
 
@@ -16,10 +25,15 @@ type AppHandler struct {
 	dataSourceService service.DataSourceService
 	tableInfoService  service.TableInfoService
 	columnInfoService service.ColumnInfoService
+	syncReportService service.SchemaSyncReportService
 
 	knowledgeService service.KnowledgeBaseService
 
-	usageService service.UsageService
+	usageService      service.UsageService
+	sqlAuditService   service.SqlAuditService
+	savedQueryService service.SavedQueryService
+	sqlExampleService service.SqlExampleService
+	schemaDocService  service.SchemaKnowledgeService
 
 	logService service.LogService
 }
@@ -29,8 +43,13 @@ func RegisterAppHandler(
 	dataSourceService service.DataSourceService,
 	tableInfoService service.TableInfoService,
 	columnInfoService service.ColumnInfoService,
+	syncReportService service.SchemaSyncReportService,
 	knowledgeService service.KnowledgeBaseService,
 	usageService service.UsageService,
+	sqlAuditService service.SqlAuditService,
+	savedQueryService service.SavedQueryService,
+	sqlExampleService service.SqlExampleService,
+	schemaDocService service.SchemaKnowledgeService,
 
 	logService service.LogService,
 ) {
@@ -39,8 +58,13 @@ func RegisterAppHandler(
 		dataSourceService: dataSourceService,
 		tableInfoService:  tableInfoService,
 		columnInfoService: columnInfoService,
+		syncReportService: syncReportService,
 		knowledgeService:  knowledgeService,
 		usageService:      usageService,
+		sqlAuditService:   sqlAuditService,
+		savedQueryService: savedQueryService,
+		sqlExampleService: sqlExampleService,
+		schemaDocService:  schemaDocService,
 
 		logService: logService,
 	}
@@ -69,12 +93,33 @@ func (h *AppHandler) RegisterRoutesV1(router fiber.Router, authMiddleware fiber.
 		dataSources.Get("/list", h.ListDataSources)
 		dataSources.Get("/info", h.GetDataSource)
 		dataSources.Get("/sync", h.SyncDataSource)
+		dataSources.Get("/sync_reports", h.ListSyncReports)
+		dataSources.Get("/sync_report", h.GetSyncReport)
+		dataSources.Post("/sync_report/resolve", h.ResolveSyncReport)
+		dataSources.Post("/import", h.ImportDataSource)
+		dataSources.Post("/glossary/import", h.ImportDataSourceGlossary)
+		dataSources.Post("/test", h.TestDataSource)
+		dataSources.Get("/health", h.GetDataSourceHealth)
 		dataSources.Get("/tables", h.GetDataSourceTables)
 		dataSources.Post("/update_table", h.UpdateDataSourceTable)
 		dataSources.Post("/delete_table", h.DeleteDataSourceTable)
 		dataSources.Get("/columns", h.GetDataSourceColumns)
 		dataSources.Post("/update_column", h.UpdateDataSourceColumn)
 		dataSources.Post("/delete_column", h.DeleteDataSourceColumn)
+		dataSources.Post("/pii/confirm", h.ConfirmPii)
+		dataSources.Get("/saved_queries", h.ListSavedQueries)
+		dataSources.Post("/saved_query/new", h.CreateSavedQuery)
+		dataSources.Post("/saved_query/update", h.UpdateSavedQuery)
+		dataSources.Post("/saved_query/delete", h.DeleteSavedQuery)
+		dataSources.Get("/sql_examples", h.ListSqlExamples)
+		dataSources.Post("/sql_example/new", h.CreateSqlExample)
+		dataSources.Post("/sql_example/update", h.UpdateSqlExample)
+		dataSources.Post("/sql_example/delete", h.DeleteSqlExample)
+		dataSources.Post("/sql_example/promote", h.PromoteSqlExample)
+		dataSources.Get("/dictionary/documents", h.ListDictionaryDocuments)
+		dataSources.Post("/dictionary/publish", h.PublishDictionary)
+		dataSources.Post("/dictionary/unpublish", h.UnpublishDictionary)
+		dataSources.Get("/dictionary/export", h.ExportDictionary)
 	}
 
 	agent := apps.Group("/agent")
@@ -88,6 +133,12 @@ func (h *AppHandler) RegisterRoutesV1(router fiber.Router, authMiddleware fiber.
 	{
 		usage.Get("/list", h.GetApplicationUsageList)
 	}
+
+	sqlAudit := apps.Group("/sql_audit")
+	{
+		sqlAudit.Get("/list", h.ListSqlAudits)
+		sqlAudit.Get("/export", h.ExportSqlAudits)
+	}
 }
 
 ///////////////////////////////////////////////////////////////////
@@ -207,15 +258,23 @@ func (h *AppHandler) GetApp(c *fiber.Ctx) error {
 //////////               DataSource                      //////////
 //region///////////////////////////////////////////////////////////
 
+// dataSourceRequest 数据源创建/更新/测试请求，Password 在模型中不参与JSON序列化，需单独接收
+type dataSourceRequest struct {
+	model.DataSource
+	Password string `json:"password"`
+}
+
 // CreateDataSource 创建数据源
 func (h *AppHandler) CreateDataSource(c *fiber.Ctx) error {
-	var dataSource model.DataSource
-	if err := c.BodyParser(&dataSource); err != nil {
+	var req dataSourceRequest
+	if err := c.BodyParser(&req); err != nil {
 		logger.Error("请求参数解析失败", logger.F("err", err))
 		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
 	}
+	dataSource := req.DataSource
+	dataSource.Password = secret.String(req.Password)
 
-	if dataSource.Name == "" || dataSource.Host == "" || dataSource.Port == 0 {
+	if dataSource.Name == "" || !validDataSourceConnection(&dataSource) {
 		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
 	}
 
@@ -230,13 +289,56 @@ func (h *AppHandler) CreateDataSource(c *fiber.Ctx) error {
 	return c.JSON(service.OK(dataSource))
 }
 
+// 表、列信息中可以清空的字段，JSON键名 → 字段名
+var (
+	clearableTableFields = map[string]string{
+		"rowFilter":        "RowFilter",
+		"businessName":     "BusinessName",
+		"description":      "Description",
+		"synonyms":         "Synonyms",
+		"exampleQuestions": "ExampleQuestions",
+	}
+	clearableColumnFields = map[string]string{
+		"businessName": "BusinessName",
+		"description":  "Description",
+		"synonyms":     "Synonyms",
+		"unit":         "Unit",
+		"enumValues":   "EnumValues",
+	}
+)
+
+// presentFields 返回请求体中出现的字段名，未出现的字段保持原值，出现的字段为空值时清空
+func presentFields(body []byte, keys map[string]string) []string {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil
+	}
+	var fields []string
+	for key, field := range keys {
+		if _, ok := raw[key]; ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// validDataSourceConnection 检查连接参数，SQLite只需要文件名
+func validDataSourceConnection(dataSource *model.DataSource) bool {
+	if dataSource.Type == "sqlite" {
+		return dataSource.Database != ""
+	}
+	return dataSource.Host != "" && dataSource.Port != 0
+}
+
 // UpdateDataSource 更新数据源
 func (h *AppHandler) UpdateDataSource(c *fiber.Ctx) error {
-	var dataSource model.DataSource
-	if err := c.BodyParser(&dataSource); err != nil {
+	var req dataSourceRequest
+	if err := c.BodyParser(&req); err != nil {
 		logger.Error("请求参数解析失败", logger.F("err", err))
 		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
 	}
+	dataSource := req.DataSource
+	dataSource.Password = secret.String(req.Password)
 
 	if err := h.dataSourceService.Update(c.Context(), &dataSource); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(err))
@@ -314,7 +416,8 @@ func (h *AppHandler) SyncDataSource(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
 	}
 
-	if err := h.dataSourceService.Sync(c.Context(), id); err != nil {
+	report, err := h.dataSourceService.Sync(c.Context(), id)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(err))
 	}
 
@@ -322,7 +425,177 @@ func (h *AppHandler) SyncDataSource(c *fiber.Ctx) error {
 	user := c.Locals("user").(*model.User)
 	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionSyncDataSource, c.IP(), c.Get("User-Agent"))
 
-	return c.JSON(service.OK(nil))
+	return c.JSON(service.OK(report))
+}
+
+// ListSyncReports 获取数据源的同步报告列表，pending=1 时只返回有待确认删除的报告
+func (h *AppHandler) ListSyncReports(c *fiber.Ctx) error {
+	offset := c.QueryInt("offset", 0)
+	limit := c.QueryInt("limit", service.DefaultPageSize)
+	if limit > service.MaxPageSize {
+		limit = service.MaxPageSize
+	}
+
+	condition := new(model.SchemaSyncReport)
+	if err := c.QueryParser(condition); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	if condition.DataSourceID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	reports, total, err := h.syncReportService.List(c.Context(), condition, offset, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(err))
+	}
+
+	return c.JSON(service.OK(service.NewListResponse(reports, total, offset, limit)))
+}
+
+// GetSyncReport 获取同步报告及差异明细
+func (h *AppHandler) GetSyncReport(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Query("id"), 10, 64)
+	if err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	report, err := h.syncReportService.GetWithItems(c.Context(), id)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	return c.JSON(service.OK(report))
+}
+
+// ResolveSyncReport 确认或拒绝同步报告中待确认的删除
+func (h *AppHandler) ResolveSyncReport(c *fiber.Ctx) error {
+	var req struct {
+		ID      uint64   `json:"id,string"`
+		ItemIDs []string `json:"itemIds"` // 为空时处理全部待确认项
+		Apply   bool     `json:"apply"`   // true: 确认删除, false: 拒绝（保留表/列信息）
+	}
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	if req.ID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	itemIDs := make([]uint64, 0, len(req.ItemIDs))
+	for _, s := range req.ItemIDs {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+		}
+		itemIDs = append(itemIDs, id)
+	}
+
+	report, err := h.syncReportService.Resolve(c.Context(), req.ID, itemIDs, req.Apply)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionResolveSyncReport, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(service.OK(report))
+}
+
+// ImportDataSource 上传CSV/XLSX文件导入为数据源，传入id时替换该导入数据源的数据
+func (h *AppHandler) ImportDataSource(c *fiber.Ctx) error {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	files := form.File["files"]
+	if len(files) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	dataSource := &model.DataSource{
+		Name: c.FormValue("name"),
+	}
+	if dataSource.ApplicationID, err = strconv.ParseUint(c.FormValue("applicationId"), 10, 64); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	if id := c.FormValue("id"); id != "" {
+		if dataSource.ID, err = strconv.ParseUint(id, 10, 64); err != nil {
+			logger.Error("请求参数解析失败", logger.F("err", err))
+			return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+		}
+	}
+
+	if err := h.dataSourceService.Import(c.Context(), dataSource, files[0]); err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionImportDataSource, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(service.OK(dataSource))
+}
+
+// ImportDataSourceGlossary 从CSV/XLSX导入数据源的业务术语（业务名称、说明、同义词、枚举值含义、单位及示例问题）
+func (h *AppHandler) ImportDataSourceGlossary(c *fiber.Ctx) error {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	files := form.File["files"]
+	if len(files) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	dataSourceID, err := strconv.ParseUint(c.FormValue("dataSourceId"), 10, 64)
+	if err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	if _, err := h.dataSourceService.Get(c.Context(), dataSourceID); err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	result, err := h.dataSourceService.ImportGlossary(c.Context(), dataSourceID, files[0])
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionImportGlossary, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(service.OK(result))
+}
+
+// TestDataSource 测试数据源连接，可测试未保存的配置；传入id时未填写的参数使用已保存的值
+func (h *AppHandler) TestDataSource(c *fiber.Ctx) error {
+	var req dataSourceRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	dataSource := req.DataSource
+	dataSource.Password = secret.String(req.Password)
+
+	if dataSource.ID == 0 && !validDataSourceConnection(&dataSource) {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	result, err := h.dataSourceService.Test(c.Context(), &dataSource)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	return c.JSON(service.OK(result))
+}
+
+// GetDataSourceHealth 获取当前数据源连接池状态
+func (h *AppHandler) GetDataSourceHealth(c *fiber.Ctx) error {
+	return c.JSON(service.OK(datasource.Stats()))
 }
 
 // GetDataSourceTables 获取数据源表列表
@@ -358,7 +631,22 @@ func (h *AppHandler) UpdateDataSourceTable(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
 	}
 
-	if err := h.tableInfoService.Update(c.Context(), &table); err != nil {
+	if table.RowFilter != "" {
+		existing, err := h.tableInfoService.Get(c.Context(), table.ID)
+		if err != nil {
+			return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+		}
+		dataSource, err := h.dataSourceService.Get(c.Context(), existing.DataSourceID)
+		if err != nil {
+			return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+		}
+		if err := sqlguard.CheckCondition(table.RowFilter, sqlguard.DialectOf(dataSource.Type)); err != nil {
+			logger.Warn("行级过滤条件不合法", logger.F("rowFilter", table.RowFilter), logger.F("err", err))
+			return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+		}
+	}
+
+	if err := h.tableInfoService.UpdateFields(c.Context(), &table, presentFields(c.Body(), clearableTableFields)); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(err))
 	}
 
@@ -369,7 +657,7 @@ func (h *AppHandler) UpdateDataSourceTable(c *fiber.Ctx) error {
 	return c.JSON(service.OK(table))
 }
 
-// GetDataSourceColumns 获取数据源表列列表
+// GetDataSourceColumns 获取数据源表列列表，未指定表时需指定数据源，如按 piiStatus=1 查询数据源中待确认的敏感列
 func (h *AppHandler) GetDataSourceColumns(c *fiber.Ctx) error {
 	offset := c.QueryInt("offset", 0)
 	limit := c.QueryInt("limit", service.DefaultPageSize)
@@ -382,7 +670,7 @@ func (h *AppHandler) GetDataSourceColumns(c *fiber.Ctx) error {
 		logger.Error("请求参数解析失败", logger.F("err", err))
 		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
 	}
-	if condition.TableID == 0 {
+	if condition.TableID == 0 && condition.DataSourceID == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
 	}
 
@@ -425,7 +713,11 @@ func (h *AppHandler) UpdateDataSourceColumn(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
 	}
 
-	if err := h.columnInfoService.Update(c.Context(), &column); err != nil {
+	if column.MaskType != "" && !masking.IsValid(column.MaskType) {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if err := h.columnInfoService.UpdateFields(c.Context(), &column, presentFields(c.Body(), clearableColumnFields)); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(err))
 	}
 
@@ -459,6 +751,397 @@ func (h *AppHandler) DeleteDataSourceColumn(c *fiber.Ctx) error {
 	return c.JSON(service.OK(nil))
 }
 
+// ConfirmPii 确认或否定同步时识别出的敏感列，确认后按脱敏设置提供给AI
+func (h *AppHandler) ConfirmPii(c *fiber.Ctx) error {
+	var req struct {
+		IDs      []string `json:"ids"`
+		Confirm  bool     `json:"confirm"`  // true: 确认为敏感信息, false: 不是敏感信息
+		MaskType string   `json:"maskType"` // 确认时的脱敏类型，为空时使用建议的脱敏类型
+	}
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	if len(req.IDs) == 0 || req.MaskType != "" && !masking.IsMasked(req.MaskType) {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	ids := make([]uint64, 0, len(req.IDs))
+	for _, s := range req.IDs {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+		}
+		ids = append(ids, id)
+	}
+
+	count, err := h.columnInfoService.ConfirmPii(c.Context(), ids, req.Confirm, req.MaskType)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionConfirmPii, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(service.OK(count))
+}
+
+//endregion
+
+///////////////////////////////////////////////////////////////////
+//////////               SavedQuery                      //////////
+//region///////////////////////////////////////////////////////////
+
+// ListSavedQueries 获取数据源的预置查询列表
+func (h *AppHandler) ListSavedQueries(c *fiber.Ctx) error {
+	offset := c.QueryInt("offset", 0)
+	limit := c.QueryInt("limit", service.DefaultPageSize)
+	if limit > service.MaxPageSize {
+		limit = service.MaxPageSize
+	}
+
+	condition := new(model.SavedQuery)
+	if err := c.QueryParser(condition); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	if condition.DataSourceID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	list, total, err := h.savedQueryService.List(c.Context(), condition, offset, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(err))
+	}
+
+	return c.JSON(service.OK(service.NewListResponse(list, total, offset, limit)))
+}
+
+// CreateSavedQuery 创建预置查询
+func (h *AppHandler) CreateSavedQuery(c *fiber.Ctx) error {
+	var query model.SavedQuery
+	if err := c.BodyParser(&query); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if query.DataSourceID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if err := h.savedQueryService.Create(c.Context(), &query); err != nil {
+		return sqlValidationError(c, err)
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionCreateSavedQuery, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(service.OK(query))
+}
+
+// UpdateSavedQuery 更新预置查询
+func (h *AppHandler) UpdateSavedQuery(c *fiber.Ctx) error {
+	var query model.SavedQuery
+	if err := c.BodyParser(&query); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if err := h.savedQueryService.Update(c.Context(), &query); err != nil {
+		return sqlValidationError(c, err)
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionUpdateSavedQuery, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(service.OK(query))
+}
+
+// DeleteSavedQuery 删除预置查询
+func (h *AppHandler) DeleteSavedQuery(c *fiber.Ctx) error {
+	var query model.SavedQuery
+	if err := c.BodyParser(&query); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if query.ID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if err := h.savedQueryService.Delete(c.Context(), query.ID); err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionDeleteSavedQuery, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(service.OK(nil))
+}
+
+// sqlValidationError 返回预置查询、SQL示例保存失败的原因，参数定义或SQL不合法时附带具体问题
+func sqlValidationError(c *fiber.Ctx, err error) error {
+	var paramErr *service.QueryParamError
+	if errors.As(err, &paramErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(service.NewResponse(paramErr, constant.ErrQueryParamInvalid))
+	}
+	var violation *sqlguard.Violation
+	if errors.As(err, &violation) {
+		return c.Status(fiber.StatusBadRequest).JSON(service.NewResponse(violation, constant.ErrSqlNotAllowed))
+	}
+	return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+}
+
+//endregion
+
+///////////////////////////////////////////////////////////////////
+//////////               SqlExample                      //////////
+//region///////////////////////////////////////////////////////////
+
+// ListSqlExamples 获取数据源的SQL示例列表
+func (h *AppHandler) ListSqlExamples(c *fiber.Ctx) error {
+	offset := c.QueryInt("offset", 0)
+	limit := c.QueryInt("limit", service.DefaultPageSize)
+	if limit > service.MaxPageSize {
+		limit = service.MaxPageSize
+	}
+
+	condition := new(model.SqlExample)
+	if err := c.QueryParser(condition); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	if condition.DataSourceID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	list, total, err := h.sqlExampleService.List(c.Context(), condition, offset, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(err))
+	}
+
+	return c.JSON(service.OK(service.NewListResponse(list, total, offset, limit)))
+}
+
+// CreateSqlExample 创建SQL示例
+func (h *AppHandler) CreateSqlExample(c *fiber.Ctx) error {
+	var example model.SqlExample
+	if err := c.BodyParser(&example); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if example.DataSourceID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	example.AuditID = 0
+
+	if err := h.sqlExampleService.Create(c.Context(), &example); err != nil {
+		return sqlValidationError(c, err)
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionCreateSqlExample, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(service.OK(example))
+}
+
+// UpdateSqlExample 更新SQL示例
+func (h *AppHandler) UpdateSqlExample(c *fiber.Ctx) error {
+	var example model.SqlExample
+	if err := c.BodyParser(&example); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if err := h.sqlExampleService.Update(c.Context(), &example); err != nil {
+		return sqlValidationError(c, err)
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionUpdateSqlExample, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(service.OK(example))
+}
+
+// DeleteSqlExample 删除SQL示例
+func (h *AppHandler) DeleteSqlExample(c *fiber.Ctx) error {
+	var example model.SqlExample
+	if err := c.BodyParser(&example); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if example.ID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if err := h.sqlExampleService.Delete(c.Context(), example.ID); err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionDeleteSqlExample, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(service.OK(nil))
+}
+
+// PromoteSqlExample 将执行成功的SQL审计记录转为SQL示例，未传问题时使用审计记录中的问题
+func (h *AppHandler) PromoteSqlExample(c *fiber.Ctx) error {
+	type Req struct {
+		AuditID  uint64 `json:"auditId,string"`
+		Question string `json:"question"`
+	}
+	req := new(Req)
+	if err := c.BodyParser(req); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if req.AuditID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	example, err := h.sqlExampleService.Promote(c.Context(), req.AuditID, req.Question)
+	if err != nil {
+		return sqlValidationError(c, err)
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionPromoteSqlExample, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(service.OK(example))
+}
+
+//endregion
+
+///////////////////////////////////////////////////////////////////
+//////////               Dictionary                      //////////
+//region///////////////////////////////////////////////////////////
+
+// ListDictionaryDocuments 获取数据源已发布到知识库的数据字典文档
+func (h *AppHandler) ListDictionaryDocuments(c *fiber.Ctx) error {
+	offset := c.QueryInt("offset", 0)
+	limit := c.QueryInt("limit", service.DefaultPageSize)
+	if limit > service.MaxPageSize {
+		limit = service.MaxPageSize
+	}
+
+	condition := new(model.SchemaDocument)
+	if err := c.QueryParser(condition); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	if condition.DataSourceID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	list, total, err := h.schemaDocService.List(c.Context(), condition, offset, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(err))
+	}
+
+	return c.JSON(service.OK(service.NewListResponse(list, total, offset, limit)))
+}
+
+// PublishDictionary 立即将数据源的数据字典发布到应用的公共知识库，不要求开启同步后自动发布
+func (h *AppHandler) PublishDictionary(c *fiber.Ctx) error {
+	var dataSource model.DataSource
+	if err := c.BodyParser(&dataSource); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if dataSource.ID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	result, err := h.schemaDocService.Publish(c.Context(), dataSource.ID)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionPublishDictionary, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(service.OK(result))
+}
+
+// UnpublishDictionary 从知识库中删除数据源的数据字典文档，返回删除的文档数
+func (h *AppHandler) UnpublishDictionary(c *fiber.Ctx) error {
+	var dataSource model.DataSource
+	if err := c.BodyParser(&dataSource); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	if dataSource.ID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	deleted, err := h.schemaDocService.Unpublish(c.Context(), dataSource.ID)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionUnpublishDictionary, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(service.OK(deleted))
+}
+
+// ExportDictionary 导出数据源的数据字典，format 为 docx（默认）或 markdown，all=1 时包含未开放给AI的表
+func (h *AppHandler) ExportDictionary(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Query("id"), 10, 64)
+	if err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+	format := c.Query("format", "docx")
+	if format != "docx" && format != "markdown" {
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	dataSource, err := h.dataSourceService.Get(c.Context(), id)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+	content, err := h.dataSourceService.ExportDictionary(c.Context(), dataSource, c.QueryInt("all") == 1)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionExportDictionary, c.IP(), c.Get("User-Agent"))
+
+	fileName := "data_dictionary_" + strconv.FormatUint(dataSource.ID, 10) + "_" + time.Now().Format("20060102150405")
+	if format == "markdown" {
+		c.Set("Content-Disposition", "attachment; filename="+fileName+".md")
+		c.Set("Content-Type", "text/markdown; charset=utf-8")
+		return c.Status(fiber.StatusOK).SendString(content)
+	}
+
+	// ER图通过mermaid渲染服务转为图片，渲染失败时保留代码
+	buf, err := docgen.NewTechnicalDocGenerator().RenderString(content)
+	if err != nil {
+		logger.Error("生成数据字典文档失败", logger.F("err", err))
+		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrInternalError))
+	}
+	c.Set("Content-Disposition", "attachment; filename="+fileName+".docx")
+	c.Set("Content-Type", "application/vnd.openxmlformats-officedocument.wordprocessingml.document")
+	return c.Status(fiber.StatusOK).Send(buf)
+}
+
 //endregion
 
 ///////////////////////////////////////////////////////////////////
@@ -556,3 +1239,53 @@ func (h *AppHandler) GetApplicationUsageList(c *fiber.Ctx) error {
 }
 
 //endregion
+
+///////////////////////////////////////////////////////////////////
+//////////               SQL Audit                       //////////
+//region///////////////////////////////////////////////////////////
+
+// ListSqlAudits 查询智能体执行SQL的审计记录
+func (h *AppHandler) ListSqlAudits(c *fiber.Ctx) error {
+	var condition service.SqlAuditCondition
+	if err := c.QueryParser(&condition); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	offset := c.QueryInt("offset", 0)
+	limit := c.QueryInt("limit", service.DefaultPageSize)
+	if limit > service.MaxPageSize {
+		limit = service.MaxPageSize
+	}
+
+	list, total, err := h.sqlAuditService.Query(c.Context(), &condition, offset, limit)
+	if err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	return c.JSON(service.OK(service.NewListResponse(list, total, offset, limit)))
+}
+
+// ExportSqlAudits 按条件导出SQL审计记录为CSV
+func (h *AppHandler) ExportSqlAudits(c *fiber.Ctx) error {
+	var condition service.SqlAuditCondition
+	if err := c.QueryParser(&condition); err != nil {
+		logger.Error("请求参数解析失败", logger.F("err", err))
+		return c.Status(fiber.StatusBadRequest).JSON(service.Error(constant.ErrInvalidParams))
+	}
+
+	var buf bytes.Buffer
+	if err := h.sqlAuditService.Export(c.Context(), &condition, &buf); err != nil {
+		return c.Status(constant.GetErrorCode(err)).JSON(service.Error(err))
+	}
+
+	// 记录操作日志
+	user := c.Locals("user").(*model.User)
+	go h.logService.CreateOperationLog(c.Context(), user.ID, constant.LogActionExportSqlAudit, c.IP(), c.Get("User-Agent"))
+
+	c.Set("Content-Disposition", "attachment; filename=sql_audit_"+time.Now().Format("20060102150405")+".csv")
+	c.Set("Content-Type", "text/csv; charset=utf-8")
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+//endregion
@@ -136,7 +136,7 @@ func (h *ChatHandler) SendMessage(c *fiber.Ctx) error {
 
 	apiSecret := ""
 	if appAgent != nil {
-		apiSecret = appAgent.Agent.ApiSecret
+		apiSecret = string(appAgent.Agent.ApiSecret)
 	}
 
 	c.Set("Content-Type", "text/event-stream")
@@ -216,7 +216,7 @@ func (h *ChatHandler) StopChatFlow(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrInternalError))
 	}
 
-	err = chatClient.StopStreamingChat(req.TaskID, strconv.FormatUint(user.ID, 10), appAgent.Agent.ApiSecret)
+	err = chatClient.StopStreamingChat(req.TaskID, strconv.FormatUint(user.ID, 10), string(appAgent.Agent.ApiSecret))
 	if err != nil {
 		logger.Error("停止会话失败", logger.F("err", err))
 		return c.Status(fiber.StatusInternalServerError).JSON(service.Error(constant.ErrInternalError))
@@ -268,7 +268,7 @@ func (h *ChatHandler) GetSessionList(c *fiber.Ctx) error {
 	}
 	apiSecret := ""
 	if appAgent != nil {
-		apiSecret = appAgent.Agent.ApiSecret
+		apiSecret = string(appAgent.Agent.ApiSecret)
 	}
 
 	list, err := chatClient.GetConversations(strconv.FormatUint(user.ID, 10), apiSecret)
@@ -326,7 +326,7 @@ func (h *ChatHandler) GetSessionHistory(c *fiber.Ctx) error {
 	}
 	apiSecret := ""
 	if appAgent != nil {
-		apiSecret = appAgent.Agent.ApiSecret
+		apiSecret = string(appAgent.Agent.ApiSecret)
 	}
 
 	conversationID := c.Query("conversation_id")
@@ -464,7 +464,7 @@ func (h *ChatHandler) UploadFile(c *fiber.Ctx) error {
 		}
 		apiSecret := ""
 		if appAgent != nil {
-			apiSecret = appAgent.Agent.ApiSecret
+			apiSecret = string(appAgent.Agent.ApiSecret)
 		}
 
 		files := form.File["files"]
@@ -548,7 +548,7 @@ func (h *ChatHandler) DeleteConversation(c *fiber.Ctx) error {
 	}
 	apiSecret := ""
 	if appAgent != nil {
-		apiSecret = appAgent.Agent.ApiSecret
+		apiSecret = string(appAgent.Agent.ApiSecret)
 	}
 
 	_, err = chatClient.DeleteConversation(req.ConversationID, strconv.FormatUint(user.ID, 10), apiSecret)
@@ -31,6 +31,33 @@ var (
 
 	// 字典错误
 	ErrDictNotConfigured = errors.New("字典未配置")
+
+	// SQL相关错误
+	ErrSqlNotAllowed       = errors.New("SQL语句不允许执行")
+	ErrRowFilterVarMissing = errors.New("缺少行级权限变量")
+	ErrInvalidCursor       = errors.New("续查游标无效")
+	ErrQueryTimeout        = errors.New("SQL执行超时")
+	ErrQueryParamInvalid   = errors.New("查询参数错误")
+	ErrRowTooLarge         = errors.New("单行结果超过返回大小限制，请减少查询的列或截取长字段")
+	ErrSqlBuilderNotReady  = errors.New("SQL构建器未配置")
+	ErrSqlBuilderFailed    = errors.New("SQL构建器调用失败")
+
+	// 数据源相关错误
+	ErrPasswordRequired = errors.New("连接参数已修改，请输入密码")
+
+	// 数据导入相关错误
+	ErrUnsupportedFileType   = errors.New("不支持的文件格式")
+	ErrImportEmpty           = errors.New("导入文件没有数据")
+	ErrImportTooManyRows     = errors.New("导入数据行数超过限制")
+	ErrGlossaryHeaderMissing = errors.New("导入文件缺少表名列")
+
+	// 文件下载相关错误
+	ErrFileNotFound        = errors.New("文件不存在或已清理")
+	ErrDownloadLinkInvalid = errors.New("下载链接无效")
+	ErrDownloadLinkExpired = errors.New("下载链接已过期")
+
+	// 图表相关错误
+	ErrChartSpecInvalid = errors.New("图表参数错误")
 )
 
 // 获取错误对应的HTTP状态码
@@ -80,6 +107,36 @@ func GetErrorCode(err error) int {
 	case ErrDictNotConfigured:
 		return http.StatusInternalServerError
 
+	// SQL相关错误
+	case ErrSqlNotAllowed, ErrRowFilterVarMissing, ErrInvalidCursor, ErrQueryParamInvalid, ErrRowTooLarge:
+		return http.StatusBadRequest
+	case ErrQueryTimeout:
+		return http.StatusRequestTimeout
+	case ErrSqlBuilderNotReady:
+		return http.StatusInternalServerError
+	case ErrSqlBuilderFailed:
+		return http.StatusBadGateway
+
+	// 数据源相关错误
+	case ErrPasswordRequired:
+		return http.StatusBadRequest
+
+	// 数据导入相关错误
+	case ErrUnsupportedFileType, ErrImportEmpty, ErrImportTooManyRows, ErrGlossaryHeaderMissing:
+		return http.StatusBadRequest
+
+	// 文件下载相关错误
+	case ErrFileNotFound:
+		return http.StatusNotFound
+	case ErrDownloadLinkInvalid:
+		return http.StatusForbidden
+	case ErrDownloadLinkExpired:
+		return http.StatusGone
+
+	// 图表相关错误
+	case ErrChartSpecInvalid:
+		return http.StatusBadRequest
+
 	default:
 		return http.StatusInternalServerError
 	}
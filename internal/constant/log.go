@@ -34,6 +34,8 @@ const (
 	LogActionUpdateColumnInfo
 	LogActionDeleteTableInfo
 	LogActionDeleteColumnInfo
+	LogActionImportDataSource
+	LogActionResolveSyncReport
 )
 
 const (
@@ -41,3 +43,19 @@ const (
 	LogActionUpdateDict
 	LogActionDeleteDict
 )
+
+const (
+	LogActionImportGlossary = 61 + iota
+	LogActionExportSqlAudit
+	LogActionCreateSavedQuery
+	LogActionUpdateSavedQuery
+	LogActionDeleteSavedQuery
+	LogActionCreateSqlExample
+	LogActionUpdateSqlExample
+	LogActionDeleteSqlExample
+	LogActionPromoteSqlExample
+	LogActionPublishDictionary
+	LogActionUnpublishDictionary
+	LogActionExportDictionary
+	LogActionConfirmPii
+)
@@ -0,0 +1,114 @@
+package datasource
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/tabular"
+	"gorm.io/gorm"
+)
+
+// importMaxParams 每条INSERT语句的参数个数上限，低于SQLite的参数数量限制
+const importMaxParams = 2000
+
+// Materialize 将表格数据写入SQLite数据源文件：先写入临时文件再替换，
+// 替换后关闭该数据源的旧连接池，后续查询使用新文件
+func Materialize(id uint64, name string, sheets []*tabular.Sheet) error {
+	path, err := SQLitePath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".importing"
+	_ = os.Remove(tmp)
+
+	if err := writeSQLite(tmp, sheets); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	Invalidate(id)
+	return nil
+}
+
+func writeSQLite(path string, sheets []*tabular.Sheet) error {
+	db, err := gorm.Open(sqlite.Open("file:"+path+"?_pragma=journal_mode(OFF)&_pragma=synchronous(OFF)"), &gorm.Config{})
+	if err != nil {
+		logger.Error("创建 SQLite 文件失败", logger.F("path", path), logger.F("err", err))
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf("CREATE TABLE %s (table_name TEXT NOT NULL, column_name TEXT, comment TEXT NOT NULL)", quoteIdent(SQLiteCommentTable))).Error; err != nil {
+			return err
+		}
+		for _, sheet := range sheets {
+			if err := writeSheet(tx, sheet); err != nil {
+				return fmt.Errorf("%s: %w", sheet.Title, err)
+			}
+		}
+		return nil
+	})
+}
+
+func writeSheet(tx *gorm.DB, sheet *tabular.Sheet) error {
+	defs := make([]string, len(sheet.Columns))
+	names := make([]string, len(sheet.Columns))
+	for i, c := range sheet.Columns {
+		names[i] = quoteIdent(c.Name)
+		defs[i] = names[i] + " " + c.Type
+	}
+	table := quoteIdent(sheet.Name)
+	if err := tx.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", table, strings.Join(defs, ", "))).Error; err != nil {
+		return err
+	}
+
+	// 以原始名称作为注释
+	commentSQL := fmt.Sprintf("INSERT INTO %s (table_name, column_name, comment) VALUES (?, ?, ?)", quoteIdent(SQLiteCommentTable))
+	if err := tx.Exec(commentSQL, sheet.Name, nil, sheet.Title).Error; err != nil {
+		return err
+	}
+	for _, c := range sheet.Columns {
+		if c.Header == "" {
+			continue
+		}
+		if err := tx.Exec(commentSQL, sheet.Name, c.Name, c.Header).Error; err != nil {
+			return err
+		}
+	}
+
+	placeholder := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ") + ")"
+	batch := max(1, importMaxParams/len(names))
+	for start := 0; start < len(sheet.Rows); start += batch {
+		rows := sheet.Rows[start:min(start+batch, len(sheet.Rows))]
+		values := make([]string, len(rows))
+		args := make([]interface{}, 0, len(rows)*len(names))
+		for i, row := range rows {
+			values[i] = placeholder
+			args = append(args, row...)
+		}
+		insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", table, strings.Join(names, ", "), strings.Join(values, ", "))
+		if err := tx.Exec(insertSQL, args...).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
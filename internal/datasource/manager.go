@@ -1,9 +1,23 @@
 package datasource
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/config"
 	"github.com/yockii/dify_tools/pkg/logger"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
@@ -11,50 +25,480 @@ import (
 )
 
 var mgr = &manager{
-	dbMap: make(map[uint64]*gorm.DB),
+	pools: make(map[uint64]*pool),
 }
 
 type manager struct {
-	dbMap map[uint64]*gorm.DB
+	mu    sync.RWMutex
+	pools map[uint64]*pool
+
+	janitorOnce sync.Once
+	stop        chan struct{}
+}
+
+// pool 单个数据源的连接池
+type pool struct {
+	db          *gorm.DB
+	fingerprint string // 连接参数摘要，参数变化后需重建连接池
+	lastUsed    time.Time
+	mu          sync.Mutex
+}
+
+func (p *pool) touch() {
+	p.mu.Lock()
+	p.lastUsed = time.Now()
+	p.mu.Unlock()
 }
 
+func (p *pool) idleSince() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastUsed
+}
+
+func (p *pool) close() {
+	if sqlDB, err := p.db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// PoolStats 连接池状态
+type PoolStats struct {
+	DataSourceID    uint64    `json:"dataSourceId,string"`
+	OpenConnections int       `json:"openConnections"`
+	InUse           int       `json:"inUse"`
+	Idle            int       `json:"idle"`
+	WaitCount       int64     `json:"waitCount"`
+	LastUsed        time.Time `json:"lastUsed"`
+}
+
+// GetDB 获取数据源的连接池，不存在或连接参数已变化时重新创建
 func GetDB(ds *model.DataSource) (*gorm.DB, error) {
-	if db, has := mgr.dbMap[ds.ID]; has {
-		return db, nil
+	mgr.startJanitor()
+	fp := fingerprint(ds)
+
+	mgr.mu.RLock()
+	p, has := mgr.pools[ds.ID]
+	mgr.mu.RUnlock()
+	if has && p.fingerprint == fp {
+		p.touch()
+		return p.db, nil
 	}
-	// 根据ds信息创建新的db
+
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	// 等待锁期间可能已被其他请求创建
+	if p, has := mgr.pools[ds.ID]; has {
+		if p.fingerprint == fp {
+			p.touch()
+			return p.db, nil
+		}
+		p.close()
+		delete(mgr.pools, ds.ID)
+	}
+
 	db, err := createNewConnection(ds)
 	if err != nil {
 		return nil, err
 	}
-	mgr.dbMap[ds.ID] = db
+	p = &pool{db: db, fingerprint: fp, lastUsed: time.Now()}
+	mgr.pools[ds.ID] = p
 	return db, nil
 }
 
+// Invalidate 关闭并移除数据源的连接池，数据源修改或删除后调用
+func Invalidate(id uint64) {
+	mgr.mu.Lock()
+	p, has := mgr.pools[id]
+	delete(mgr.pools, id)
+	mgr.mu.Unlock()
+	if has {
+		p.close()
+		logger.Info("数据源连接池已关闭", logger.F("dataSourceId", id))
+	}
+}
+
+// CloseAll 关闭所有连接池并停止后台清理，服务关闭时调用
+func CloseAll() {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	if mgr.stop != nil {
+		close(mgr.stop)
+		mgr.stop = nil
+	}
+	for id, p := range mgr.pools {
+		p.close()
+		delete(mgr.pools, id)
+	}
+}
+
+// Stats 获取当前所有连接池的状态
+func Stats() []PoolStats {
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+	stats := make([]PoolStats, 0, len(mgr.pools))
+	for id, p := range mgr.pools {
+		st := PoolStats{DataSourceID: id, LastUsed: p.idleSince()}
+		if sqlDB, err := p.db.DB(); err == nil {
+			s := sqlDB.Stats()
+			st.OpenConnections = s.OpenConnections
+			st.InUse = s.InUse
+			st.Idle = s.Idle
+			st.WaitCount = s.WaitCount
+		}
+		stats = append(stats, st)
+	}
+	return stats
+}
+
+// TestResult 连接测试结果
+type TestResult struct {
+	Success bool   `json:"success"`
+	Latency int64  `json:"latency"` // 毫秒
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Test 使用临时连接测试数据源配置是否可用，不影响已有连接池
+func Test(ctx context.Context, ds *model.DataSource) *TestResult {
+	start := time.Now()
+	result := new(TestResult)
+	db, err := createNewConnection(ds)
+	if err != nil {
+		result.Error = err.Error()
+		result.Latency = time.Since(start).Milliseconds()
+		return result
+	}
+	defer func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.GetInt("datasource.test_timeout"))*time.Second)
+	defer cancel()
+	versionSQL := "SELECT version()"
+	if ds.Type == "sqlite" {
+		versionSQL = "SELECT sqlite_version()"
+	}
+	err = db.WithContext(ctx).Raw(versionSQL).Scan(&result.Version).Error
+	result.Latency = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Success = true
+	return result
+}
+
+// startJanitor 启动后台清理，定期关闭长时间未使用及健康检查失败的连接池
+func (m *manager) startJanitor() {
+	m.janitorOnce.Do(func() {
+		m.mu.Lock()
+		m.stop = make(chan struct{})
+		stop := m.stop
+		m.mu.Unlock()
+
+		interval := time.Duration(config.GetInt("datasource.health_check_interval")) * time.Second
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					m.evict()
+				}
+			}
+		}()
+	})
+}
+
+func (m *manager) evict() {
+	idleTimeout := time.Duration(config.GetInt("datasource.idle_timeout")) * time.Second
+
+	m.mu.RLock()
+	candidates := make(map[uint64]*pool, len(m.pools))
+	for id, p := range m.pools {
+		candidates[id] = p
+	}
+	m.mu.RUnlock()
+
+	for id, p := range candidates {
+		reason := ""
+		if idleTimeout > 0 && time.Since(p.idleSince()) > idleTimeout {
+			reason = "长时间未使用"
+		} else if sqlDB, err := p.db.DB(); err != nil {
+			reason = "获取连接失败"
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			err = sqlDB.PingContext(ctx)
+			cancel()
+			if err != nil {
+				reason = "健康检查失败"
+				logger.Warn("数据源健康检查失败", logger.F("dataSourceId", id), logger.F("err", err))
+			}
+		}
+		if reason == "" {
+			continue
+		}
+
+		m.mu.Lock()
+		// 期间可能已被替换
+		if m.pools[id] == p {
+			delete(m.pools, id)
+		} else {
+			p = nil
+		}
+		m.mu.Unlock()
+		if p != nil {
+			p.close()
+			logger.Info("关闭数据源连接池", logger.F("dataSourceId", id), logger.F("reason", reason))
+		}
+	}
+}
+
+// fingerprint 计算连接参数摘要
+func fingerprint(ds *model.DataSource) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s|%s|%d|%s|%s|%s|%s|%d|%d",
+		ds.Type, ds.Host, ds.Port, ds.User, ds.Password, ds.Database, ds.Schema, ds.MaxOpenConns, ds.MaxIdleConns))
+	return hex.EncodeToString(sum[:])
+}
+
 func createNewConnection(ds *model.DataSource) (*gorm.DB, error) {
+	var db *gorm.DB
+	var err error
 	// 创建新的数据库连接
 	switch ds.Type {
 	case "mysql":
 		// 创建 MySQL 连接
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local&timeout=10s",
 			ds.User, ds.Password, ds.Host, ds.Port, ds.Database)
-		db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
 		if err != nil {
 			logger.Error("创建 MySQL 连接失败", logger.F("err", err))
 			return nil, err
 		}
-		return db, nil
 	case "postgres":
-		// 创建 Postgres 连接
-		dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		// 创建 Postgres 连接，会话默认只读
+		dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable connect_timeout=10 default_transaction_read_only=on",
 			ds.Host, ds.Port, ds.User, ds.Password, ds.Database)
-		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
 		if err != nil {
 			logger.Error("创建 Postgres 连接失败", logger.F("err", err))
 			return nil, err
 		}
-		return db, nil
+	case "sqlite":
+		// SQLite 以只读方式打开，文件须位于数据目录下
+		path, err := SQLitePath(ds.Database)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			logger.Error("SQLite 文件不存在", logger.F("path", path), logger.F("err", err))
+			return nil, err
+		}
+		dsn := "file:" + path + "?mode=ro&_pragma=query_only(1)&_pragma=busy_timeout(5000)"
+		db, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+		if err != nil {
+			logger.Error("创建 SQLite 连接失败", logger.F("err", err))
+			return nil, err
+		}
+	default:
+		logger.Warn("不支持的数据库类型", logger.F("type", ds.Type))
+		return nil, fmt.Errorf("unsupported database type: %s", ds.Type)
+	}
+
+	// 设置连接池
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	maxOpen, maxIdle := ds.MaxOpenConns, ds.MaxIdleConns
+	if maxOpen <= 0 {
+		maxOpen = config.GetInt("datasource.max_open_conns")
+	}
+	if maxIdle <= 0 {
+		maxIdle = config.GetInt("datasource.max_idle_conns")
+	}
+	if maxOpen > 0 {
+		maxIdle = min(maxIdle, maxOpen)
+	}
+	sqlDB.SetMaxOpenConns(maxOpen)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	sqlDB.SetConnMaxLifetime(time.Duration(config.GetInt("datasource.conn_max_lifetime")) * time.Second)
+	sqlDB.SetConnMaxIdleTime(time.Duration(config.GetInt("datasource.conn_max_idle_time")) * time.Second)
+	return db, nil
+}
+
+// SQLiteCommentTable SQLite数据源中保存表及列注释的附加表，
+// 结构为 (table_name TEXT, column_name TEXT, comment TEXT)，column_name 为空表示表注释
+const SQLiteCommentTable = "_dify_comments"
+
+// SQLitePath 获取SQLite文件的绝对路径，相对路径基于 datasource.sqlite_dir，且不能位于该目录之外
+func SQLitePath(name string) (string, error) {
+	dir, err := filepath.Abs(config.GetString("datasource.sqlite_dir"))
+	if err != nil {
+		return "", err
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path = filepath.Clean(path)
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("sqlite file must be inside %s: %s", dir, name)
+	}
+	return path, nil
+}
+
+// QueryOptions 查询限制
+type QueryOptions struct {
+	Timeout time.Duration // 执行超时，0为不限制
+	Offset  int           // 跳过的行数
+	MaxRows int           // 最多返回的行数，0为不限制
+}
+
+// QueryReadOnly 在只读事务中执行查询，执行完毕后总是回滚，
+// 超过 MaxRows 的结果会被丢弃，此时 hasMore 为 true
+func QueryReadOnly(ctx context.Context, ds *model.DataSource, opts QueryOptions, query string, args ...interface{}) (result []map[string]interface{}, hasMore bool, err error) {
+	hasMore, err = ScanReadOnly(ctx, ds, opts, nil, func(row map[string]interface{}) error {
+		result = append(result, row)
+		return nil
+	}, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	return result, hasMore, nil
+}
+
+// ScanReadOnly 与 QueryReadOnly 相同，但逐行交给 onRow 处理而不保留结果；onColumns 不为nil时在读取前以结果列的顺序调用一次，
+// 回调返回错误时停止读取
+func ScanReadOnly(ctx context.Context, ds *model.DataSource, opts QueryOptions, onColumns func(columns []string) error, onRow func(row map[string]interface{}) error, query string, args ...interface{}) (hasMore bool, err error) {
+	db, err := GetDB(ds)
+	if err != nil {
+		return false, err
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	// 提前结束读取时取消查询，避免驱动读完剩余结果
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	tx := db.WithContext(ctx).Begin(&sql.TxOptions{ReadOnly: true})
+	if tx.Error != nil {
+		logger.Error("开启只读事务失败", logger.F("err", tx.Error))
+		return false, tx.Error
+	}
+	defer tx.Rollback()
+
+	if opts.Timeout > 0 {
+		setStatementTimeout(tx, ds.Type, opts.Timeout)
+	}
+
+	rows, err := tx.Raw(query, args...).Rows()
+	if err != nil {
+		return false, wrapTimeout(ctx, err)
+	}
+	defer rows.Close()
+	if onColumns != nil {
+		columns, err := rows.Columns()
+		if err != nil {
+			return false, err
+		}
+		if err := onColumns(columns); err != nil {
+			return false, err
+		}
+	}
+
+	count := 0
+	for n := 0; rows.Next(); n++ {
+		if n < opts.Offset {
+			continue
+		}
+		if opts.MaxRows > 0 && count >= opts.MaxRows {
+			hasMore = true
+			stop()
+			break
+		}
+		row := make(map[string]interface{})
+		if err := tx.ScanRows(rows, &row); err != nil {
+			return false, err
+		}
+		if err := onRow(row); err != nil {
+			stop()
+			return false, err
+		}
+		count++
+	}
+	if !hasMore {
+		if err := rows.Err(); err != nil {
+			return false, wrapTimeout(ctx, err)
+		}
+	}
+	return hasMore, nil
+}
+
+// CountReadOnly 在只读事务中统计查询结果的总行数
+func CountReadOnly(ctx context.Context, ds *model.DataSource, timeout time.Duration, query string, args ...interface{}) (int64, error) {
+	db, err := GetDB(ds)
+	if err != nil {
+		return 0, err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	tx := db.WithContext(ctx).Begin(&sql.TxOptions{ReadOnly: true})
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	defer tx.Rollback()
+
+	if timeout > 0 {
+		setStatementTimeout(tx, ds.Type, timeout)
+	}
+	var total int64
+	if err := tx.Raw("SELECT COUNT(*) FROM ("+query+") AS count_query", args...).Scan(&total).Error; err != nil {
+		return 0, wrapTimeout(ctx, err)
+	}
+	return total, nil
+}
+
+// setStatementTimeout 设置数据库端的语句超时，作用于当前事务
+func setStatementTimeout(tx *gorm.DB, dbType string, timeout time.Duration) {
+	var err error
+	switch dbType {
+	case "postgres":
+		err = tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout.Milliseconds())).Error
+	case "mysql":
+		// 会话级设置，仅对SELECT生效，MySQL 5.7.8 及以上版本支持
+		err = tx.Exec(fmt.Sprintf("SET SESSION max_execution_time = %d", timeout.Milliseconds())).Error
+	}
+	if err != nil {
+		logger.Warn("设置语句超时失败", logger.F("type", dbType), logger.F("err", err))
+	}
+}
+
+// wrapTimeout 将超时（含数据库端语句超时）导致的错误转换为 context.DeadlineExceeded
+func wrapTimeout(ctx context.Context, err error) error {
+	var pgErr *pgconn.PgError
+	var mysqlErr *mysqldriver.MySQLError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded),
+		errors.As(err, &pgErr) && pgErr.Code == "57014",      // query_canceled
+		errors.As(err, &mysqlErr) && mysqlErr.Number == 3024: // ER_QUERY_TIMEOUT
+		return fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
 	}
-	logger.Warn("不支持的数据库类型", logger.F("type", ds.Type))
-	return nil, fmt.Errorf("unsupported database type: %s", ds.Type)
+	return err
 }
@@ -42,11 +42,11 @@ func (c *KnowledgeBaseClient) buildPostRequest(url string, body []byte) (*http.R
 	return req, nil
 }
 
+// CreateDocumentByText 通过文本创建文档，返回dify的原始响应，包含 document 及 batch
 func (c *KnowledgeBaseClient) CreateDocumentByText(ID, docName, docContent string, docMetadata map[string]string) (string, error) {
 	body := map[string]interface{}{
 		"name":               docName,
 		"text":               docContent,
-		"doc_metadata":       docMetadata,
 		"indexing_technique": "high_quality",
 		"doc_form":           "text_model", //"hierarchical_model",
 		"process_rule": map[string]interface{}{
@@ -59,12 +59,32 @@ func (c *KnowledgeBaseClient) CreateDocumentByText(ID, docName, docContent strin
 			"score_threshold_enabled": false,
 		},
 	}
+	if len(docMetadata) > 0 {
+		body["doc_type"] = "others"
+		body["doc_metadata"] = docMetadata
+	}
+	return c.postJSON(c.baseUrl+"/datasets/"+ID+"/document/create-by-text", body)
+}
+
+// UpdateDocumentByText 使用新的文本替换文档内容，返回dify的原始响应，包含 document 及 batch
+func (c *KnowledgeBaseClient) UpdateDocumentByText(ID, documentID, docName, docContent string) (string, error) {
+	body := map[string]interface{}{
+		"name": docName,
+		"text": docContent,
+		"process_rule": map[string]interface{}{
+			"mode": "automatic",
+		},
+	}
+	return c.postJSON(c.baseUrl+"/datasets/"+ID+"/documents/"+documentID+"/update-by-text", body)
+}
+
+func (c *KnowledgeBaseClient) postJSON(url string, body map[string]interface{}) (string, error) {
 	bodyBytes, err := json.Marshal(body)
 	if err != nil {
 		logger.Error("序列化请求参数失败", logger.F("err", err))
 		return "", err
 	}
-	req, err := c.buildPostRequest(c.baseUrl+"/datasets/"+ID+"/documents/create-by-text", bodyBytes)
+	req, err := c.buildPostRequest(url, bodyBytes)
 	if err != nil {
 		return "", err
 	}
@@ -82,9 +102,7 @@ func (c *KnowledgeBaseClient) CreateDocumentByText(ID, docName, docContent strin
 		logger.Error("读取响应失败", logger.F("err", err))
 		return "", err
 	}
-	respJson := gjson.ParseBytes(response)
-	docId := respJson.Get("batch").String()
-	return docId, nil
+	return string(response), nil
 }
 
 func (c *KnowledgeBaseClient) CreateDocumentByFile(ID string, fileHeader *multipart.FileHeader, docMetadata map[string]string) (string, error) {
@@ -0,0 +1,60 @@
+package dify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/yockii/dify_tools/pkg/logger"
+)
+
+type WorkflowRunRequest struct {
+	Inputs       map[string]interface{} `json:"inputs"`
+	ResponseMode string                 `json:"response_mode"` // streaming/blocking，目前只处理blocking
+	User         string                 `json:"user"`
+}
+
+// RunWorkflow 以阻塞模式执行工作流，返回完整响应，输出在 data.outputs 中
+func (c *ChatClient) RunWorkflow(req *WorkflowRunRequest, apiSecret string) ([]byte, error) {
+	req.ResponseMode = "blocking"
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		logger.Error("序列化请求体失败", logger.F("err", err))
+		return nil, err
+	}
+	httpReq, err := http.NewRequest("POST", c.baseUrl+"/workflows/run", bytes.NewBuffer(reqBody))
+	if err != nil {
+		logger.Error("创建请求失败", logger.F("err", err))
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if apiSecret != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+apiSecret)
+	} else if c.defaultAPISecret != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.defaultAPISecret)
+	} else {
+		logger.Error("未提供API密钥")
+		return nil, fmt.Errorf("未提供API密钥")
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		logger.Error("发送请求失败", logger.F("err", err))
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Error("读取响应失败", logger.F("err", err))
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		logger.Error("API返回错误", logger.F("statusCode", resp.StatusCode), logger.F("response", string(body)))
+		return nil, fmt.Errorf("API错误: %d, %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"gorm.io/gorm"
 
+	"github.com/yockii/dify_tools/pkg/secret"
 	"github.com/yockii/dify_tools/pkg/util"
 )
 
@@ -35,18 +36,25 @@ func (a *Application) BeforeCreate(tx *gorm.DB) error {
 // DataSource 数据源模型
 type DataSource struct {
 	BaseModel
-	ApplicationID uint64    `json:"applicationId,string" gorm:"index;not null"`
-	Name          string    `json:"name" gorm:"type:varchar(50);not null"`
-	Type          string    `json:"type" gorm:"type:varchar(20);not null"` // mysql, postgres, etc.
-	Host          string    `json:"host" gorm:"type:varchar(50);not null"`
-	Port          int       `json:"port" gorm:"type:int;not null"`
-	User          string    `json:"user" gorm:"type:varchar(50);not null"`
-	Password      string    `json:"password" gorm:"type:varchar(50);not null"`
-	Database      string    `json:"database" gorm:"type:varchar(50);not null"`
-	Schema        string    `json:"schema" gorm:"type:varchar(50);default:public"` // 数据库schema
-	SyncTime      time.Time `json:"syncTime,omitzero" gorm:"type:timestamp"`
-	Status        int       `json:"status" gorm:"type:int;default:1;not null"` // 1: 正常, -1: 禁用
-	UpdatedAt     time.Time `json:"updatedAt,omitzero" gorm:"type:timestamp;not null"`
+	ApplicationID uint64        `json:"applicationId,string" gorm:"index;not null"`
+	Name          string        `json:"name" gorm:"type:varchar(50);not null"`
+	Type          string        `json:"type" gorm:"type:varchar(20);not null"` // mysql, postgres, sqlite
+	Host          string        `json:"host" gorm:"type:varchar(50);not null"`
+	Port          int           `json:"port" gorm:"type:int;not null"`
+	User          string        `json:"user" gorm:"type:varchar(50);not null"`
+	Password      secret.String `json:"-" gorm:"type:varchar(500);not null"` // 加密存储，不对外输出
+	Database      string        `json:"database" gorm:"type:varchar(50);not null"`
+	Schema        string        `json:"schema" gorm:"type:varchar(50);default:public"`   // 数据库schema
+	QueryTimeout  int           `json:"queryTimeout,omitzero" gorm:"type:int;default:0"` // 单条SQL执行超时，单位：秒，0为使用全局配置
+	MaxRows       int           `json:"maxRows,omitzero" gorm:"type:int;default:0"`      // 单次返回的最大行数，0为使用全局配置
+	MaxBytes      int           `json:"maxBytes,omitzero" gorm:"type:int;default:0"`     // 单次返回结果的最大字节数，0为使用全局配置
+	MaxOpenConns  int           `json:"maxOpenConns,omitzero" gorm:"type:int;default:0"` // 连接池最大连接数，0为使用全局配置
+	MaxIdleConns  int           `json:"maxIdleConns,omitzero" gorm:"type:int;default:0"` // 连接池最大空闲连接数，0为使用全局配置
+	Profiling     int           `json:"profiling,omitzero" gorm:"type:int;default:-1"`   // 1: 同步时采样分析列的取值, -1: 不分析
+	PublishDict   int           `json:"publishDict,omitzero" gorm:"type:int;default:-1"` // 1: 同步后将数据字典发布到应用公共知识库, -1: 不发布
+	SyncTime      time.Time     `json:"syncTime,omitzero" gorm:"type:timestamp"`
+	Status        int           `json:"status" gorm:"type:int;default:1;not null"` // 1: 正常, -1: 禁用
+	UpdatedAt     time.Time     `json:"updatedAt,omitzero" gorm:"type:timestamp;not null"`
 }
 
 func (d *DataSource) TableComment() string {
@@ -64,11 +72,20 @@ func (d *DataSource) BeforeCreate(tx *gorm.DB) error {
 // TableInfo 表信息
 type TableInfo struct {
 	BaseModel
-	ApplicationID uint64    `json:"applicationId,string,omitzero" gorm:"index;not null"`
-	DataSourceID  uint64    `json:"dataSourceId,string,omitzero" gorm:"index;not null"`
-	Name          string    `json:"name" gorm:"type:varchar(50);not null"`
-	Comment       string    `json:"comment" gorm:"type:varchar(200)"`
-	UpdatedAt     time.Time `json:"updatedAt,omitzero" gorm:"type:timestamp;not null"`
+	ApplicationID    uint64    `json:"applicationId,string,omitzero" gorm:"index;not null"`
+	DataSourceID     uint64    `json:"dataSourceId,string,omitzero" gorm:"index;not null"`
+	Name             string    `json:"name" gorm:"type:varchar(64);not null"`
+	Comment          string    `json:"comment" gorm:"type:varchar(200)"`
+	SourceComment    string    `json:"sourceComment,omitempty" gorm:"type:varchar(200)"`            // 最近一次同步时数据库中的注释，与 Comment 不同表示注释经过人工修改
+	Kind             string    `json:"kind,omitempty" gorm:"type:varchar(10);default:table"`        // table: 表, view: 视图
+	BusinessName     string    `json:"businessName,omitempty" gorm:"type:varchar(100)"`             // 业务名称
+	Description      string    `json:"description,omitempty" gorm:"type:varchar(1000)"`             // 业务说明，如统计口径、数据范围
+	Synonyms         string    `json:"synonyms,omitempty" gorm:"type:varchar(500)"`                 // 同义词，多个以逗号分隔，用于按问题检索相关表
+	ExampleQuestions []string  `json:"exampleQuestions,omitempty" gorm:"type:text;serializer:json"` // 可以用该表回答的示例问题
+	Indexes          []Index   `json:"indexes,omitempty" gorm:"type:text;serializer:json"`          // 索引（不含主键）
+	ExposedToAI      int       `json:"exposedToAi,omitzero" gorm:"type:int;default:-1;not null"`    // 1: 开放给AI查询, -1: 不开放
+	RowFilter        string    `json:"rowFilter,omitempty" gorm:"type:varchar(500)"`                // 行级过滤条件，可引用请求变量，如 user_id = {{custom_id}}
+	UpdatedAt        time.Time `json:"updatedAt,omitzero" gorm:"type:timestamp;not null"`
 }
 
 func (t *TableInfo) TableComment() string {
@@ -83,21 +100,67 @@ func (t *TableInfo) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// Index 索引信息
+type Index struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique,omitempty"`
+}
+
 // ColumnInfo 列信息
 type ColumnInfo struct {
 	BaseModel
-	ApplicationID uint64    `json:"applicationId,string" gorm:"index;not null"`
-	DataSourceID  uint64    `json:"dataSourceId,string" gorm:"index;not null"`
-	TableID       uint64    `json:"tableId,string" gorm:"index;not null"`
-	Name          string    `json:"name" gorm:"type:varchar(50);not null"`
-	Type          string    `json:"type" gorm:"type:varchar(50);not null"`
-	Size          int64     `json:"size,string"`
-	Precision     int64     `json:"precision"`
-	Scale         int64     `json:"scale"`
-	Nullable      bool      `json:"nullable"`
-	DefaultValue  string    `json:"defaultValue"`
-	Comment       string    `json:"comment"`
-	UpdatedAt     time.Time `json:"updatedAt,omitzero" gorm:"type:timestamp;not null"`
+	ApplicationID uint64         `json:"applicationId,string" gorm:"index;not null"`
+	DataSourceID  uint64         `json:"dataSourceId,string" gorm:"index;not null"`
+	TableID       uint64         `json:"tableId,string" gorm:"index;not null"`
+	Name          string         `json:"name" gorm:"type:varchar(64);not null"`
+	Type          string         `json:"type" gorm:"type:varchar(50);not null"`
+	Size          int64          `json:"size,string"`
+	Precision     int64          `json:"precision"`
+	Scale         int64          `json:"scale"`
+	Nullable      bool           `json:"nullable"`
+	DefaultValue  string         `json:"defaultValue"`
+	Comment       string         `json:"comment"`
+	SourceComment string         `json:"sourceComment,omitempty"`                                 // 最近一次同步时数据库中的注释，同 TableInfo.SourceComment
+	PrimaryKey    bool           `json:"primaryKey,omitempty"`                                    // 是否为主键列
+	RefTable      string         `json:"refTable,omitempty" gorm:"type:varchar(64)"`              // 外键引用的表
+	RefColumn     string         `json:"refColumn,omitempty" gorm:"type:varchar(64)"`             // 外键引用的列
+	BusinessName  string         `json:"businessName,omitempty" gorm:"type:varchar(100)"`         // 业务名称
+	Description   string         `json:"description,omitempty" gorm:"type:varchar(1000)"`         // 业务说明
+	Synonyms      string         `json:"synonyms,omitempty" gorm:"type:varchar(500)"`             // 同义词，多个以逗号分隔
+	Unit          string         `json:"unit,omitempty" gorm:"type:varchar(20)"`                  // 单位，如 元、千克
+	EnumValues    []EnumValue    `json:"enumValues,omitempty" gorm:"type:text;serializer:json"`   // 枚举值含义，如 status=1 表示已支付
+	Profile       *ColumnProfile `json:"profile,omitempty" gorm:"type:text;serializer:json"`      // 同步时采样得到的取值分布，脱敏列不分析
+	MaskType      string         `json:"maskType,omitempty" gorm:"type:varchar(20);default:none"` // 脱敏类型: none, hide, partial, phone, id_card, hash, null
+	PiiType       string         `json:"piiType,omitempty" gorm:"type:varchar(20)"`               // 同步时识别的敏感信息类型: phone, id_card, email, bank_card, address, name
+	PiiStatus     int            `json:"piiStatus" gorm:"type:int;default:0;not null"`            // 敏感信息确认状态，见 PiiStatusPending 等
+	SuggestedMask string         `json:"suggestedMask,omitempty" gorm:"type:varchar(20)"`         // 建议的脱敏类型
+	UpdatedAt     time.Time      `json:"updatedAt,omitzero" gorm:"type:timestamp;not null"`
+}
+
+// 列的敏感信息识别状态，待确认的列不提供给AI且查询结果完全隐藏
+const (
+	PiiStatusUnchecked = 0  // 未识别
+	PiiStatusPending   = 1  // 识别为敏感信息，待管理员确认
+	PiiStatusConfirmed = 2  // 已确认为敏感信息并设置脱敏
+	PiiStatusDismissed = -1 // 未识别出敏感信息或管理员确认不是敏感信息
+)
+
+// EnumValue 枚举值及其业务含义
+type EnumValue struct {
+	Value   string `json:"value"`
+	Meaning string `json:"meaning"`
+}
+
+// ColumnProfile 列的取值分布，基于同步时读取的样本
+type ColumnProfile struct {
+	SampleRows int64     `json:"sampleRows"`       // 样本行数
+	NullRatio  float64   `json:"nullRatio"`        // 空值比例
+	Distinct   int64     `json:"distinct"`         // 样本中不同值的数量
+	Min        string    `json:"min,omitempty"`    // 最小值，仅数值及日期时间列
+	Max        string    `json:"max,omitempty"`    // 最大值，仅数值及日期时间列
+	Values     []string  `json:"values,omitempty"` // 不同值较少时的全部取值，按出现次数降序
+	ProfiledAt time.Time `json:"profiledAt"`
 }
 
 func (c *ColumnInfo) TableComment() string {
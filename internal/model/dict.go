@@ -3,7 +3,9 @@ package model
 import (
 	"strconv"
 
+	"github.com/yockii/dify_tools/internal/constant"
 	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/secret"
 	"github.com/yockii/dify_tools/pkg/util"
 	"gorm.io/gorm"
 )
@@ -12,9 +14,11 @@ type Dict struct {
 	BaseModel
 	Name     string `json:"name" gorm:"type:varchar(50);not null"`
 	Code     string `json:"code" gorm:"type:varchar(50);not null"`
-	Value    string `json:"value" gorm:"type:varchar(255);not null"`
+	Value    string `json:"value" gorm:"type:varchar(500);not null"`
 	ParentID uint64 `json:"parentId,string" gorm:"not null"`
 	Sort     int    `json:"sort" gorm:"type:int;default:0"`
+
+	plainValue *string // 保存时被加密或转义前的明文
 }
 
 func (d *Dict) ValueUint64() uint64 {
@@ -38,6 +42,59 @@ func (d *Dict) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// 需要加密存储的字典
+var secretDictCodes = map[string]bool{
+	constant.DictCodeDifyToken: true,
+}
+
+// BeforeSave 保存前钩子，敏感字典值加密存储，未配置主密钥时按 secret.Escape 转义后存储
+func (d *Dict) BeforeSave(tx *gorm.DB) error {
+	if d.Value == "" || !secret.Enabled() && secret.Escape(d.Value) == d.Value {
+		return nil
+	}
+	code := d.Code
+	if code == "" && d.ID != 0 {
+		// 按ID更新时可能未带上code
+		tx.Session(&gorm.Session{NewDB: true}).Model(&Dict{}).Where("id = ?", d.ID).Pluck("code", &code)
+	}
+	if !secretDictCodes[code] {
+		return nil
+	}
+	value := secret.Escape(d.Value)
+	if secret.Enabled() {
+		var err error
+		if value, err = secret.Encrypt(d.Value); err != nil {
+			return err
+		}
+	}
+	plain := d.Value
+	d.plainValue = &plain
+	tx.Statement.SetColumn("Value", value)
+	return nil
+}
+
+// AfterSave 保存后钩子，恢复为明文供调用方继续使用
+func (d *Dict) AfterSave(tx *gorm.DB) error {
+	if d.plainValue != nil {
+		d.Value, d.plainValue = *d.plainValue, nil
+	}
+	return nil
+}
+
+// AfterFind 查询后钩子，解密敏感字典值
+func (d *Dict) AfterFind(tx *gorm.DB) error {
+	// 只查询了value字段时无法判断是否为敏感字典，按密文前缀判断
+	if !secretDictCodes[d.Code] && (d.Code != "" || !secret.IsEncrypted(d.Value)) {
+		return nil
+	}
+	value, err := secret.Decrypt(d.Value)
+	if err != nil {
+		return err
+	}
+	d.Value = value
+	return nil
+}
+
 func init() {
 	models = append(models, &Dict{})
 }
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"github.com/yockii/dify_tools/pkg/secret"
 	"github.com/yockii/dify_tools/pkg/util"
 	"gorm.io/gorm"
 )
@@ -10,6 +11,12 @@ const (
 	InnerAgentCodeCommonChatFlow = "common_chat_flow"
 )
 
+// 智能体类型，决定调用dify的接口
+const (
+	AgentTypeChat     = 1 // 聊天助手或对话流，调用 /chat-messages
+	AgentTypeWorkflow = 2 // 工作流，调用 /workflows/run
+)
+
 const (
 	InnerAgentNameSqlBuilder     = "SQL构建器"
 	InnerAgentNameCommonChatFlow = "通用聊天流程"
@@ -21,9 +28,9 @@ type Agent struct {
 	Name string `json:"name" gorm:"type:varchar(100);not null"`
 	// 说明
 	Remark string `json:"remark" gorm:"type:varchar(100)"`
-	// 类型
-	Type      int    `json:"type" gorm:"type:int;not null"`
-	ApiSecret string `json:"apiSecret" gorm:"type:varchar(100);not null"`
+	// 类型 1: 聊天助手或对话流, 2: 工作流
+	Type      int           `json:"type" gorm:"type:int;not null"`
+	ApiSecret secret.String `json:"-" gorm:"type:varchar(500);not null"` // 加密存储，不对外输出
 }
 
 func (a *Agent) TableComment() string {
@@ -32,6 +32,9 @@ func (b *BaseModel) GetID() uint64 {
 var models []Model
 
 func AutoMigrate(db *gorm.DB) {
+	// 升级前已同步的表没有开放设置，迁移后默认不开放会导致现有查询全部被拒绝，保持升级前的行为
+	exposeExisting := db.Migrator().HasTable(&TableInfo{}) && !db.Migrator().HasColumn(&TableInfo{}, "ExposedToAI")
+
 	if dt := config.GetString("database.type"); dt == "mysql" {
 		migrator := db.Migrator()
 		for _, m := range models {
@@ -66,6 +69,15 @@ func AutoMigrate(db *gorm.DB) {
 	} else {
 		logger.Error("不支持的数据库类型", logger.F("type", dt))
 	}
+
+	if exposeExisting {
+		result := db.Model(&TableInfo{}).Where("1 = 1").Update("exposed_to_ai", 1)
+		if result.Error != nil {
+			logger.Error("开放已有表失败", logger.F("error", result.Error))
+		} else {
+			logger.Warn("已将升级前同步的表设置为开放给AI，请在表信息中关闭不允许查询的表", logger.F("count", result.RowsAffected))
+		}
+	}
 }
 
 func InitData(db *gorm.DB) error {
@@ -223,9 +235,24 @@ func InitData(db *gorm.DB) error {
 					{Name: "修改列信息", Value: 46, Code: "log_action_update_column_info"},
 					{Name: "删除表信息", Value: 47, Code: "log_action_delete_table_info"},
 					{Name: "删除列信息", Value: 48, Code: "log_action_delete_column_info"},
+					{Name: "导入数据源", Value: 49, Code: "log_action_import_data_source"},
+					{Name: "处理同步报告", Value: 50, Code: "log_action_resolve_sync_report"},
 					{Name: "创建字典", Value: 51, Code: "log_action_create_dict"},
 					{Name: "编辑字典", Value: 52, Code: "log_action_update_dict"},
 					{Name: "删除字典", Value: 53, Code: "log_action_delete_dict"},
+					{Name: "导入业务术语", Value: 61, Code: "log_action_import_glossary"},
+					{Name: "导出SQL审计", Value: 62, Code: "log_action_export_sql_audit"},
+					{Name: "创建预置查询", Value: 63, Code: "log_action_create_saved_query"},
+					{Name: "编辑预置查询", Value: 64, Code: "log_action_update_saved_query"},
+					{Name: "删除预置查询", Value: 65, Code: "log_action_delete_saved_query"},
+					{Name: "创建SQL示例", Value: 66, Code: "log_action_create_sql_example"},
+					{Name: "编辑SQL示例", Value: 67, Code: "log_action_update_sql_example"},
+					{Name: "删除SQL示例", Value: 68, Code: "log_action_delete_sql_example"},
+					{Name: "审计记录转为SQL示例", Value: 69, Code: "log_action_promote_sql_example"},
+					{Name: "发布数据字典", Value: 70, Code: "log_action_publish_dictionary"},
+					{Name: "撤回数据字典", Value: 71, Code: "log_action_unpublish_dictionary"},
+					{Name: "导出数据字典", Value: 72, Code: "log_action_export_dictionary"},
+					{Name: "确认敏感列", Value: 73, Code: "log_action_confirm_pii"},
 				}
 				for _, log := range logMap {
 					if err := tx.Where(&Dict{
@@ -262,6 +289,7 @@ func InitData(db *gorm.DB) error {
 				}{
 					{Name: "MySQL", Value: "mysql", Code: "datasource_type_mysql"},
 					{Name: "PostgreSQL", Value: "postgres", Code: "datasource_type_postgresql"},
+					{Name: "SQLite", Value: "sqlite", Code: "datasource_type_sqlite"},
 				}
 				for _, ds := range datasourceMap {
 					if err := tx.Where(&Dict{
@@ -343,6 +371,16 @@ func InitData(db *gorm.DB) error {
 				}).FirstOrCreate(&ApplicationAgent{}).Error; err != nil {
 					return fmt.Errorf("create application agent failed: %v", err)
 				}
+
+				// SQL构建器，密钥需在智能体管理中填写
+				if err := tx.Where(&Agent{
+					Code: InnerAgentCodeSqlBuilder,
+				}).Attrs(&Agent{
+					Name: InnerAgentNameSqlBuilder,
+					Type: AgentTypeWorkflow,
+				}).FirstOrCreate(&Agent{}).Error; err != nil {
+					return fmt.Errorf("create agent failed: %v", err)
+				}
 			}
 		}
 
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"github.com/yockii/dify_tools/pkg/util"
+	"gorm.io/gorm"
+)
+
+// 预置查询的参数类型
+const (
+	QueryParamString   = "string"
+	QueryParamInteger  = "integer"
+	QueryParamNumber   = "number"
+	QueryParamBoolean  = "boolean"
+	QueryParamDate     = "date"     // 2006-01-02
+	QueryParamDateTime = "datetime" // 2006-01-02 15:04:05
+)
+
+// SavedQuery 预置的参数化查询，SQL中以 {{参数名}} 引用参数，执行时按类型转换为常量后代入
+type SavedQuery struct {
+	BaseModel
+	ApplicationID uint64       `json:"applicationId,string" gorm:"index;not null"`
+	DataSourceID  uint64       `json:"dataSourceId,string" gorm:"index;not null"`
+	Name          string       `json:"name" gorm:"type:varchar(50);not null"`                   // 查询名称，同一数据源内唯一，供智能体调用
+	Description   string       `json:"description" gorm:"type:varchar(500)"`                    // 查询说明，告诉智能体何时使用
+	Sql           string       `json:"sql,omitempty" gorm:"column:sql_text;type:text;not null"` // 查询语句，参数不要加引号，如 WHERE region = {{region}}
+	Params        []QueryParam `json:"params,omitempty" gorm:"type:text;serializer:json"`       // 参数定义
+	UpdatedAt     time.Time    `json:"updatedAt,omitzero" gorm:"type:timestamp;not null"`
+}
+
+// QueryParam 预置查询的参数定义
+type QueryParam struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // string, integer, number, boolean, date, datetime
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Default     string `json:"default,omitempty"` // 未传入时使用的值，不必填且无默认值时代入NULL
+}
+
+func (q *SavedQuery) TableComment() string {
+	return "预置查询表"
+}
+
+// BeforeCreate 创建前钩子
+func (q *SavedQuery) BeforeCreate(tx *gorm.DB) error {
+	if q.ID == 0 {
+		q.ID = util.NewID()
+	}
+	return nil
+}
+
+func init() {
+	models = append(models, &SavedQuery{})
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/yockii/dify_tools/pkg/util"
+	"gorm.io/gorm"
+)
+
+// SchemaDocument 发布到应用公共知识库的数据字典文档，每张开放给AI的表一个文档
+type SchemaDocument struct {
+	BaseModel
+	ApplicationID   uint64    `json:"applicationId,string" gorm:"index;not null"`
+	DataSourceID    uint64    `json:"dataSourceId,string" gorm:"index;not null"`
+	TableID         uint64    `json:"tableId,string" gorm:"index;not null"`
+	KnowledgeBaseID uint64    `json:"knowledgeBaseId,string" gorm:"not null"`
+	TableName       string    `json:"tableName" gorm:"type:varchar(64);not null"`
+	OuterID         string    `json:"outerId" gorm:"type:varchar(50);not null"`          // dify中的文档ID
+	ContentHash     string    `json:"contentHash" gorm:"type:varchar(64);not null"`      // 文档内容的SHA-256，内容未变化时不重新上传
+	UpdatedAt       time.Time `json:"updatedAt,omitzero" gorm:"type:timestamp;not null"` // 最近一次上传时间
+}
+
+func (d *SchemaDocument) TableComment() string {
+	return "数据字典文档表"
+}
+
+// BeforeCreate 创建前钩子
+func (d *SchemaDocument) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == 0 {
+		d.ID = util.NewID()
+	}
+	return nil
+}
+
+func init() {
+	models = append(models, &SchemaDocument{})
+}
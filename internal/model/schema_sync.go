@@ -0,0 +1,85 @@
+package model
+
+import (
+	"time"
+
+	"github.com/yockii/dify_tools/pkg/util"
+	"gorm.io/gorm"
+)
+
+// 表结构同步的差异类型
+const (
+	SyncChangeAdded          = "added"
+	SyncChangeRemoved        = "removed"
+	SyncChangeTypeChanged    = "type_changed"
+	SyncChangeCommentChanged = "comment_changed"
+)
+
+// 差异项状态，只有删除需要人工确认
+const (
+	SyncItemStatusPending    = 0  // 待确认
+	SyncItemStatusApplied    = 1  // 已应用
+	SyncItemStatusRejected   = -1 // 已拒绝
+	SyncItemStatusSuperseded = 2  // 已失效，表/列在之后的同步中重新出现
+)
+
+// SchemaSyncReport 表结构同步报告，每次同步生成一条
+type SchemaSyncReport struct {
+	BaseModel
+	ApplicationID  uint64    `json:"applicationId,string" gorm:"index;not null"`
+	DataSourceID   uint64    `json:"dataSourceId,string" gorm:"index;not null"`
+	Added          int       `json:"added" gorm:"type:int;default:0;not null"`
+	Removed        int       `json:"removed" gorm:"type:int;default:0;not null"`
+	TypeChanged    int       `json:"typeChanged" gorm:"type:int;default:0;not null"`
+	CommentChanged int       `json:"commentChanged" gorm:"type:int;default:0;not null"`
+	Pending        int       `json:"pending" gorm:"type:int;default:0;not null"`    // 待确认的删除数量
+	Profiled       int       `json:"profiled" gorm:"type:int;default:0;not null"`   // 分析了取值分布的列数
+	Classified     int       `json:"classified" gorm:"type:int;default:0;not null"` // 识别为敏感信息待确认的列数
+	UpdatedAt      time.Time `json:"updatedAt,omitzero" gorm:"type:timestamp;not null"`
+
+	Items []*SchemaSyncItem `json:"items,omitempty" gorm:"-"`
+}
+
+func (r *SchemaSyncReport) TableComment() string {
+	return "表结构同步报告表"
+}
+
+// BeforeCreate 创建前钩子
+func (r *SchemaSyncReport) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == 0 {
+		r.ID = util.NewID()
+	}
+	return nil
+}
+
+// SchemaSyncItem 表结构同步差异项，ColumnName 为空表示表级别的差异
+type SchemaSyncItem struct {
+	BaseModel
+	ReportID     uint64    `json:"reportId,string" gorm:"index;not null"`
+	DataSourceID uint64    `json:"dataSourceId,string" gorm:"index;not null"`
+	TableID      uint64    `json:"tableId,string" gorm:"not null"`
+	ColumnID     uint64    `json:"columnId,string,omitzero" gorm:"not null;default:0"`
+	TableName    string    `json:"tableName" gorm:"type:varchar(64);not null"`
+	ColumnName   string    `json:"columnName,omitempty" gorm:"type:varchar(64)"`
+	ChangeType   string    `json:"changeType" gorm:"type:varchar(20);not null"` // added, removed, type_changed, comment_changed
+	OldValue     string    `json:"oldValue,omitempty" gorm:"type:varchar(500)"`
+	NewValue     string    `json:"newValue,omitempty" gorm:"type:varchar(500)"`
+	Status       int       `json:"status" gorm:"type:int;not null"` // 0: 待确认, 1: 已应用, -1: 已拒绝, 2: 已失效
+	UpdatedAt    time.Time `json:"updatedAt,omitzero" gorm:"type:timestamp;not null"`
+}
+
+func (i *SchemaSyncItem) TableComment() string {
+	return "表结构同步差异表"
+}
+
+// BeforeCreate 创建前钩子
+func (i *SchemaSyncItem) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == 0 {
+		i.ID = util.NewID()
+	}
+	return nil
+}
+
+func init() {
+	models = append(models, &SchemaSyncReport{}, &SchemaSyncItem{})
+}
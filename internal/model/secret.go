@@ -0,0 +1,77 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/secret"
+	"gorm.io/gorm"
+)
+
+// ReencryptSecrets 使用当前主密钥重新加密所有敏感字段（明文或使用旧主密钥加密的值），返回处理的记录数
+func ReencryptSecrets(db *gorm.DB) (int, error) {
+	if !secret.Enabled() {
+		return 0, secret.ErrKeyNotConfigured
+	}
+
+	total := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		targets := []struct {
+			model  interface{}
+			column string
+			scope  func(*gorm.DB) *gorm.DB
+		}{
+			{&DataSource{}, "password", nil},
+			{&Agent{}, "api_secret", nil},
+			{&Dict{}, "value", func(q *gorm.DB) *gorm.DB {
+				return q.Where("code = ?", constant.DictCodeDifyToken)
+			}},
+		}
+		for _, t := range targets {
+			n, err := reencryptColumn(tx, t.model, t.column, t.scope)
+			if err != nil {
+				return err
+			}
+			total += n
+		}
+		return nil
+	})
+	return total, err
+}
+
+// reencryptColumn 重新加密单个字段，直接读写原始值，不经过模型的加解密
+func reencryptColumn(tx *gorm.DB, model interface{}, column string, scope func(*gorm.DB) *gorm.DB) (int, error) {
+	var rows []struct {
+		ID    uint64
+		Value string
+	}
+	query := tx.Model(model).Select("id, " + column + " AS value")
+	if scope != nil {
+		query = scope(query)
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return 0, fmt.Errorf("query %s failed: %v", column, err)
+	}
+
+	count := 0
+	for _, row := range rows {
+		if !secret.NeedsRotation(row.Value) {
+			continue
+		}
+		plaintext, err := secret.Decrypt(row.Value)
+		if err != nil {
+			logger.Error("解密失败", logger.F("id", row.ID), logger.F("column", column), logger.F("err", err))
+			return count, err
+		}
+		value, err := secret.Encrypt(plaintext)
+		if err != nil {
+			return count, err
+		}
+		if err := tx.Model(model).Where("id = ?", row.ID).UpdateColumn(column, value).Error; err != nil {
+			return count, fmt.Errorf("update %s failed: %v", column, err)
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"github.com/yockii/dify_tools/pkg/util"
+	"gorm.io/gorm"
+)
+
+// SQL审计记录的调用来源
+const (
+	SqlAuditSourceExecuteSql = "executeSql" // dify_api 的 /executeSql
+	SqlAuditSourceSavedQuery = "savedQuery" // dify_api 的 /executeSavedQuery
+	SqlAuditSourceTextToSql  = "textToSql"  // 问题转SQL，SQL由SQL构建器生成
+	SqlAuditSourceExport     = "export"     // dify_api 的 /exportSql，完整结果导出为文件
+	SqlAuditSourceChart      = "chart"      // dify_api 的 /chart，查询结果绘制为图表
+)
+
+// SqlAudit 智能体执行SQL的审计记录，每次执行（包括被拒绝的）记录一条
+type SqlAudit struct {
+	BaseModel
+	ApplicationID uint64 `json:"applicationId,string" gorm:"index;not null"`
+	DataSourceID  uint64 `json:"dataSourceId,string" gorm:"index;not null"`
+	CustomID      string `json:"customId,omitempty" gorm:"type:varchar(100);index"` // 终端用户ID
+	Source        string `json:"source" gorm:"type:varchar(20);not null"`           // 调用来源
+	Question      string `json:"question,omitempty" gorm:"type:varchar(500)"`       // SQL对应的用户问题，智能体提供时记录
+	Sql           string `json:"sql" gorm:"column:sql_text;type:text;not null"`     // 智能体提交的原始SQL
+	DurationMs    int64  `json:"durationMs"`                                        // 执行耗时，单位：毫秒
+	Rows          int    `json:"rows" gorm:"column:row_count"`                      // 返回的行数
+	Truncated     bool   `json:"truncated"`                                         // 结果是否被截断
+	Error         string `json:"error,omitempty" gorm:"type:varchar(500)"`          // 拒绝或失败的原因，为空表示执行成功
+}
+
+func (a *SqlAudit) TableComment() string {
+	return "SQL审计表"
+}
+
+// BeforeCreate 创建前钩子
+func (a *SqlAudit) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == 0 {
+		a.ID = util.NewID()
+	}
+	return nil
+}
+
+func init() {
+	models = append(models, &SqlAudit{})
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/yockii/dify_tools/pkg/util"
+	"gorm.io/gorm"
+)
+
+// SqlExample 经管理员确认正确的问题及SQL，检索相似问题后作为少样本示例提供给AI
+type SqlExample struct {
+	BaseModel
+	ApplicationID uint64    `json:"applicationId,string" gorm:"index;not null"`
+	DataSourceID  uint64    `json:"dataSourceId,string" gorm:"index;not null"`
+	Question      string    `json:"question" gorm:"type:varchar(500);not null"`
+	Sql           string    `json:"sql" gorm:"column:sql_text;type:text;not null"`
+	Note          string    `json:"note,omitempty" gorm:"type:varchar(500)"`  // 说明，如口径、注意事项
+	AuditID       uint64    `json:"auditId,string,omitzero" gorm:"default:0"` // 从SQL审计记录转入时的审计ID
+	UpdatedAt     time.Time `json:"updatedAt,omitzero" gorm:"type:timestamp;not null"`
+}
+
+func (e *SqlExample) TableComment() string {
+	return "SQL示例表"
+}
+
+// BeforeCreate 创建前钩子
+func (e *SqlExample) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == 0 {
+		e.ID = util.NewID()
+	}
+	return nil
+}
+
+func init() {
+	models = append(models, &SqlExample{})
+}
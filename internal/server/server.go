@@ -14,9 +14,11 @@ import (
 	appapi "github.com/yockii/dify_tools/internal/api_app"
 	difyapi "github.com/yockii/dify_tools/internal/api_dify"
 	sysapi "github.com/yockii/dify_tools/internal/api_sys"
+	"github.com/yockii/dify_tools/internal/datasource"
 	"github.com/yockii/dify_tools/internal/middleware"
 	"github.com/yockii/dify_tools/internal/service"
 	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/filestore"
 	"github.com/yockii/dify_tools/pkg/logger"
 )
 
@@ -33,6 +35,15 @@ type Server struct {
 	dataSourceSrv    service.DataSourceService
 	tableInfoSrv     service.TableInfoService
 	columnInfoSrv    service.ColumnInfoService
+	syncReportSrv    service.SchemaSyncReportService
+	schemaSrv        service.SchemaService
+	schemaDocSrv     service.SchemaKnowledgeService
+	querySrv         service.QueryService
+	sqlAuditSrv      service.SqlAuditService
+	savedQuerySrv    service.SavedQueryService
+	sqlExampleSrv    service.SqlExampleService
+	chartSrv         service.ChartService
+	textToSqlSrv     service.TextToSqlService
 	dictSrv          service.DictService
 	knowledgeBaseSrv service.KnowledgeBaseService
 	documentSrv      service.DocumentService
@@ -97,6 +108,7 @@ func (s *Server) gracefulShutdown() {
 	if err := s.app.ShutdownWithContext(ctx); err != nil {
 		logger.Error("服务关闭失败", logger.F("error", err))
 	}
+	datasource.CloseAll()
 
 	logger.Info("服务已关闭")
 }
@@ -113,15 +125,26 @@ func (s *Server) setupServices() {
 	s.logSrv = service.NewLogService()
 
 	s.applicationSrv = service.NewApplicationService(s.dictSrv)
-	s.dataSourceSrv = service.NewDataSourceService()
+	s.knowledgeBaseSrv = service.NewKnowledgeBaseService(s.dictSrv, s.applicationSrv)
 	s.tableInfoSrv = service.NewTableInfoService()
 	s.columnInfoSrv = service.NewColumnInfoService()
+	s.schemaSrv = service.NewSchemaService(s.tableInfoSrv, s.columnInfoSrv)
+	s.schemaDocSrv = service.NewSchemaKnowledgeService(s.schemaSrv, s.knowledgeBaseSrv)
+	s.dataSourceSrv = service.NewDataSourceService(s.schemaDocSrv)
+	s.syncReportSrv = service.NewSchemaSyncReportService()
+	s.sqlAuditSrv = service.NewSqlAuditService()
+	s.sqlAuditSrv.StartCleanup()
+	filestore.StartCleanup()
+	s.querySrv = service.NewQueryService(s.tableInfoSrv, s.columnInfoSrv, s.sqlAuditSrv)
+	s.savedQuerySrv = service.NewSavedQueryService(s.querySrv)
+	s.sqlExampleSrv = service.NewSqlExampleService()
+	s.chartSrv = service.NewChartService(s.querySrv)
 
-	s.knowledgeBaseSrv = service.NewKnowledgeBaseService(s.dictSrv, s.applicationSrv)
 	s.documentSrv = service.NewDocumentService(s.dictSrv, s.applicationSrv, s.knowledgeBaseSrv)
 
 	s.agentSrv = service.NewAgentService()
 	s.usageSrv = service.NewUsageService()
+	s.textToSqlSrv = service.NewTextToSqlService(s.dictSrv, s.agentSrv, s.schemaSrv, s.querySrv, s.usageSrv, s.sqlExampleSrv)
 }
 
 // setupMiddleware 配置中间件
@@ -156,8 +179,13 @@ func (s *Server) registerSysHandler() {
 		s.dataSourceSrv,
 		s.tableInfoSrv,
 		s.columnInfoSrv,
+		s.syncReportSrv,
 		s.knowledgeBaseSrv,
 		s.usageSrv,
+		s.sqlAuditSrv,
+		s.savedQuerySrv,
+		s.sqlExampleSrv,
+		s.schemaDocSrv,
 		s.logSrv,
 	)
 	sysapi.RegisterDictHandler(
@@ -221,13 +249,24 @@ func (s *Server) registerDifyHandler() {
 	difyapi.RegisterDatabaseHandler(
 		s.applicationSrv,
 		s.dataSourceSrv,
-		s.tableInfoSrv,
-		s.columnInfoSrv,
+		s.schemaSrv,
+		s.querySrv,
+		s.savedQuerySrv,
+		s.textToSqlSrv,
+		s.sqlExampleSrv,
+	)
+	difyapi.RegisterChartHandler(
+		s.applicationSrv,
+		s.dataSourceSrv,
+		s.chartSrv,
 	)
 	difyapi.RegisterKnowledgeBaseHandler(
 		s.applicationSrv,
 		s.knowledgeBaseSrv,
 	)
+	difyapi.RegisterOpenAPIHandler(
+		s.applicationSrv,
+	)
 }
 
 // setupDifyRoutesV1 配置DIFY路由对应dify版本 v1.0.1
@@ -254,6 +293,11 @@ func (s *Server) setupApplicationRoutesV1() {
 		s.knowledgeBaseSrv,
 		s.documentSrv,
 	)
+	appapi.RegisterDataSourceHandler(
+		s.dataSourceSrv,
+		s.textToSqlSrv,
+	)
+	appapi.RegisterFileHandler()
 
 	appAuthMiddleware := middleware.NewAppMiddleware(s.applicationSrv)
 	appApiGroup := s.app.Group("/api/v1", appAuthMiddleware)
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"context"
+	"errors"
+
 	"github.com/yockii/dify_tools/internal/constant"
 	"github.com/yockii/dify_tools/internal/model"
 	"github.com/yockii/dify_tools/pkg/logger"
@@ -57,3 +60,16 @@ func (s *agentService) BuildCondition(query *gorm.DB, condition *model.Agent) *g
 	}
 	return query
 }
+
+// GetByCode 按编码获取智能体，如内置的SQL构建器
+func (s *agentService) GetByCode(ctx context.Context, code string) (*model.Agent, error) {
+	var agent model.Agent
+	if err := s.db.Where("code = ?", code).First(&agent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, constant.ErrRecordNotFound
+		}
+		logger.Error("查询智能体失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	return &agent, nil
+}
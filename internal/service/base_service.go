@@ -105,6 +105,11 @@ func (s *BaseServiceImpl[T]) Create(ctx context.Context, record T) error {
 
 // Update 更新记录
 func (s *BaseServiceImpl[T]) Update(ctx context.Context, record T) error {
+	return s.UpdateFields(ctx, record, nil)
+}
+
+// UpdateFields 更新记录，除非零值字段外，fields 中的字段即使为空值也会写入，用于清空可选字段
+func (s *BaseServiceImpl[T]) UpdateFields(ctx context.Context, record T, fields []string) error {
 	id := record.GetID()
 	if id == 0 {
 		return constant.ErrRecordIDEmpty
@@ -127,7 +132,16 @@ func (s *BaseServiceImpl[T]) Update(ctx context.Context, record T) error {
 	}
 
 	// 更新记录
-	if err := s.db.Model(record).Updates(record).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(record).Updates(record).Error; err != nil {
+			return err
+		}
+		if len(fields) > 0 {
+			return tx.Model(record).Select(fields).Updates(record).Error
+		}
+		return nil
+	})
+	if err != nil {
 		logger.Error("更新记录失败", logger.F("error", err))
 		return constant.ErrDatabaseError
 	}
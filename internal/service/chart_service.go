@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/chart"
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/filestore"
+	"github.com/yockii/dify_tools/pkg/logger"
+)
+
+// 饼图合并后扇区的名称
+const chartOtherLabel = "其他"
+
+type chartService struct {
+	queryService QueryService
+}
+
+func NewChartService(queryService QueryService) *chartService {
+	return &chartService{queryService: queryService}
+}
+
+// Render 将结果集绘制为图表，SVG、PNG保存到文件存储并返回带签名的图片地址，mermaid直接返回代码。
+// 参数不合法时返回 *ChartSpecError；执行SQL时与 ExecuteSql 一样检查、脱敏并记录审计
+func (s *chartService) Render(ctx context.Context, dataSource *model.DataSource, req *ChartRequest) (*ChartResult, error) {
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	switch req.Type {
+	case chart.TypeBar, chart.TypeLine, chart.TypePie:
+	default:
+		return nil, &ChartSpecError{Field: "type", Reason: "图表类型只能是bar、line或pie"}
+	}
+	req.Format = strings.ToLower(strings.TrimSpace(req.Format))
+	if req.Format == "" {
+		req.Format = "svg"
+	}
+	if req.Format != "svg" && req.Format != "png" && req.Format != "mermaid" {
+		return nil, &ChartSpecError{Field: "format", Reason: "格式只能是svg、png或mermaid"}
+	}
+	if strings.TrimSpace(req.X) == "" {
+		return nil, &ChartSpecError{Field: "x", Reason: "分类字段不能为空"}
+	}
+
+	result := &ChartResult{Format: req.Format}
+	rows := req.Rows
+	if len(rows) == 0 {
+		if req.Query == nil || req.Query.Sql == "" || dataSource == nil {
+			return nil, constant.ErrInvalidParams
+		}
+		query := *req.Query
+		query.Source = model.SqlAuditSourceChart
+		queryResult, err := s.queryService.ExecuteSql(ctx, dataSource, &query)
+		if err != nil {
+			return nil, err
+		}
+		rows = queryResult.Rows
+		result.Truncated = queryResult.Truncated
+	}
+	if len(rows) == 0 {
+		return nil, &ChartSpecError{Field: "rows", Reason: "结果集没有数据"}
+	}
+
+	c, truncated, err := buildChart(rows, req)
+	if err != nil {
+		return nil, err
+	}
+	result.Truncated = result.Truncated || truncated
+	result.Points = len(c.Labels)
+
+	if req.Format == "mermaid" {
+		code, err := chart.Mermaid(c)
+		if err != nil {
+			return nil, chartError(err)
+		}
+		result.Mermaid = code
+		result.Markdown = "```mermaid\n" + code + "```"
+		return result, nil
+	}
+
+	name := req.Title
+	if name == "" {
+		name = "chart_" + time.Now().Format("20060102_150405")
+	}
+	file, err := filestore.Save(exportFileName(name, req.Format), func(w io.Writer) error {
+		if req.Format == "png" {
+			return chart.RenderPNG(c, w)
+		}
+		return chart.RenderSVG(c, w)
+	})
+	if err != nil {
+		if spec := chartError(err); spec != err {
+			return nil, spec
+		}
+		logger.Error("保存图表失败", logger.F("error", err))
+		return nil, constant.ErrInternalError
+	}
+	result.ExpiresAt = time.Now().Add(time.Duration(config.GetInt("files.url_ttl")) * time.Second).Unix()
+	// 括号会截断markdown中的链接
+	result.Url = strings.NewReplacer("(", "%28", ")", "%29").Replace(req.BaseURL + FileDownloadPath(file.ID, file.Name, result.ExpiresAt))
+	alt := strings.NewReplacer("[", "", "]", "").Replace(req.Title)
+	result.Markdown = fmt.Sprintf("![%s](%s)", alt, result.Url)
+	return result, nil
+}
+
+// chartError 将绘制时的数据错误转换为参数错误，其余原样返回
+func chartError(err error) error {
+	if errors.Is(err, chart.ErrNoData) {
+		return &ChartSpecError{Field: "y", Reason: "没有可绘制的数据，饼图的数值需大于0"}
+	}
+	return err
+}
+
+// buildChart 按字段从结果集中取出分类及数值，字段名不区分大小写；
+// 柱状图、折线图最多 chart.max_points 个分类，饼图超过 chart.pie_max_slices 时将较小的扇区合并
+func buildChart(rows []map[string]interface{}, req *ChartRequest) (*chart.Chart, bool, error) {
+	columns := make(map[string]string)
+	for _, row := range rows {
+		for k := range row {
+			if _, ok := columns[strings.ToLower(k)]; !ok {
+				columns[strings.ToLower(k)] = k
+			}
+		}
+	}
+	x, ok := columns[strings.ToLower(strings.TrimSpace(req.X))]
+	if !ok {
+		return nil, false, &ChartSpecError{Field: "x", Reason: "结果中没有字段 " + req.X}
+	}
+
+	var y []string
+	if len(req.Y) == 0 {
+		// 未指定时使用所有数值列，按名称排序保证顺序稳定
+		for _, name := range columns {
+			if name != x && isNumericColumn(rows, name) {
+				y = append(y, name)
+			}
+		}
+		if len(y) == 0 {
+			return nil, false, &ChartSpecError{Field: "y", Reason: "结果中没有数值字段"}
+		}
+		sort.Strings(y)
+	} else {
+		for _, field := range req.Y {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			name, ok := columns[strings.ToLower(field)]
+			if !ok {
+				return nil, false, &ChartSpecError{Field: "y", Reason: "结果中没有字段 " + field}
+			}
+			if !isNumericColumn(rows, name) {
+				return nil, false, &ChartSpecError{Field: "y", Reason: "字段 " + field + " 不是数值"}
+			}
+			y = append(y, name)
+		}
+		if len(y) == 0 {
+			return nil, false, &ChartSpecError{Field: "y", Reason: "数值字段不能为空"}
+		}
+	}
+	if req.Type == chart.TypePie {
+		y = y[:1]
+	}
+
+	c := &chart.Chart{
+		Type:   req.Type,
+		Title:  req.Title,
+		Width:  config.GetInt("chart.width"),
+		Height: config.GetInt("chart.height"),
+		Series: make([]chart.Series, len(y)),
+	}
+	for i, name := range y {
+		c.Series[i].Name = name
+	}
+	for _, row := range rows {
+		c.Labels = append(c.Labels, chartLabel(row[x]))
+		for i, name := range y {
+			v, _ := chartNumber(row[name])
+			c.Series[i].Values = append(c.Series[i].Values, v)
+		}
+	}
+
+	truncated := false
+	if req.Type == chart.TypePie {
+		truncated = mergePieSlices(c, config.GetInt("chart.pie_max_slices"))
+	} else if maxPoints := config.GetInt("chart.max_points"); maxPoints > 0 && len(c.Labels) > maxPoints {
+		c.Labels = c.Labels[:maxPoints]
+		for i := range c.Series {
+			c.Series[i].Values = c.Series[i].Values[:maxPoints]
+		}
+		truncated = true
+	}
+	return c, truncated, nil
+}
+
+// mergePieSlices 扇区按数值从大到小排列，超过 maxSlices 时其余合并为"其他"，返回是否合并
+func mergePieSlices(c *chart.Chart, maxSlices int) bool {
+	type slice struct {
+		label string
+		value float64
+	}
+	slices := make([]slice, len(c.Labels))
+	for i, l := range c.Labels {
+		slices[i] = slice{l, c.Series[0].Values[i]}
+	}
+	sort.SliceStable(slices, func(i, j int) bool { return slices[i].value > slices[j].value })
+
+	merged := false
+	if maxSlices > 1 && len(slices) > maxSlices {
+		other := slice{label: chartOtherLabel}
+		for _, s := range slices[maxSlices-1:] {
+			other.value += s.value
+		}
+		slices = append(slices[:maxSlices-1], other)
+		merged = true
+	}
+	c.Labels = make([]string, len(slices))
+	c.Series[0].Values = make([]float64, len(slices))
+	for i, s := range slices {
+		c.Labels[i] = s.label
+		c.Series[0].Values[i] = s.value
+	}
+	return merged
+}
+
+// isNumericColumn 非空值都能转为数值且至少有一个非空值
+func isNumericColumn(rows []map[string]interface{}, name string) bool {
+	found := false
+	for _, row := range rows {
+		v := row[name]
+		if v == nil {
+			continue
+		}
+		if _, ok := chartNumber(v); !ok {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// chartNumber 数值或数字字符串（如DECIMAL）转为float64，NULL为0
+func chartNumber(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case nil:
+		return 0, true
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	case []byte:
+		return chartNumber(string(x))
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// chartLabel 分类值的文本形式，时间部分为0的按日期显示
+func chartLabel(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case []byte:
+		return string(x)
+	case time.Time:
+		if x.Hour() == 0 && x.Minute() == 0 && x.Second() == 0 {
+			return x.Format(time.DateOnly)
+		}
+		return x.Format(time.DateTime)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
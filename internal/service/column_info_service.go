@@ -6,6 +6,7 @@ import (
 	"github.com/yockii/dify_tools/internal/constant"
 	"github.com/yockii/dify_tools/internal/model"
 	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/masking"
 	"gorm.io/gorm"
 )
 
@@ -64,15 +65,80 @@ func (s *columnInfoService) BuildCondition(query *gorm.DB, condition *model.Colu
 	if condition.Comment != "" {
 		query = query.Where("comment LIKE ?", "%"+condition.Comment+"%")
 	}
+	if condition.PiiStatus != 0 {
+		query = query.Where("pii_status = ?", condition.PiiStatus)
+	}
+	if condition.PiiType != "" {
+		query = query.Where("pii_type = ?", condition.PiiType)
+	}
 	return query
 }
 
+// ListSchemaForDify 查询提供给AI的列，待确认的敏感列不提供
 func (s *columnInfoService) ListSchemaForDify(ctx context.Context, condition *model.ColumnInfo) ([]*model.ColumnInfo, error) {
 	var cl []*model.ColumnInfo
-	if err := s.db.Select("Name", "Type", "Comment").
-		Where(condition).Find(&cl).Error; err != nil {
+	if err := s.db.Select("TableID", "Name", "Type", "Comment", "MaskType", "PrimaryKey", "RefTable", "RefColumn",
+		"BusinessName", "Description", "Synonyms", "Unit", "EnumValues", "Profile").
+		Where(condition).Where("pii_status <> ?", model.PiiStatusPending).Order("id").Find(&cl).Error; err != nil {
 		logger.Error("查询记录失败", logger.F("error", err))
 		return nil, constant.ErrDatabaseError
 	}
+	// 只标记脱敏的列，让AI知道这些列返回的是脱敏后的值
+	for _, c := range cl {
+		if !masking.IsMasked(c.MaskType) {
+			c.MaskType = ""
+		}
+	}
 	return cl, nil
 }
+
+// ListMaskedColumns 查询指定表中需要脱敏的列，待确认的敏感列完全隐藏
+func (s *columnInfoService) ListMaskedColumns(ctx context.Context, tableIDs []uint64) ([]*model.ColumnInfo, error) {
+	var cl []*model.ColumnInfo
+	if len(tableIDs) == 0 {
+		return cl, nil
+	}
+	if err := s.db.Select("TableID", "Name", "MaskType", "PiiStatus").
+		Where("table_id IN ?", tableIDs).
+		Where("mask_type NOT IN ? OR pii_status = ?", []string{"", masking.TypeNone}, model.PiiStatusPending).
+		Find(&cl).Error; err != nil {
+		logger.Error("查询脱敏列失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	for _, c := range cl {
+		if c.PiiStatus == model.PiiStatusPending {
+			c.MaskType = masking.TypeHide
+		}
+	}
+	return cl, nil
+}
+
+// ConfirmPii 确认或否定同步时识别出的待确认敏感列，返回处理的列数。
+// 确认时设置脱敏类型，maskType 为空时使用建议的脱敏类型；否定后该列不再识别，按原有脱敏设置提供给AI
+func (s *columnInfoService) ConfirmPii(ctx context.Context, ids []uint64, confirm bool, maskType string) (int, error) {
+	var cl []*model.ColumnInfo
+	if err := s.db.Where("id IN ? AND pii_status = ?", ids, model.PiiStatusPending).Find(&cl).Error; err != nil {
+		logger.Error("查询待确认的敏感列失败", logger.F("error", err))
+		return 0, constant.ErrDatabaseError
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, c := range cl {
+			c.PiiStatus = model.PiiStatusDismissed
+			if confirm {
+				c.PiiStatus, c.MaskType = model.PiiStatusConfirmed, maskType
+				if c.MaskType == "" {
+					c.MaskType = c.SuggestedMask
+				}
+			}
+			if err := tx.Model(c).Select("PiiStatus", "MaskType").Updates(c).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("确认敏感列失败", logger.F("error", err))
+		return 0, constant.ErrDatabaseError
+	}
+	return len(cl), nil
+}
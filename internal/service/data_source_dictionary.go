@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/masking"
+	"github.com/yockii/dify_tools/pkg/pii"
+)
+
+// 脱敏类型在数据字典中的名称
+var maskTypeNames = map[string]string{
+	masking.TypeHide:    "完全隐藏",
+	masking.TypeNull:    "置空",
+	masking.TypeHash:    "哈希",
+	masking.TypeIDCard:  "身份证号",
+	masking.TypePhone:   "手机号",
+	masking.TypePartial: "部分遮盖",
+}
+
+// 敏感信息类型在数据字典中的名称
+var piiTypeNames = map[string]string{
+	pii.TypePhone:    "电话",
+	pii.TypeIDCard:   "身份证号",
+	pii.TypeEmail:    "邮箱",
+	pii.TypeBankCard: "银行卡号",
+	pii.TypeAddress:  "地址",
+	pii.TypeName:     "姓名",
+}
+
+// mermaid实体名及属性类型不支持的字符
+var erInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// ExportDictionary 生成数据源的数据字典（Markdown）：ER图及每张表的说明、索引和列（含主外键、注释及脱敏设置）。
+// all 为false时只包含开放给AI的表，否则包含所有已同步的表并标注是否开放
+func (s *dataSourceService) ExportDictionary(ctx context.Context, dataSource *model.DataSource, all bool) (string, error) {
+	query := s.db.Where("data_source_id = ?", dataSource.ID)
+	if !all {
+		query = query.Where("exposed_to_ai = ?", 1)
+	}
+	var tables []*model.TableInfo
+	if err := query.Order("name").Find(&tables).Error; err != nil {
+		logger.Error("查询表信息失败", logger.F("error", err))
+		return "", constant.ErrDatabaseError
+	}
+	var columns []*model.ColumnInfo
+	if err := s.db.Where("data_source_id = ?", dataSource.ID).Order("id").Find(&columns).Error; err != nil {
+		logger.Error("查询列信息失败", logger.F("error", err))
+		return "", constant.ErrDatabaseError
+	}
+	byTable := make(map[uint64][]*model.ColumnInfo, len(tables))
+	for _, c := range columns {
+		byTable[c.TableID] = append(byTable[c.TableID], c)
+	}
+
+	var b strings.Builder
+	b.WriteString("# 数据字典：" + dataSource.Name + "\n\n")
+	b.WriteString("- 数据源类型：" + dataSource.Type + "\n")
+	if dataSource.Database != "" {
+		b.WriteString("- 数据库：" + dataSource.Database + "\n")
+	}
+	exposed := 0
+	for _, t := range tables {
+		if t.ExposedToAI == 1 {
+			exposed++
+		}
+	}
+	if all {
+		b.WriteString(fmt.Sprintf("- 表数量：%d（开放给AI %d）\n", len(tables), exposed))
+	} else {
+		b.WriteString(fmt.Sprintf("- 表数量：%d（仅开放给AI的表）\n", len(tables)))
+	}
+	if !dataSource.SyncTime.IsZero() {
+		b.WriteString("- 同步时间：" + dataSource.SyncTime.Format(time.DateTime) + "\n")
+	}
+	b.WriteString("- 导出时间：" + time.Now().Format(time.DateTime) + "\n")
+
+	if er := erDiagram(tables, byTable); er != "" {
+		b.WriteString("\n## ER图\n\n```mermaid\n" + er + "```\n")
+	}
+
+	for _, t := range tables {
+		b.WriteString("\n" + renderDictionaryTable(t, byTable[t.ID], all))
+	}
+	return b.String(), nil
+}
+
+// renderDictionaryTable 单张表的说明、索引及列表格
+func renderDictionaryTable(t *model.TableInfo, cols []*model.ColumnInfo, all bool) string {
+	var b strings.Builder
+	b.WriteString("## " + t.Name)
+	if t.BusinessName != "" {
+		b.WriteString("（" + singleLine(t.BusinessName) + "）")
+	}
+	b.WriteString("\n\n")
+	if t.Kind == "view" {
+		b.WriteString("- 类型：视图\n")
+	} else {
+		b.WriteString("- 类型：表\n")
+	}
+	if t.Comment != "" {
+		b.WriteString("- 注释：" + singleLine(t.Comment) + "\n")
+	}
+	if t.Description != "" {
+		b.WriteString("- 业务说明：" + singleLine(t.Description) + "\n")
+	}
+	if t.Synonyms != "" {
+		b.WriteString("- 同义词：" + singleLine(t.Synonyms) + "\n")
+	}
+	if all {
+		if t.ExposedToAI == 1 {
+			b.WriteString("- 开放给AI：是\n")
+		} else {
+			b.WriteString("- 开放给AI：否\n")
+		}
+	}
+	if t.RowFilter != "" {
+		b.WriteString("- 行级过滤：`" + singleLine(t.RowFilter) + "`\n")
+	}
+	for _, idx := range t.Indexes {
+		line := "- 索引：" + idx.Name + "(" + strings.Join(idx.Columns, ", ") + ")"
+		if idx.Unique {
+			line += " 唯一"
+		}
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString("\n| 列名 | 类型 | 可空 | 默认值 | 键 | 注释 | 脱敏 |\n|---|---|---|---|---|---|---|\n")
+	for _, c := range cols {
+		nullable := "否"
+		if c.Nullable {
+			nullable = "是"
+		}
+		var keys []string
+		if c.PrimaryKey {
+			keys = append(keys, "PK")
+		}
+		if c.RefTable != "" {
+			keys = append(keys, "FK→"+c.RefTable+"."+c.RefColumn)
+		}
+		var notes []string
+		if c.Comment != "" {
+			notes = append(notes, singleLine(c.Comment))
+		}
+		if c.BusinessName != "" && c.BusinessName != c.Comment {
+			notes = append(notes, singleLine(c.BusinessName))
+		}
+		if c.Unit != "" {
+			notes = append(notes, "单位："+singleLine(c.Unit))
+		}
+		mask := "无"
+		if c.PiiStatus == model.PiiStatusPending {
+			mask = "待确认（" + piiTypeNames[c.PiiType] + "）"
+		} else if masking.IsMasked(c.MaskType) {
+			mask = maskTypeNames[c.MaskType]
+		}
+		cells := []string{c.Name, c.Type, nullable, singleLine(c.DefaultValue), strings.Join(keys, " "),
+			strings.Join(notes, "；"), mask}
+		for i := range cells {
+			cells[i] = strings.ReplaceAll(cells[i], "|", "\\|")
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	return b.String()
+}
+
+// erDiagram 根据外键生成Mermaid ER图，只包含有关联的表及其主键、外键列；没有关联时返回空。
+// 注释等文本需要引号，转为HTML后会被转义导致渲染失败，因此不输出
+func erDiagram(tables []*model.TableInfo, byTable map[uint64][]*model.ColumnInfo) string {
+	names := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		names[t.Name] = true
+	}
+	var relations []string
+	related := make(map[string]bool)
+	for _, t := range tables {
+		for _, c := range byTable[t.ID] {
+			if c.RefTable == "" || !names[c.RefTable] {
+				continue
+			}
+			// 外键可空时被引用方为0或1
+			parent := "||"
+			if c.Nullable {
+				parent = "|o"
+			}
+			relations = append(relations, fmt.Sprintf("    %s %s--o{ %s : %s\n", erName(c.RefTable), parent, erName(t.Name), erName(c.Name)))
+			related[t.Name], related[c.RefTable] = true, true
+		}
+	}
+	if len(relations) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+	for _, t := range tables {
+		if !related[t.Name] {
+			continue
+		}
+		var attrs []string
+		for _, c := range byTable[t.ID] {
+			var key string
+			switch {
+			case c.PrimaryKey && c.RefTable != "":
+				key = " PK, FK"
+			case c.PrimaryKey:
+				key = " PK"
+			case c.RefTable != "":
+				key = " FK"
+			default:
+				continue
+			}
+			attrs = append(attrs, "        "+erName(c.Type)+" "+erName(c.Name)+key+"\n")
+		}
+		// 没有键列的表只出现在关联中
+		if len(attrs) > 0 {
+			b.WriteString("    " + erName(t.Name) + " {\n" + strings.Join(attrs, "") + "    }\n")
+		}
+	}
+	for _, r := range relations {
+		b.WriteString(r)
+	}
+	return b.String()
+}
+
+// erName 将名称中mermaid不支持的字符替换为下划线，如 varchar(50) 转为 varchar_50_
+func erName(s string) string {
+	s = erInvalidChars.ReplaceAllString(s, "_")
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+	return s
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"mime/multipart"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/tabular"
+	"gorm.io/gorm"
+)
+
+// 业务术语导入文件的列，表头支持中英文
+const (
+	glossaryTable            = "table"
+	glossaryColumn           = "column"
+	glossaryBusinessName     = "business_name"
+	glossaryDescription      = "description"
+	glossarySynonyms         = "synonyms"
+	glossaryEnumValues       = "enum_values"
+	glossaryUnit             = "unit"
+	glossaryExampleQuestions = "example_questions"
+)
+
+var glossaryHeaders = map[string]string{
+	"table": glossaryTable, "tablename": glossaryTable, "表": glossaryTable, "表名": glossaryTable,
+	"column": glossaryColumn, "columnname": glossaryColumn, "列": glossaryColumn, "列名": glossaryColumn, "字段": glossaryColumn, "字段名": glossaryColumn,
+	"businessname": glossaryBusinessName, "业务名称": glossaryBusinessName,
+	"description": glossaryDescription, "说明": glossaryDescription, "业务说明": glossaryDescription,
+	"synonyms": glossarySynonyms, "同义词": glossarySynonyms,
+	"enumvalues": glossaryEnumValues, "枚举值": glossaryEnumValues, "取值": glossaryEnumValues,
+	"unit": glossaryUnit, "单位": glossaryUnit,
+	"examplequestions": glossaryExampleQuestions, "示例问题": glossaryExampleQuestions,
+}
+
+// ImportGlossary 从CSV/XLSX导入业务术语。每行对应一张表（列名为空）或一个列，
+// 只更新非空的单元格，未找到的表或列在结果中列出
+func (s *dataSourceService) ImportGlossary(ctx context.Context, dataSourceID uint64, fileHeader *multipart.FileHeader) (*GlossaryImportResult, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		logger.Error("打开上传文件失败", logger.F("error", err))
+		return nil, constant.ErrInternalError
+	}
+	defer file.Close()
+
+	records, err := tabular.ReadRecords(fileHeader.Filename, file)
+	if err != nil {
+		switch {
+		case errors.Is(err, tabular.ErrUnsupportedFormat):
+			return nil, constant.ErrUnsupportedFileType
+		case errors.Is(err, tabular.ErrEmptySheet):
+			return nil, constant.ErrImportEmpty
+		}
+		logger.Error("解析导入文件失败", logger.F("file", fileHeader.Filename), logger.F("error", err))
+		return nil, constant.ErrInvalidParams
+	}
+	if len(records) < 2 {
+		return nil, constant.ErrImportEmpty
+	}
+
+	fields := make(map[string]int)
+	for i, h := range records[0] {
+		key := strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(h)))
+		if field, ok := glossaryHeaders[key]; ok {
+			if _, exists := fields[field]; !exists {
+				fields[field] = i
+			}
+		}
+	}
+	if _, ok := fields[glossaryTable]; !ok {
+		return nil, constant.ErrGlossaryHeaderMissing
+	}
+
+	var tables []*model.TableInfo
+	if err := s.db.Where("data_source_id = ?", dataSourceID).Find(&tables).Error; err != nil {
+		logger.Error("查询表信息失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	var columns []*model.ColumnInfo
+	if err := s.db.Where("data_source_id = ?", dataSourceID).Find(&columns).Error; err != nil {
+		logger.Error("查询列信息失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	tableByName := make(map[string]*model.TableInfo, len(tables))
+	for _, t := range tables {
+		tableByName[strings.ToLower(t.Name)] = t
+	}
+	columnByName := make(map[uint64]map[string]*model.ColumnInfo, len(tables))
+	for _, c := range columns {
+		if columnByName[c.TableID] == nil {
+			columnByName[c.TableID] = make(map[string]*model.ColumnInfo)
+		}
+		columnByName[c.TableID][strings.ToLower(c.Name)] = c
+	}
+
+	result := &GlossaryImportResult{}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, row := range records[1:] {
+			get := func(field string) string {
+				if i, ok := fields[field]; ok && i < len(row) {
+					return strings.TrimSpace(row[i])
+				}
+				return ""
+			}
+			tableName, columnName := get(glossaryTable), get(glossaryColumn)
+			if tableName == "" {
+				continue
+			}
+			table := tableByName[strings.ToLower(tableName)]
+			if table == nil {
+				result.Unmatched = append(result.Unmatched, tableName)
+				continue
+			}
+
+			if columnName == "" {
+				var selects []string
+				if v := get(glossaryBusinessName); v != "" {
+					table.BusinessName = truncateRunes(v, 100)
+					selects = append(selects, "BusinessName")
+				}
+				if v := get(glossaryDescription); v != "" {
+					table.Description = truncateRunes(v, 1000)
+					selects = append(selects, "Description")
+				}
+				if v := get(glossarySynonyms); v != "" {
+					table.Synonyms = truncateRunes(normalizeSynonyms(v), 500)
+					selects = append(selects, "Synonyms")
+				}
+				if v := get(glossaryExampleQuestions); v != "" {
+					table.ExampleQuestions = splitGlossaryList(v, false)
+					selects = append(selects, "ExampleQuestions")
+				}
+				if len(selects) == 0 {
+					continue
+				}
+				if err := tx.Model(table).Select(selects).Updates(table).Error; err != nil {
+					return err
+				}
+				result.Tables++
+				continue
+			}
+
+			column := columnByName[table.ID][strings.ToLower(columnName)]
+			if column == nil {
+				result.Unmatched = append(result.Unmatched, tableName+"."+columnName)
+				continue
+			}
+			var selects []string
+			if v := get(glossaryBusinessName); v != "" {
+				column.BusinessName = truncateRunes(v, 100)
+				selects = append(selects, "BusinessName")
+			}
+			if v := get(glossaryDescription); v != "" {
+				column.Description = truncateRunes(v, 1000)
+				selects = append(selects, "Description")
+			}
+			if v := get(glossarySynonyms); v != "" {
+				column.Synonyms = truncateRunes(normalizeSynonyms(v), 500)
+				selects = append(selects, "Synonyms")
+			}
+			if v := get(glossaryUnit); v != "" {
+				column.Unit = truncateRunes(v, 20)
+				selects = append(selects, "Unit")
+			}
+			if v := get(glossaryEnumValues); v != "" {
+				column.EnumValues = parseEnumValues(v)
+				selects = append(selects, "EnumValues")
+			}
+			if len(selects) == 0 {
+				continue
+			}
+			if err := tx.Model(column).Select(selects).Updates(column).Error; err != nil {
+				return err
+			}
+			result.Columns++
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("导入业务术语失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	return result, nil
+}
+
+// splitGlossaryList 按分号或换行拆分多个值，withComma 为 true 时逗号、顿号也作为分隔符
+func splitGlossaryList(s string, withComma bool) []string {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		switch r {
+		case ';', '；', '\n', '\r':
+			return true
+		case ',', '，', '、':
+			return withComma
+		}
+		return false
+	})
+	result := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// normalizeSynonyms 统一同义词的分隔符为英文逗号
+func normalizeSynonyms(s string) string {
+	return strings.Join(splitGlossaryList(s, true), ",")
+}
+
+// parseEnumValues 解析枚举值含义，如 "1=已支付;2=已退款"，值与含义之间可用 = 或冒号分隔
+func parseEnumValues(s string) []model.EnumValue {
+	var values []model.EnumValue
+	for _, item := range splitGlossaryList(s, true) {
+		i := strings.IndexAny(item, "=:：")
+		if i < 0 {
+			values = append(values, model.EnumValue{Value: item})
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(item[i:])
+		values = append(values, model.EnumValue{
+			Value:   strings.TrimSpace(item[:i]),
+			Meaning: strings.TrimSpace(item[i+size:]),
+		})
+	}
+	return values
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yockii/dify_tools/internal/datasource"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/masking"
+	"github.com/yockii/dify_tools/pkg/pii"
+)
+
+// classify 识别数据源中尚未识别过的列是否为敏感信息（手机号、身份证号、邮箱等），返回识别为敏感信息的列数。
+// 识别出的列记为待确认并给出建议的脱敏类型，确认前不提供给AI；主键、已设置脱敏的列及日期时间等类型的列不识别。
+// 按列名、注释及每张表前 pii.sample_rows 行的取值识别，单张表读取失败时只按列名及注释识别
+func (s *dataSourceService) classify(ctx context.Context, dataSource *model.DataSource) (classified int) {
+	var columns []*model.ColumnInfo
+	if err := s.db.Where("data_source_id = ? AND pii_status = ?", dataSource.ID, model.PiiStatusUnchecked).
+		Order("id").Find(&columns).Error; err != nil {
+		logger.Error("查询列信息失败", logger.F("error", err))
+		return 0
+	}
+	byTable := make(map[uint64][]*model.ColumnInfo)
+	for _, c := range columns {
+		if c.PrimaryKey || masking.IsMasked(c.MaskType) {
+			continue
+		}
+		if kind := profileKind(c.Type); kind == profileKindSkip || kind == profileKindTime {
+			continue
+		}
+		byTable[c.TableID] = append(byTable[c.TableID], c)
+	}
+	if len(byTable) == 0 {
+		return 0
+	}
+
+	var tables []*model.TableInfo
+	if err := s.db.Where("data_source_id = ?", dataSource.ID).Find(&tables).Error; err != nil {
+		logger.Error("查询表信息失败", logger.F("error", err))
+		return 0
+	}
+	sampleRows := config.GetInt("pii.sample_rows")
+	matchRatio := config.GetFloat64("pii.match_ratio")
+	timeout := queryLimitsOf(dataSource).timeout
+
+	for _, table := range tables {
+		targets := byTable[table.ID]
+		if len(targets) == 0 {
+			continue
+		}
+		samples, err := sampleColumns(ctx, dataSource, table.Name, targets, sampleRows, timeout)
+		if err != nil {
+			logger.Warn("读取敏感信息识别样本失败", logger.F("table", table.Name), logger.F("error", err))
+		}
+		for i, c := range targets {
+			var values []string
+			if samples != nil {
+				values = samples[i]
+			}
+			typ, _ := pii.Classify(c.Name, c.Comment, values, matchRatio)
+			if typ == "" {
+				// 读取样本失败时保持未识别，下次同步再识别
+				if err != nil {
+					continue
+				}
+				c.PiiStatus = model.PiiStatusDismissed
+			} else {
+				c.PiiType, c.PiiStatus, c.SuggestedMask = typ, model.PiiStatusPending, pii.SuggestMask(typ)
+				classified++
+			}
+			if err := s.db.Model(c).Select("PiiType", "PiiStatus", "SuggestedMask").Updates(c).Error; err != nil {
+				logger.Error("保存敏感信息识别结果失败", logger.F("column", c.Name), logger.F("error", err))
+			}
+		}
+	}
+	return classified
+}
+
+// sampleColumns 读取表的前 sampleRows 行，按列返回非空取值
+func sampleColumns(ctx context.Context, dataSource *model.DataSource, tableName string, columns []*model.ColumnInfo, sampleRows int, timeout time.Duration) ([][]string, error) {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = quoteIdentifier(dataSource.Type, c.Name)
+	}
+	sql := fmt.Sprintf("SELECT %s FROM %s", strings.Join(names, ", "), quoteTable(dataSource, tableName))
+	if sampleRows > 0 {
+		sql += fmt.Sprintf(" LIMIT %d", sampleRows)
+	}
+	rows, _, err := datasource.QueryReadOnly(ctx, dataSource, datasource.QueryOptions{Timeout: timeout}, sql)
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]string, len(columns))
+	for _, row := range rows {
+		for i, c := range columns {
+			if v := profileString(row[c.Name]); v != "" {
+				values[i] = append(values[i], v)
+			}
+		}
+	}
+	return values, nil
+}
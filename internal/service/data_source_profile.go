@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yockii/dify_tools/internal/datasource"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/masking"
+)
+
+// 列取值分析时的类型分类
+const (
+	profileKindNumber = "number" // 数值，记录最小、最大值
+	profileKindTime   = "time"   // 日期时间，记录最小、最大值
+	profileKindText   = "text"   // 文本等，只记录取值
+	profileKindSkip   = "skip"   // 二进制、JSON等，不分析
+)
+
+// profileValueMaxRunes 记录的单个取值的最大长度
+const profileValueMaxRunes = 50
+
+// profile 采样分析开放给AI的表中各列的取值分布。主键、脱敏列、待确认的敏感列及设置了行级过滤的表不分析，
+// 已有的分析结果会被清除，避免取值绕过脱敏或行级权限暴露给AI；单张表分析失败时跳过该表
+func (s *dataSourceService) profile(ctx context.Context, dataSource *model.DataSource) (profiled int) {
+	var tables []*model.TableInfo
+	if err := s.db.Where("data_source_id = ? AND exposed_to_ai = ?", dataSource.ID, 1).Find(&tables).Error; err != nil {
+		logger.Error("查询表信息失败", logger.F("error", err))
+		return 0
+	}
+	sampleRows := config.GetInt("schema.profile_sample_rows")
+	maxDistinct := config.GetInt("schema.profile_max_distinct")
+	timeout := queryLimitsOf(dataSource).timeout
+
+	for _, table := range tables {
+		var columns []*model.ColumnInfo
+		if err := s.db.Where("table_id = ?", table.ID).Order("id").Find(&columns).Error; err != nil {
+			logger.Error("查询列信息失败", logger.F("error", err))
+			continue
+		}
+		var targets []*model.ColumnInfo
+		for _, c := range columns {
+			if table.RowFilter == "" && !c.PrimaryKey && !masking.IsMasked(c.MaskType) && c.PiiStatus != model.PiiStatusPending &&
+				profileKind(c.Type) != profileKindSkip {
+				targets = append(targets, c)
+			} else if c.Profile != nil {
+				s.saveProfile(c, nil)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		profiles, err := profileTable(ctx, dataSource, table.Name, targets, sampleRows, maxDistinct, timeout)
+		if err != nil {
+			logger.Warn("分析列取值失败", logger.F("table", table.Name), logger.F("error", err))
+			continue
+		}
+		for i, c := range targets {
+			s.saveProfile(c, profiles[i])
+		}
+		profiled += len(targets)
+	}
+	return profiled
+}
+
+// clearProfiles 清除数据源所有列的取值分析结果
+func (s *dataSourceService) clearProfiles(dataSourceID uint64) {
+	if err := s.db.Model(&model.ColumnInfo{}).Where("data_source_id = ? AND profile IS NOT NULL", dataSourceID).
+		Update("profile", nil).Error; err != nil {
+		logger.Error("清除列取值分析失败", logger.F("error", err))
+	}
+}
+
+func (s *dataSourceService) saveProfile(column *model.ColumnInfo, profile *model.ColumnProfile) {
+	column.Profile = profile
+	var err error
+	if profile == nil {
+		err = s.db.Model(column).Update("profile", nil).Error
+	} else {
+		err = s.db.Model(column).Select("Profile").Updates(column).Error
+	}
+	if err != nil {
+		logger.Error("保存列取值分析失败", logger.F("column", column.Name), logger.F("error", err))
+	}
+}
+
+// profileTable 在表的前 sampleRows 行上统计各列的空值、不同值数量及最值，不同值不超过 maxDistinct 的列再读取全部取值
+func profileTable(ctx context.Context, dataSource *model.DataSource, tableName string, columns []*model.ColumnInfo, sampleRows, maxDistinct int, timeout time.Duration) ([]*model.ColumnProfile, error) {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = quoteIdentifier(dataSource.Type, c.Name)
+	}
+	sample := fmt.Sprintf("SELECT %s FROM %s", strings.Join(names, ", "), quoteTable(dataSource, tableName))
+	if sampleRows > 0 {
+		sample += fmt.Sprintf(" LIMIT %d", sampleRows)
+	}
+
+	selects := []string{"COUNT(*) AS sample_rows"}
+	for i, c := range columns {
+		selects = append(selects,
+			fmt.Sprintf("COUNT(%s) AS c%d_count", names[i], i),
+			fmt.Sprintf("COUNT(DISTINCT %s) AS c%d_distinct", names[i], i))
+		if kind := profileKind(c.Type); kind == profileKindNumber || kind == profileKindTime {
+			selects = append(selects,
+				fmt.Sprintf("MIN(%s) AS c%d_min", names[i], i),
+				fmt.Sprintf("MAX(%s) AS c%d_max", names[i], i))
+		}
+	}
+	rows, _, err := datasource.QueryReadOnly(ctx, dataSource, datasource.QueryOptions{Timeout: timeout},
+		fmt.Sprintf("SELECT %s FROM (%s) AS profile_sample", strings.Join(selects, ", "), sample))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("统计结果为空")
+	}
+	stats := rows[0]
+	total := profileInt(stats["sample_rows"])
+
+	now := time.Now()
+	profiles := make([]*model.ColumnProfile, len(columns))
+	for i := range columns {
+		notNull := profileInt(stats[fmt.Sprintf("c%d_count", i)])
+		p := &model.ColumnProfile{
+			SampleRows: total,
+			Distinct:   profileInt(stats[fmt.Sprintf("c%d_distinct", i)]),
+			Min:        profileString(stats[fmt.Sprintf("c%d_min", i)]),
+			Max:        profileString(stats[fmt.Sprintf("c%d_max", i)]),
+			ProfiledAt: now,
+		}
+		if total > 0 {
+			p.NullRatio = float64(total-notNull) / float64(total)
+		}
+		if p.Distinct > 0 && p.Distinct <= int64(maxDistinct) {
+			values, _, err := datasource.QueryReadOnly(ctx, dataSource, datasource.QueryOptions{Timeout: timeout},
+				fmt.Sprintf("SELECT %[1]s AS v, COUNT(*) AS n FROM (%[2]s) AS profile_sample WHERE %[1]s IS NOT NULL GROUP BY %[1]s ORDER BY n DESC", names[i], sample))
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range values {
+				p.Values = append(p.Values, profileString(row["v"]))
+			}
+		}
+		profiles[i] = p
+	}
+	return profiles, nil
+}
+
+// profileKind 按列类型判断分析方式
+func profileKind(typ string) string {
+	t := strings.ToLower(typ)
+	has := func(parts ...string) bool {
+		for _, p := range parts {
+			if strings.Contains(t, p) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has("blob", "binary", "bytea", "json", "xml", "geometry", "point", "polygon", "interval", "tsvector", "[]", "array"):
+		return profileKindSkip
+	case has("int", "dec", "numeric", "number", "float", "double", "real", "money", "serial"):
+		return profileKindNumber
+	case has("date", "time", "year"):
+		return profileKindTime
+	}
+	return profileKindText
+}
+
+// quoteIdentifier 按数据库类型给标识符加引号
+func quoteIdentifier(dbType, name string) string {
+	if dbType == "mysql" {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteTable 加引号的表名，PostgreSQL指定了schema时加上schema前缀
+func quoteTable(dataSource *model.DataSource, name string) string {
+	table := quoteIdentifier(dataSource.Type, name)
+	if dataSource.Type == "postgres" && dataSource.Schema != "" {
+		table = quoteIdentifier(dataSource.Type, dataSource.Schema) + "." + table
+	}
+	return table
+}
+
+func profileInt(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case int:
+		return int64(n)
+	case uint64:
+		return int64(n)
+	case float64:
+		return int64(n)
+	case []byte:
+		i, _ := strconv.ParseInt(string(n), 10, 64)
+		return i
+	case string:
+		i, _ := strconv.ParseInt(n, 10, 64)
+		return i
+	}
+	return 0
+}
+
+func profileString(v interface{}) string {
+	var s string
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		s = string(x)
+	case string:
+		s = x
+	case time.Time:
+		if x.Hour() == 0 && x.Minute() == 0 && x.Second() == 0 && x.Nanosecond() == 0 {
+			s = x.Format(time.DateOnly)
+		} else {
+			s = x.Format(time.DateTime)
+		}
+	case float64:
+		s = strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		s = strconv.FormatFloat(float64(x), 'f', -1, 32)
+	default:
+		s = fmt.Sprint(x)
+	}
+	return truncateRunes(s, profileValueMaxRunes)
+}
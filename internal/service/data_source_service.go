@@ -4,26 +4,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/yockii/dify_tools/internal/constant"
 	"github.com/yockii/dify_tools/internal/datasource"
 	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/config"
 	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/tabular"
 	"gorm.io/gorm"
 )
 
 type dataSourceService struct {
 	*BaseServiceImpl[*model.DataSource]
+	schemaKnowledgeService SchemaKnowledgeService
 }
 
-func NewDataSourceService() *dataSourceService {
+func NewDataSourceService(schemaKnowledgeService SchemaKnowledgeService) *dataSourceService {
 	srv := new(dataSourceService)
+	srv.schemaKnowledgeService = schemaKnowledgeService
 	srv.BaseServiceImpl = NewBaseService(BaseServiceConfig[*model.DataSource]{
 		NewModel:        srv.NewModel,
 		CheckDuplicate:  srv.CheckDuplicate,
 		DeleteCheck:     srv.DeleteCheck,
 		BuildCondition:  srv.BuildCondition,
 		ListOmitColumns: srv.ListOmitColumns,
+		UpdateHook:      srv.UpdateHook,
 	})
 	return srv
 }
@@ -52,6 +61,11 @@ func (s *dataSourceService) DeleteCheck(record *model.DataSource) error {
 	return nil
 }
 
+// UpdateHook 连接参数可能已变化，关闭旧的连接池
+func (s *dataSourceService) UpdateHook(ctx context.Context, record *model.DataSource) {
+	datasource.Invalidate(record.ID)
+}
+
 func (s *dataSourceService) ListOmitColumns() []string {
 	return []string{"password"}
 }
@@ -101,137 +115,156 @@ func (s *dataSourceService) Delete(ctx context.Context, id uint64) error {
 			logger.Error("删除字段信息失败", logger.F("error", err))
 			return constant.ErrDatabaseError
 		}
+		// 删除同步报告
+		if err := tx.Where("data_source_id = ?", record.ID).Delete(&model.SchemaSyncItem{}).Error; err != nil {
+			logger.Error("删除同步差异失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+		if err := tx.Where("data_source_id = ?", record.ID).Delete(&model.SchemaSyncReport{}).Error; err != nil {
+			logger.Error("删除同步报告失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+		// 删除预置查询
+		if err := tx.Where("data_source_id = ?", record.ID).Delete(&model.SavedQuery{}).Error; err != nil {
+			logger.Error("删除预置查询失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+		// 删除SQL示例
+		if err := tx.Where("data_source_id = ?", record.ID).Delete(&model.SqlExample{}).Error; err != nil {
+			logger.Error("删除SQL示例失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
 		return nil
 	}); err != nil {
 		return err
 	}
+	datasource.Invalidate(record.ID)
+	// 从知识库中删除已发布的数据字典
+	go s.unpublishDictionary(record.ID)
+	// 导入生成的SQLite文件随数据源删除
+	if record.Type == "sqlite" && record.Database == importFileName(record.ID) {
+		if path, err := datasource.SQLitePath(record.Database); err == nil {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				logger.Warn("删除导入的SQLite文件失败", logger.F("path", path), logger.F("error", err))
+			}
+		}
+	}
 	return nil
 }
 
-func (s *dataSourceService) Sync(ctx context.Context, id uint64) error {
-	// 查询数据源
-	var dataSource model.DataSource
-	if err := s.db.First(&dataSource, "id = ?", id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return constant.ErrRecordNotFound
-		}
-		logger.Error("查询数据源失败", logger.F("error", err))
-		return constant.ErrDatabaseError
-	}
+// importFileName 导入数据源对应的SQLite文件名
+func importFileName(id uint64) string {
+	return fmt.Sprintf("import_%d.db", id)
+}
 
-	db, err := datasource.GetDB(&dataSource)
+// Import 将CSV/XLSX文件导入为SQLite数据源并同步表结构，XLSX的每个工作表对应一张表；
+// dataSource.ID 不为0时使用新文件替换该导入数据源的数据，否则新建数据源
+func (s *dataSourceService) Import(ctx context.Context, dataSource *model.DataSource, fileHeader *multipart.FileHeader) error {
+	// 导入的数据源必须属于某个应用
+	if dataSource.ApplicationID == 0 {
+		return constant.ErrInvalidParams
+	}
+	file, err := fileHeader.Open()
 	if err != nil {
-		return err
+		logger.Error("打开上传文件失败", logger.F("error", err))
+		return constant.ErrInternalError
 	}
+	defer file.Close()
 
-	// 查询表信息
-	type TempTableInfo struct {
-		TableName    string
-		TableComment string
-	}
-	var tables []TempTableInfo
-	switch dataSource.Type {
-	case "mysql":
-		err = db.Raw("SELECT table_name AS table_name, table_comment AS table_comment FROM information_schema.tables WHERE table_schema = ?", dataSource.Database).Scan(&tables).Error
-	case "postgres":
-		err = db.Raw("SELECT table_name, obj_description(table_name::regclass) AS table_comment FROM information_schema.tables WHERE table_catalog = ? AND table_schema = ?", dataSource.Database, dataSource.Schema).Scan(&tables).Error
-	default:
-		err = fmt.Errorf("unsupported database type: %s", dataSource.Type)
-	}
+	sheets, err := tabular.Read(fileHeader.Filename, file, config.GetInt("datasource.import_max_rows"))
 	if err != nil {
-		logger.Error("查询表信息失败", logger.F("error", err))
-		return constant.ErrDatabaseError
+		switch {
+		case errors.Is(err, tabular.ErrUnsupportedFormat):
+			return constant.ErrUnsupportedFileType
+		case errors.Is(err, tabular.ErrEmptySheet):
+			return constant.ErrImportEmpty
+		case errors.Is(err, tabular.ErrTooManyRows):
+			return constant.ErrImportTooManyRows
+		}
+		logger.Error("解析导入文件失败", logger.F("file", fileHeader.Filename), logger.F("error", err))
+		return constant.ErrInvalidParams
 	}
 
-	var tableInfos []*model.TableInfo
-	for _, table := range tables {
-		tableInfos = append(tableInfos, &model.TableInfo{
-			ApplicationID: dataSource.ApplicationID,
-			DataSourceID:  dataSource.ID,
-			Name:          table.TableName,
-			Comment:       table.TableComment,
-		})
+	created := false
+	if dataSource.ID != 0 {
+		var record model.DataSource
+		if err := s.db.First(&record, "id = ?", dataSource.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return constant.ErrRecordNotFound
+			}
+			logger.Error("查询数据源失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+		// 只能替换同一应用下由导入生成的数据源
+		if record.ApplicationID != dataSource.ApplicationID || record.Type != "sqlite" || record.Database != importFileName(record.ID) {
+			return constant.ErrInvalidOperation
+		}
+		*dataSource = record
+	} else {
+		dataSource.Type = "sqlite"
+		if dataSource.Name == "" {
+			name := []rune(strings.TrimSuffix(filepath.Base(fileHeader.Filename), filepath.Ext(fileHeader.Filename)))
+			dataSource.Name = string(name[:min(len(name), 50)])
+		}
+		if err := s.Create(ctx, dataSource); err != nil {
+			return err
+		}
+		dataSource.Database = importFileName(dataSource.ID)
+		if err := s.db.Model(dataSource).Update("database", dataSource.Database).Error; err != nil {
+			logger.Error("更新数据源失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+		created = true
 	}
 
-	err = s.db.Transaction(func(tx *gorm.DB) error {
-		err := tx.Create(&tableInfos).Error
-		if err != nil {
-			logger.Error("创建表信息失败", logger.F("error", err))
-			return constant.ErrDatabaseError
+	if err := datasource.Materialize(dataSource.ID, dataSource.Database, sheets); err != nil {
+		logger.Error("写入SQLite文件失败", logger.F("file", fileHeader.Filename), logger.F("error", err))
+		if created {
+			s.db.Delete(dataSource)
 		}
+		return constant.ErrInternalError
+	}
 
-		for _, table := range tableInfos {
-			if err := tx.Where(&model.TableInfo{
-				DataSourceID: dataSource.ID,
-				Name:         table.Name,
-			}).Assign(table).FirstOrCreate(table).Error; err != nil {
-				logger.Error("同步表信息失败", logger.F("error", err))
-				return constant.ErrDatabaseError
-			}
-			// // 查询表信息
-			// var tableInfo model.TableInfo
-			// if err := tx.First(&tableInfo, "data_source_id = ? AND name = ?", dataSource.ID, table.Name).Error; err != nil {
-			// 	logger.Error("查询表信息失败", logger.F("error", err))
-			// 	return fmt.Errorf("查询表信息失败: %v", err)
-			// }
-			// if tableInfo.ID == 0 {
-			// 	tx.Create(table)
-			// } else {
-			// 	tx.Model(&tableInfo).Updates(table)
-			// 	table.ID = tableInfo.ID
-			// }
-		}
-
-		migrator := db.Migrator()
-		var columnInfos []*model.ColumnInfo
-		for _, table := range tableInfos {
-			ct, err := migrator.ColumnTypes(table.Name)
-			if err != nil {
-				logger.Error("查询列信息失败", logger.F("error", err))
-				return constant.ErrDatabaseError
+	// 导入的数据供应用直接查询，新建的表开放给AI；替换数据时已有表的开放设置不变
+	_, err = s.sync(ctx, dataSource.ID, true)
+	return err
+}
+
+// Test 测试数据源连接，已保存的数据源未传入的连接参数使用已保存的值；
+// 类型、主机、端口及用户名都与已保存的一致时才使用已保存的密码，否则需传入密码
+func (s *dataSourceService) Test(ctx context.Context, dataSource *model.DataSource) (*datasource.TestResult, error) {
+	if dataSource.ID != 0 {
+		var record model.DataSource
+		if err := s.db.First(&record, "id = ?", dataSource.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, constant.ErrRecordNotFound
 			}
-			for _, c := range ct {
-				t, _ := c.ColumnType()
-				size, _ := c.Length()
-				precision, scale, _ := c.DecimalSize()
-				nullable, _ := c.Nullable()
-				defaultValue, _ := c.DefaultValue()
-				comment, _ := c.Comment()
-				columnInfos = append(columnInfos, &model.ColumnInfo{
-					ApplicationID: dataSource.ApplicationID,
-					DataSourceID:  dataSource.ID,
-					TableID:       table.ID,
-					Name:          c.Name(),
-					Type:          t,
-					Size:          size,
-					Precision:     precision,
-					Scale:         scale,
-					Nullable:      nullable,
-					DefaultValue:  defaultValue,
-					Comment:       comment,
-				})
+			logger.Error("查询数据源失败", logger.F("error", err))
+			return nil, constant.ErrDatabaseError
+		}
+		fill := func(dst *string, v string) {
+			if *dst == "" {
+				*dst = v
 			}
 		}
-
-		for _, column := range columnInfos {
-			// 查询字段信息
-			if err := tx.Where(&model.ColumnInfo{
-				DataSourceID: dataSource.ID,
-				TableID:      column.TableID,
-				Name:         column.Name,
-			}).Assign(column).FirstOrCreate(column).Error; err != nil {
-				logger.Error("同步字段信息失败", logger.F("error", err))
-				return constant.ErrDatabaseError
+		fill(&dataSource.Type, record.Type)
+		fill(&dataSource.Host, record.Host)
+		fill(&dataSource.User, record.User)
+		fill(&dataSource.Database, record.Database)
+		fill(&dataSource.Schema, record.Schema)
+		if dataSource.Port == 0 {
+			dataSource.Port = record.Port
+		}
+		// 连接的服务器或账号变化时不使用已保存的密码，避免将其发送到其他服务器
+		if dataSource.Password == "" {
+			if dataSource.Type != record.Type || dataSource.Host != record.Host ||
+				dataSource.Port != record.Port || dataSource.User != record.User {
+				return nil, constant.ErrPasswordRequired
 			}
+			dataSource.Password = record.Password
 		}
-		return nil
-	})
-
-	if err != nil {
-		return constant.ErrDatabaseError
 	}
-
-	return nil
+	return datasource.Test(ctx, dataSource), nil
 }
 
 func (s *dataSourceService) ListForDify(ctx context.Context, condition *model.DataSource) ([]*model.DataSource, error) {
@@ -0,0 +1,766 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/datasource"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/masking"
+	"gorm.io/gorm"
+)
+
+// sourceTable 从数据库读取的表结构
+type sourceTable struct {
+	Name    string
+	Comment string
+	Kind    string
+	Indexes []model.Index
+	Columns []*model.ColumnInfo
+}
+
+// Sync 同步数据源表结构：与已保存的表/列信息逐项比较，新增、类型变化及注释变化直接应用，
+// 数据库中已不存在的表/列记为待确认的删除，由管理员在同步报告中确认或拒绝。
+// 人工修改过的注释、开放设置、行级过滤及脱敏规则不会被覆盖。新增的列会识别是否为敏感信息，识别出的列确认前不提供给AI
+func (s *dataSourceService) Sync(ctx context.Context, id uint64) (*model.SchemaSyncReport, error) {
+	return s.sync(ctx, id, false)
+}
+
+// sync 同步表结构，exposeNew 为true时新建的表直接开放给AI（用于导入的数据源）
+func (s *dataSourceService) sync(ctx context.Context, id uint64, exposeNew bool) (*model.SchemaSyncReport, error) {
+	// 查询数据源
+	var dataSource model.DataSource
+	if err := s.db.First(&dataSource, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, constant.ErrRecordNotFound
+		}
+		logger.Error("查询数据源失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+
+	db, err := datasource.GetDB(&dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, keysLoaded, err := s.readSchema(&dataSource, db)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.applySchema(&dataSource, tables, keysLoaded, exposeNew)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先识别敏感信息，待确认的列不参与取值分析，识别失败不影响同步结果
+	if config.GetBool("pii.enabled") {
+		report.Classified = s.classify(ctx, &dataSource)
+		if err := s.db.Model(report).Update("classified", report.Classified).Error; err != nil {
+			logger.Error("更新同步报告失败", logger.F("error", err))
+		}
+	}
+
+	// 表结构更新后再分析取值分布，分析失败不影响同步结果
+	if dataSource.Profiling == 1 {
+		report.Profiled = s.profile(ctx, &dataSource)
+		if err := s.db.Model(report).Update("profiled", report.Profiled).Error; err != nil {
+			logger.Error("更新同步报告失败", logger.F("error", err))
+		}
+	} else {
+		s.clearProfiles(dataSource.ID)
+	}
+
+	// 数据字典的上传耗时较长，在后台刷新
+	if dataSource.PublishDict == 1 {
+		go s.publishDictionary(dataSource.ID)
+	} else {
+		go s.unpublishDictionary(dataSource.ID)
+	}
+	return report, nil
+}
+
+// applySchema 在事务中应用表结构差异并保存同步报告
+func (s *dataSourceService) applySchema(dataSource *model.DataSource, tables []*sourceTable, keysLoaded, exposeNew bool) (*model.SchemaSyncReport, error) {
+	report := &model.SchemaSyncReport{
+		ApplicationID: dataSource.ApplicationID,
+		DataSourceID:  dataSource.ID,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		diff, err := newSchemaDiff(tx, dataSource, keysLoaded)
+		if err != nil {
+			logger.Error("查询已同步的表结构失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+		diff.exposeNew = exposeNew
+		if err := diff.apply(tables); err != nil {
+			logger.Error("同步表结构失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+		if err := diff.supersede(); err != nil {
+			logger.Error("更新已失效的删除失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+
+		for _, item := range diff.items {
+			switch item.ChangeType {
+			case model.SyncChangeAdded:
+				report.Added++
+			case model.SyncChangeRemoved:
+				report.Removed++
+				report.Pending++
+			case model.SyncChangeTypeChanged:
+				report.TypeChanged++
+			case model.SyncChangeCommentChanged:
+				report.CommentChanged++
+			}
+		}
+		if err := tx.Create(report).Error; err != nil {
+			logger.Error("创建同步报告失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+		for _, item := range diff.items {
+			item.ReportID = report.ID
+		}
+		if len(diff.items) > 0 {
+			if err := tx.CreateInBatches(diff.items, 100).Error; err != nil {
+				logger.Error("创建同步差异失败", logger.F("error", err))
+				return constant.ErrDatabaseError
+			}
+		}
+		if err := tx.Model(dataSource).Update("sync_time", time.Now()).Error; err != nil {
+			logger.Error("更新同步时间失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+		report.Items = diff.items
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// publishDictionary 将数据字典发布到应用的公共知识库，失败只记录日志
+func (s *dataSourceService) publishDictionary(dataSourceID uint64) {
+	result, err := s.schemaKnowledgeService.Publish(context.Background(), dataSourceID)
+	if err != nil {
+		logger.Warn("发布数据字典失败", logger.F("dataSourceId", dataSourceID), logger.F("error", err))
+		return
+	}
+	if result.Failed > 0 {
+		logger.Warn("部分数据字典文档发布失败", logger.F("dataSourceId", dataSourceID), logger.F("failed", result.Failed))
+	}
+}
+
+// unpublishDictionary 删除已发布的数据字典，未发布过时不做任何操作
+func (s *dataSourceService) unpublishDictionary(dataSourceID uint64) {
+	if _, err := s.schemaKnowledgeService.Unpublish(context.Background(), dataSourceID); err != nil {
+		logger.Warn("删除数据字典文档失败", logger.F("dataSourceId", dataSourceID), logger.F("error", err))
+	}
+}
+
+// readSchema 从数据库读取表、视图及列结构，keysLoaded 表示是否成功读取了主键、索引及外键
+func (s *dataSourceService) readSchema(dataSource *model.DataSource, db *gorm.DB) (result []*sourceTable, keysLoaded bool, err error) {
+	// 查询表信息
+	type TempTableInfo struct {
+		TableName    string
+		TableComment string
+		TableType    string
+	}
+	var tables []TempTableInfo
+	switch dataSource.Type {
+	case "mysql":
+		err = db.Raw("SELECT table_name AS table_name, table_comment AS table_comment, table_type AS table_type FROM information_schema.tables WHERE table_schema = ?", dataSource.Database).Scan(&tables).Error
+	case "postgres":
+		err = db.Raw("SELECT table_name, obj_description(format('%I.%I', table_schema, table_name)::regclass) AS table_comment, table_type FROM information_schema.tables WHERE table_catalog = ? AND table_schema = ?", dataSource.Database, dataSource.Schema).Scan(&tables).Error
+	case "sqlite":
+		err = db.Raw("SELECT name AS table_name, type AS table_type FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite\\_%' ESCAPE '\\' AND name <> ?", datasource.SQLiteCommentTable).Scan(&tables).Error
+	default:
+		err = fmt.Errorf("unsupported database type: %s", dataSource.Type)
+	}
+	if err != nil {
+		logger.Error("查询表信息失败", logger.F("error", err))
+		return nil, false, constant.ErrDatabaseError
+	}
+
+	// SQLite没有注释语法，从附加表读取
+	var sqliteComments map[string]map[string]string
+	if dataSource.Type == "sqlite" {
+		sqliteComments = s.sqliteComments(db)
+		for i := range tables {
+			tables[i].TableComment = sqliteComments[tables[i].TableName][""]
+		}
+	}
+
+	migrator := db.Migrator()
+	for _, table := range tables {
+		st := &sourceTable{
+			Name:    table.TableName,
+			Comment: table.TableComment,
+			Kind:    "table",
+		}
+		if strings.Contains(strings.ToUpper(table.TableType), "VIEW") {
+			st.Kind = "view"
+		}
+		if dataSource.Type == "sqlite" {
+			if st.Columns, err = s.sqliteColumns(db, table.TableName, sqliteComments[table.TableName]); err != nil {
+				logger.Error("查询列信息失败", logger.F("error", err))
+				return nil, false, constant.ErrDatabaseError
+			}
+			result = append(result, st)
+			continue
+		}
+		ct, err := migrator.ColumnTypes(table.TableName)
+		if err != nil {
+			logger.Error("查询列信息失败", logger.F("error", err))
+			return nil, false, constant.ErrDatabaseError
+		}
+		for _, c := range ct {
+			t, _ := c.ColumnType()
+			size, _ := c.Length()
+			precision, scale, _ := c.DecimalSize()
+			nullable, _ := c.Nullable()
+			defaultValue, _ := c.DefaultValue()
+			comment, _ := c.Comment()
+			st.Columns = append(st.Columns, &model.ColumnInfo{
+				Name:         c.Name(),
+				Type:         t,
+				Size:         size,
+				Precision:    precision,
+				Scale:        scale,
+				Nullable:     nullable,
+				DefaultValue: defaultValue,
+				Comment:      comment,
+			})
+		}
+		result = append(result, st)
+	}
+
+	// 主键、索引及外键读取失败（如账号无权限）时不影响表结构同步
+	if err := s.readKeys(dataSource, db, result); err != nil {
+		logger.Warn("查询主键、索引及外键失败", logger.F("dataSourceId", dataSource.ID), logger.F("error", err))
+		return result, false, nil
+	}
+	return result, true, nil
+}
+
+// indexColumn 索引中的一列
+type indexColumn struct {
+	TableName  string
+	IndexName  string
+	IsUnique   bool
+	IsPrimary  bool
+	ColumnName string
+}
+
+// foreignKeyColumn 外键中的一列
+type foreignKeyColumn struct {
+	TableName  string
+	ColumnName string
+	RefTable   string
+	RefColumn  string
+}
+
+// readKeys 读取主键、索引及外键，MySQL及PostgreSQL的外键来自 information_schema
+func (s *dataSourceService) readKeys(dataSource *model.DataSource, db *gorm.DB, tables []*sourceTable) error {
+	var indexes []indexColumn
+	var foreignKeys []foreignKeyColumn
+	switch dataSource.Type {
+	case "mysql":
+		if err := db.Raw("SELECT table_name AS table_name, index_name AS index_name, non_unique = 0 AS is_unique, index_name = 'PRIMARY' AS is_primary, column_name AS column_name "+
+			"FROM information_schema.statistics WHERE table_schema = ? AND column_name IS NOT NULL ORDER BY table_name, index_name, seq_in_index", dataSource.Database).Scan(&indexes).Error; err != nil {
+			return err
+		}
+		if err := db.Raw("SELECT table_name AS table_name, column_name AS column_name, referenced_table_name AS ref_table, referenced_column_name AS ref_column "+
+			"FROM information_schema.key_column_usage WHERE table_schema = ? AND referenced_table_schema = table_schema AND referenced_table_name IS NOT NULL "+
+			"ORDER BY table_name, constraint_name, ordinal_position", dataSource.Database).Scan(&foreignKeys).Error; err != nil {
+			return err
+		}
+	case "postgres":
+		// information_schema 不包含普通索引，索引从 pg_index 读取
+		if err := db.Raw("SELECT t.relname AS table_name, i.relname AS index_name, ix.indisunique AS is_unique, ix.indisprimary AS is_primary, a.attname AS column_name "+
+			"FROM pg_index ix JOIN pg_class t ON t.oid = ix.indrelid JOIN pg_class i ON i.oid = ix.indexrelid JOIN pg_namespace n ON n.oid = t.relnamespace "+
+			"CROSS JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum "+
+			"WHERE n.nspname = ? ORDER BY t.relname, i.relname, k.ord", dataSource.Schema).Scan(&indexes).Error; err != nil {
+			return err
+		}
+		if err := db.Raw("SELECT kcu.table_name, kcu.column_name, ref.table_name AS ref_table, ref.column_name AS ref_column "+
+			"FROM information_schema.referential_constraints rc "+
+			"JOIN information_schema.key_column_usage kcu ON kcu.constraint_schema = rc.constraint_schema AND kcu.constraint_name = rc.constraint_name "+
+			"JOIN information_schema.key_column_usage ref ON ref.constraint_schema = rc.unique_constraint_schema AND ref.constraint_name = rc.unique_constraint_name AND ref.ordinal_position = kcu.position_in_unique_constraint "+
+			"WHERE kcu.table_schema = ? AND ref.table_schema = kcu.table_schema ORDER BY kcu.table_name, kcu.constraint_name, kcu.ordinal_position", dataSource.Schema).Scan(&foreignKeys).Error; err != nil {
+			return err
+		}
+	case "sqlite":
+		for _, t := range tables {
+			var rows []indexColumn
+			if err := db.Raw("SELECT ? AS table_name, il.name AS index_name, il.\"unique\" AS is_unique, il.origin = 'pk' AS is_primary, ii.name AS column_name "+
+				"FROM pragma_index_list(?) il JOIN pragma_index_info(il.name) ii ORDER BY il.seq, ii.seqno", t.Name, t.Name).Scan(&rows).Error; err != nil {
+				return err
+			}
+			indexes = append(indexes, rows...)
+			var fks []foreignKeyColumn
+			if err := db.Raw("SELECT ? AS table_name, \"from\" AS column_name, \"table\" AS ref_table, coalesce(\"to\", '') AS ref_column FROM pragma_foreign_key_list(?) ORDER BY id, seq", t.Name, t.Name).Scan(&fks).Error; err != nil {
+				return err
+			}
+			foreignKeys = append(foreignKeys, fks...)
+		}
+	}
+
+	byName := make(map[string]*sourceTable, len(tables))
+	for _, t := range tables {
+		byName[t.Name] = t
+	}
+	for _, ic := range indexes {
+		t := byName[ic.TableName]
+		if t == nil {
+			continue
+		}
+		if ic.IsPrimary {
+			if c := t.column(ic.ColumnName); c != nil {
+				c.PrimaryKey = true
+			}
+			continue
+		}
+		if n := len(t.Indexes); n > 0 && t.Indexes[n-1].Name == ic.IndexName {
+			t.Indexes[n-1].Columns = append(t.Indexes[n-1].Columns, ic.ColumnName)
+		} else {
+			t.Indexes = append(t.Indexes, model.Index{Name: ic.IndexName, Columns: []string{ic.ColumnName}, Unique: ic.IsUnique})
+		}
+	}
+	for _, fk := range foreignKeys {
+		t := byName[fk.TableName]
+		if t == nil {
+			continue
+		}
+		c := t.column(fk.ColumnName)
+		if c == nil {
+			continue
+		}
+		c.RefTable, c.RefColumn = fk.RefTable, fk.RefColumn
+		// SQLite外键省略引用列时引用的是主键
+		if c.RefColumn == "" {
+			if ref := byName[fk.RefTable]; ref != nil {
+				for _, rc := range ref.Columns {
+					if rc.PrimaryKey {
+						c.RefColumn = rc.Name
+						break
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (t *sourceTable) column(name string) *model.ColumnInfo {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// sqliteComments 读取SQLite附加表中的注释，返回 表名 → 列名 → 注释，列名为空表示表注释
+func (s *dataSourceService) sqliteComments(db *gorm.DB) map[string]map[string]string {
+	comments := make(map[string]map[string]string)
+	if !db.Migrator().HasTable(datasource.SQLiteCommentTable) {
+		return comments
+	}
+	var rows []struct {
+		TableName  string
+		ColumnName sql.NullString
+		Comment    string
+	}
+	if err := db.Table(datasource.SQLiteCommentTable).Select("table_name", "column_name", "comment").Scan(&rows).Error; err != nil {
+		logger.Warn("读取SQLite注释表失败", logger.F("error", err))
+		return comments
+	}
+	for _, row := range rows {
+		if comments[row.TableName] == nil {
+			comments[row.TableName] = make(map[string]string)
+		}
+		comments[row.TableName][row.ColumnName.String] = row.Comment
+	}
+	return comments
+}
+
+// sqliteColumns 通过 PRAGMA table_info 获取SQLite表的列信息
+func (s *dataSourceService) sqliteColumns(db *gorm.DB, table string, comments map[string]string) ([]*model.ColumnInfo, error) {
+	var columns []struct {
+		Name      string
+		Type      string
+		NotNull   int
+		DfltValue sql.NullString
+		Pk        int
+	}
+	if err := db.Raw("SELECT name, type, \"notnull\" AS not_null, dflt_value, pk FROM pragma_table_info(?)", table).Scan(&columns).Error; err != nil {
+		return nil, err
+	}
+
+	var columnInfos []*model.ColumnInfo
+	for _, c := range columns {
+		// 类型中的长度/精度，如 VARCHAR(50)、DECIMAL(10,2)
+		columnType, size, precision, scale := c.Type, int64(0), int64(0), int64(0)
+		if i := strings.IndexByte(c.Type, '('); i > 0 && strings.HasSuffix(c.Type, ")") {
+			columnType = strings.TrimSpace(c.Type[:i])
+			args := strings.Split(c.Type[i+1:len(c.Type)-1], ",")
+			size, _ = strconv.ParseInt(strings.TrimSpace(args[0]), 10, 64)
+			if len(args) > 1 {
+				precision = size
+				scale, _ = strconv.ParseInt(strings.TrimSpace(args[1]), 10, 64)
+			}
+		}
+		columnInfos = append(columnInfos, &model.ColumnInfo{
+			Name:         c.Name,
+			Type:         strings.ToLower(columnType),
+			Size:         size,
+			Precision:    precision,
+			Scale:        scale,
+			Nullable:     c.NotNull == 0,
+			DefaultValue: c.DfltValue.String,
+			Comment:      comments[c.Name],
+			PrimaryKey:   c.Pk > 0,
+		})
+	}
+	return columnInfos, nil
+}
+
+// schemaDiff 比较数据库表结构与已保存的表/列信息，并在事务中应用差异
+type schemaDiff struct {
+	tx         *gorm.DB
+	dataSource *model.DataSource
+	keysLoaded bool                                    // 是否读取到了主键、索引及外键，未读取到时保留原有信息
+	tables     map[string]*model.TableInfo             // 表名 → 表信息
+	columns    map[uint64]map[string]*model.ColumnInfo // 表ID → 列名 → 列信息
+	removals   map[[2]uint64][]*model.SchemaSyncItem   // 已有待确认或已拒绝删除的表/列，不重复报告
+	exposeNew  bool                                    // 新建的表是否开放给AI
+	items      []*model.SchemaSyncItem
+	superseded []*model.SchemaSyncItem // 表/列已重新出现，需置为失效的删除
+}
+
+func newSchemaDiff(tx *gorm.DB, dataSource *model.DataSource, keysLoaded bool) (*schemaDiff, error) {
+	d := &schemaDiff{
+		tx:         tx,
+		dataSource: dataSource,
+		keysLoaded: keysLoaded,
+		tables:     make(map[string]*model.TableInfo),
+		columns:    make(map[uint64]map[string]*model.ColumnInfo),
+		removals:   make(map[[2]uint64][]*model.SchemaSyncItem),
+	}
+
+	var tables []*model.TableInfo
+	if err := tx.Where("data_source_id = ?", dataSource.ID).Order("created_at, id").Find(&tables).Error; err != nil {
+		return nil, err
+	}
+	// 早期版本同步时会产生重复的表信息，保留有人工设置的一条，其余连同列信息删除
+	var duplicateTables []uint64
+	for _, t := range tables {
+		kept, ok := d.tables[t.Name]
+		if !ok {
+			d.tables[t.Name] = t
+			continue
+		}
+		if tableAnnotated(t) && !tableAnnotated(kept) || tableAnnotated(t) == tableAnnotated(kept) && t.UpdatedAt.After(kept.UpdatedAt) {
+			d.tables[t.Name], t = t, kept
+		}
+		duplicateTables = append(duplicateTables, t.ID)
+	}
+	if len(duplicateTables) > 0 {
+		if err := tx.Where("table_id IN ?", duplicateTables).Delete(&model.ColumnInfo{}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Where("id IN ?", duplicateTables).Delete(&model.TableInfo{}).Error; err != nil {
+			return nil, err
+		}
+		logger.Info("清理重复的表信息", logger.F("dataSourceId", dataSource.ID), logger.F("count", len(duplicateTables)))
+	}
+
+	var columns []*model.ColumnInfo
+	if err := tx.Where("data_source_id = ?", dataSource.ID).Order("created_at, id").Find(&columns).Error; err != nil {
+		return nil, err
+	}
+	var duplicateColumns []uint64
+	for _, c := range columns {
+		byName := d.columns[c.TableID]
+		if byName == nil {
+			byName = make(map[string]*model.ColumnInfo)
+			d.columns[c.TableID] = byName
+		}
+		kept, ok := byName[c.Name]
+		if !ok {
+			byName[c.Name] = c
+			continue
+		}
+		if columnAnnotated(c) && !columnAnnotated(kept) || columnAnnotated(c) == columnAnnotated(kept) && c.UpdatedAt.After(kept.UpdatedAt) {
+			byName[c.Name], c = c, kept
+		}
+		duplicateColumns = append(duplicateColumns, c.ID)
+	}
+	if len(duplicateColumns) > 0 {
+		if err := tx.Where("id IN ?", duplicateColumns).Delete(&model.ColumnInfo{}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var removals []*model.SchemaSyncItem
+	if err := tx.Select("ID", "ReportID", "TableID", "ColumnID", "Status").
+		Where("data_source_id = ? AND change_type = ? AND status IN ?", dataSource.ID, model.SyncChangeRemoved,
+			[]int{model.SyncItemStatusPending, model.SyncItemStatusRejected}).
+		Find(&removals).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range removals {
+		key := [2]uint64{item.TableID, item.ColumnID}
+		d.removals[key] = append(d.removals[key], item)
+	}
+	return d, nil
+}
+
+func tableAnnotated(t *model.TableInfo) bool {
+	return t.ExposedToAI == 1 || t.RowFilter != ""
+}
+
+func columnAnnotated(c *model.ColumnInfo) bool {
+	return masking.IsMasked(c.MaskType)
+}
+
+func (d *schemaDiff) apply(tables []*sourceTable) error {
+	seen := make(map[string]bool)
+	for _, st := range tables {
+		seen[st.Name] = true
+		table, ok := d.tables[st.Name]
+		if !ok {
+			table = &model.TableInfo{
+				ApplicationID: d.dataSource.ApplicationID,
+				DataSourceID:  d.dataSource.ID,
+				Name:          st.Name,
+				Comment:       st.Comment,
+				SourceComment: st.Comment,
+				Kind:          st.Kind,
+				Indexes:       st.Indexes,
+			}
+			if d.exposeNew {
+				table.ExposedToAI = 1
+			}
+			if err := d.tx.Create(table).Error; err != nil {
+				return err
+			}
+			d.addItem(table, nil, model.SyncChangeAdded, "", st.Comment)
+		} else {
+			d.reappear(table, nil)
+			comment, changed := mergeComment(table.Comment, table.SourceComment, st.Comment)
+			if changed {
+				d.addItem(table, nil, model.SyncChangeCommentChanged, table.SourceComment, st.Comment)
+			}
+			indexes := table.Indexes
+			if d.keysLoaded {
+				indexes = st.Indexes
+			}
+			if comment != table.Comment || table.SourceComment != st.Comment || table.Kind != st.Kind || !reflect.DeepEqual(indexes, table.Indexes) {
+				table.Comment, table.SourceComment, table.Kind, table.Indexes = comment, st.Comment, st.Kind, indexes
+				if err := d.tx.Model(table).Select("Comment", "SourceComment", "Kind", "Indexes").Updates(table).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if err := d.applyColumns(table, st.Columns, !ok); err != nil {
+			return err
+		}
+	}
+
+	// 数据库中已不存在的表
+	for name, table := range d.tables {
+		if !seen[name] {
+			d.addRemoval(table, nil)
+		}
+	}
+	return nil
+}
+
+// applyColumns 同步一张表的列，新建的表不逐列报告新增
+func (d *schemaDiff) applyColumns(table *model.TableInfo, columns []*model.ColumnInfo, newTable bool) error {
+	existing := d.columns[table.ID]
+	seen := make(map[string]bool)
+	for _, c := range columns {
+		seen[c.Name] = true
+		column, ok := existing[c.Name]
+		if !ok {
+			c.ApplicationID = table.ApplicationID
+			c.DataSourceID = table.DataSourceID
+			c.TableID = table.ID
+			c.SourceComment = c.Comment
+			if err := d.tx.Create(c).Error; err != nil {
+				return err
+			}
+			if !newTable {
+				d.addItem(table, c, model.SyncChangeAdded, "", columnTypeSignature(c))
+			}
+			continue
+		}
+
+		d.reappear(table, column)
+		updated := false
+		if oldType, newType := columnTypeSignature(column), columnTypeSignature(c); oldType != newType {
+			d.addItem(table, column, model.SyncChangeTypeChanged, oldType, newType)
+			updated = true
+		}
+		if column.DefaultValue != c.DefaultValue {
+			updated = true
+		}
+		if !d.keysLoaded {
+			c.PrimaryKey, c.RefTable, c.RefColumn = column.PrimaryKey, column.RefTable, column.RefColumn
+		} else if column.PrimaryKey != c.PrimaryKey || column.RefTable != c.RefTable || column.RefColumn != c.RefColumn {
+			updated = true
+		}
+		comment, changed := mergeComment(column.Comment, column.SourceComment, c.Comment)
+		if changed {
+			d.addItem(table, column, model.SyncChangeCommentChanged, column.SourceComment, c.Comment)
+		}
+		if comment != column.Comment || column.SourceComment != c.Comment {
+			updated = true
+		}
+		if !updated {
+			continue
+		}
+		column.Type, column.Size, column.Precision, column.Scale = c.Type, c.Size, c.Precision, c.Scale
+		column.Nullable, column.DefaultValue = c.Nullable, c.DefaultValue
+		column.Comment, column.SourceComment = comment, c.Comment
+		column.PrimaryKey, column.RefTable, column.RefColumn = c.PrimaryKey, c.RefTable, c.RefColumn
+		if err := d.tx.Model(column).
+			Select("Type", "Size", "Precision", "Scale", "Nullable", "DefaultValue", "Comment", "SourceComment", "PrimaryKey", "RefTable", "RefColumn").
+			Updates(column).Error; err != nil {
+			return err
+		}
+	}
+
+	// 数据库中已不存在的列
+	for name, column := range existing {
+		if !seen[name] {
+			d.addRemoval(table, column)
+		}
+	}
+	return nil
+}
+
+func (d *schemaDiff) addItem(table *model.TableInfo, column *model.ColumnInfo, changeType, oldValue, newValue string) {
+	item := &model.SchemaSyncItem{
+		DataSourceID: d.dataSource.ID,
+		TableID:      table.ID,
+		TableName:    table.Name,
+		ChangeType:   changeType,
+		OldValue:     truncateRunes(oldValue, 500),
+		NewValue:     truncateRunes(newValue, 500),
+		Status:       model.SyncItemStatusApplied,
+	}
+	if column != nil {
+		item.ColumnID = column.ID
+		item.ColumnName = column.Name
+	}
+	d.items = append(d.items, item)
+}
+
+// addRemoval 记录待确认的删除，已有待确认或已拒绝的同一删除时不再重复记录
+func (d *schemaDiff) addRemoval(table *model.TableInfo, column *model.ColumnInfo) {
+	key := [2]uint64{table.ID, 0}
+	oldValue := table.Comment
+	if column != nil {
+		key[1] = column.ID
+		oldValue = columnTypeSignature(column)
+	}
+	if len(d.removals[key]) > 0 {
+		return
+	}
+	d.addItem(table, column, model.SyncChangeRemoved, oldValue, "")
+	d.items[len(d.items)-1].Status = model.SyncItemStatusPending
+}
+
+// mergeComment 合并数据库中的注释，返回合并后的注释及数据库注释是否有变化，人工修改过的注释保持不变
+func mergeComment(comment, sourceComment, newSource string) (string, bool) {
+	if newSource == sourceComment {
+		return comment, false
+	}
+	// 早期同步的记录没有保存数据库注释，注释与数据库一致时视为未变化
+	if sourceComment == "" && comment == newSource {
+		return comment, false
+	}
+	if comment == sourceComment {
+		return newSource, true
+	}
+	return comment, true
+}
+
+// columnTypeSignature 列类型的比较形式，如 varchar(50) NOT NULL、decimal(10,2)
+func columnTypeSignature(c *model.ColumnInfo) string {
+	sig := c.Type
+	if !strings.Contains(sig, "(") {
+		switch t := strings.ToLower(sig); {
+		case c.Scale > 0 || (c.Precision > 0 && (t == "decimal" || t == "numeric")):
+			sig += fmt.Sprintf("(%d,%d)", c.Precision, c.Scale)
+		case c.Size > 0:
+			sig += fmt.Sprintf("(%d)", c.Size)
+		}
+	}
+	if !c.Nullable {
+		sig += " NOT NULL"
+	}
+	return sig
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// reappear 表/列仍存在于数据库中，之前报告的删除（待确认或已拒绝）已失效，
+// 避免确认旧报告时误删现有的表/列信息，之后再被删除时也能重新报告
+func (d *schemaDiff) reappear(table *model.TableInfo, column *model.ColumnInfo) {
+	key := [2]uint64{table.ID, 0}
+	if column != nil {
+		key[1] = column.ID
+	}
+	d.superseded = append(d.superseded, d.removals[key]...)
+}
+
+// supersede 将已失效的删除置为失效，并更新相关报告的待确认数量
+func (d *schemaDiff) supersede() error {
+	if len(d.superseded) == 0 {
+		return nil
+	}
+	ids := make([]uint64, 0, len(d.superseded))
+	reports := make(map[uint64]bool)
+	for _, item := range d.superseded {
+		ids = append(ids, item.ID)
+		if item.Status == model.SyncItemStatusPending {
+			reports[item.ReportID] = true
+		}
+	}
+	if err := d.tx.Model(&model.SchemaSyncItem{}).Where("id IN ?", ids).Update("status", model.SyncItemStatusSuperseded).Error; err != nil {
+		return err
+	}
+	for reportID := range reports {
+		var pending int64
+		if err := d.tx.Model(&model.SchemaSyncItem{}).
+			Where("report_id = ? AND status = ?", reportID, model.SyncItemStatusPending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if err := d.tx.Model(&model.SchemaSyncReport{}).Where("id = ?", reportID).Update("pending", pending).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
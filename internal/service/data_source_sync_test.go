@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/util"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+func TestMain(m *testing.M) {
+	_ = config.Init(os.DevNull)
+	dir, err := os.MkdirTemp("", "service")
+	if err != nil {
+		panic(err)
+	}
+	config.Set("log.filename", filepath.Join(dir, "logs", "app.log"))
+	logger.Init()
+	util.InitNode(1)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newSyncTestService 使用临时的SQLite文件作为系统数据库
+func newSyncTestService(t *testing.T) (*dataSourceService, *model.DataSource) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+filepath.Join(t.TempDir(), "meta.db")), &gorm.Config{
+		Logger:         gormlogger.Discard,
+		NamingStrategy: schema.NamingStrategy{TablePrefix: "t_"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.DataSource{}, &model.TableInfo{}, &model.ColumnInfo{}, &model.SchemaSyncReport{}, &model.SchemaSyncItem{}); err != nil {
+		t.Fatal(err)
+	}
+	dataSource := &model.DataSource{ApplicationID: 1, Name: "src", Type: "sqlite", Database: "src.db"}
+	if err := db.Create(dataSource).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &dataSourceService{BaseServiceImpl: &BaseServiceImpl[*model.DataSource]{db: db}}, dataSource
+}
+
+func syncColumn(name, typ, comment string) *model.ColumnInfo {
+	return &model.ColumnInfo{Name: name, Type: typ, Nullable: true, Comment: comment}
+}
+
+// syncTables 每次同步使用新的列对象，与从数据库读取时一致
+func syncTables(tables map[string][]*model.ColumnInfo, comments map[string]string) []*sourceTable {
+	var result []*sourceTable
+	for name, columns := range tables {
+		st := &sourceTable{Name: name, Comment: comments[name], Kind: "table"}
+		for _, c := range columns {
+			copied := *c
+			st.Columns = append(st.Columns, &copied)
+		}
+		result = append(result, st)
+	}
+	return result
+}
+
+type syncChange struct {
+	table, column, changeType string
+	status                    int
+}
+
+func reportChanges(report *model.SchemaSyncReport) map[syncChange]bool {
+	changes := make(map[syncChange]bool)
+	for _, item := range report.Items {
+		changes[syncChange{item.TableName, item.ColumnName, item.ChangeType, item.Status}] = true
+	}
+	return changes
+}
+
+func TestApplySchema(t *testing.T) {
+	s, dataSource := newSyncTestService(t)
+	db := s.db
+	reportService := &schemaSyncReportService{BaseServiceImpl: &BaseServiceImpl[*model.SchemaSyncReport]{db: db}}
+	var reports []*model.SchemaSyncReport
+	resolve := func(step int, apply bool) func(t *testing.T) {
+		return func(t *testing.T) {
+			if _, err := reportService.Resolve(context.Background(), reports[step].ID, nil, apply); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	users := []*model.ColumnInfo{syncColumn("id", "INTEGER", ""), syncColumn("phone", "TEXT", "手机号")}
+	orders := []*model.ColumnInfo{syncColumn("id", "INTEGER", ""), syncColumn("amount", "REAL", "")}
+
+	steps := []struct {
+		name     string
+		tables   map[string][]*model.ColumnInfo
+		comments map[string]string
+		before   func(t *testing.T) // 同步前在报告中确认或拒绝删除
+		want     []syncChange
+		pending  int
+	}{
+		{
+			name:   "initial sync reports new tables only",
+			tables: map[string][]*model.ColumnInfo{"users": users, "orders": orders},
+			want: []syncChange{
+				{"users", "", model.SyncChangeAdded, model.SyncItemStatusApplied},
+				{"orders", "", model.SyncChangeAdded, model.SyncItemStatusApplied},
+			},
+		},
+		{
+			name:   "unchanged schema",
+			tables: map[string][]*model.ColumnInfo{"users": users, "orders": orders},
+		},
+		{
+			name:   "dropped table and column are pending removals",
+			tables: map[string][]*model.ColumnInfo{"users": users[:1]},
+			want: []syncChange{
+				{"users", "phone", model.SyncChangeRemoved, model.SyncItemStatusPending},
+				{"orders", "", model.SyncChangeRemoved, model.SyncItemStatusPending},
+			},
+			pending: 2,
+		},
+		{
+			name:   "pending removals are not reported twice",
+			tables: map[string][]*model.ColumnInfo{"users": users[:1]},
+		},
+		{
+			name:   "reappearing column supersedes its removal",
+			tables: map[string][]*model.ColumnInfo{"users": users},
+		},
+		{
+			name:   "rejected removal is not reported again",
+			before: resolve(2, false),
+			tables: map[string][]*model.ColumnInfo{"users": users},
+		},
+		{
+			name:   "reappearing table supersedes the rejected removal",
+			tables: map[string][]*model.ColumnInfo{"users": users, "orders": orders},
+		},
+		{
+			name:   "removed again after reappearing",
+			tables: map[string][]*model.ColumnInfo{"users": users},
+			want: []syncChange{
+				{"orders", "", model.SyncChangeRemoved, model.SyncItemStatusPending},
+			},
+			pending: 1,
+		},
+		{
+			name:   "confirmed removal deletes the table, which is added again as new",
+			before: resolve(7, true),
+			tables: map[string][]*model.ColumnInfo{
+				"users":  {syncColumn("id", "BIGINT", ""), users[1], syncColumn("email", "TEXT", "")},
+				"orders": orders,
+			},
+			want: []syncChange{
+				{"users", "id", model.SyncChangeTypeChanged, model.SyncItemStatusApplied},
+				{"users", "email", model.SyncChangeAdded, model.SyncItemStatusApplied},
+				{"orders", "", model.SyncChangeAdded, model.SyncItemStatusApplied},
+			},
+		},
+	}
+	for _, step := range steps {
+		if step.before != nil {
+			step.before(t)
+		}
+		report, err := s.applySchema(dataSource, syncTables(step.tables, step.comments), true, false)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		reports = append(reports, report)
+		got := reportChanges(report)
+		if len(got) != len(step.want) {
+			t.Fatalf("%s: changes = %v, want %v", step.name, got, step.want)
+		}
+		for _, w := range step.want {
+			if !got[w] {
+				t.Fatalf("%s: changes = %v, missing %v", step.name, got, w)
+			}
+		}
+		if report.Pending != step.pending {
+			t.Fatalf("%s: pending = %d, want %d", step.name, report.Pending, step.pending)
+		}
+	}
+
+	// 删除失效后，报告的待确认数量随之更新
+	var first model.SchemaSyncReport
+	db.First(&first, "id = ?", reports[2].ID)
+	if first.Pending != 0 {
+		t.Fatalf("report of the first removal still has %d pending items", first.Pending)
+	}
+	var statuses []int
+	db.Model(&model.SchemaSyncItem{}).Where("change_type = ?", model.SyncChangeRemoved).Order("created_at, id").Pluck("status", &statuses)
+	want := []int{model.SyncItemStatusSuperseded, model.SyncItemStatusSuperseded, model.SyncItemStatusApplied}
+	if len(statuses) != len(want) {
+		t.Fatalf("removal statuses = %v, want %v", statuses, want)
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Fatalf("removal statuses = %v, want %v", statuses, want)
+		}
+	}
+}
+
+func TestApplySchemaComments(t *testing.T) {
+	s, dataSource := newSyncTestService(t)
+	sync := func(tableComment, columnComment string) *model.SchemaSyncReport {
+		t.Helper()
+		tables := syncTables(map[string][]*model.ColumnInfo{"users": {syncColumn("phone", "TEXT", columnComment)}}, map[string]string{"users": tableComment})
+		report, err := s.applySchema(dataSource, tables, true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+	comments := func() (string, string) {
+		t.Helper()
+		var table model.TableInfo
+		var column model.ColumnInfo
+		s.db.First(&table, "name = ?", "users")
+		s.db.First(&column, "name = ?", "phone")
+		return table.Comment, column.Comment
+	}
+
+	sync("用户", "电话")
+	// 数据库注释变化且未人工修改时更新
+	report := sync("用户表", "手机号")
+	if report.CommentChanged != 2 {
+		t.Fatalf("CommentChanged = %d, want 2", report.CommentChanged)
+	}
+	if table, column := comments(); table != "用户表" || column != "手机号" {
+		t.Fatalf("comments = %q, %q", table, column)
+	}
+
+	// 人工修改过的注释保留，数据库注释变化仍然报告
+	s.db.Model(&model.TableInfo{}).Where("name = ?", "users").Update("comment", "会员")
+	s.db.Model(&model.ColumnInfo{}).Where("name = ?", "phone").Update("comment", "会员手机号")
+	report = sync("用户信息", "联系电话")
+	if report.CommentChanged != 2 {
+		t.Fatalf("CommentChanged = %d, want 2", report.CommentChanged)
+	}
+	if table, column := comments(); table != "会员" || column != "会员手机号" {
+		t.Fatalf("manual comments overwritten: %q, %q", table, column)
+	}
+	if report := sync("用户信息", "联系电话"); report.CommentChanged != 0 {
+		t.Fatalf("unchanged source comments reported: %d", report.CommentChanged)
+	}
+}
+
+func TestMergeComment(t *testing.T) {
+	tests := []struct {
+		name                           string
+		comment, sourceComment, newSrc string
+		want                           string
+		changed                        bool
+	}{
+		{"unchanged", "用户", "用户", "用户", "用户", false},
+		{"source changed", "用户", "用户", "用户表", "用户表", true},
+		{"manual comment kept", "会员", "用户", "用户表", "会员", true},
+		{"manual comment, source unchanged", "会员", "用户", "用户", "会员", false},
+		{"legacy record equal to source", "用户", "", "用户", "用户", false},
+		{"legacy record with manual comment", "会员", "", "用户", "会员", true},
+		{"source comment removed", "用户", "用户", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := mergeComment(tt.comment, tt.sourceComment, tt.newSrc)
+			if got != tt.want || changed != tt.changed {
+				t.Fatalf("mergeComment() = %q, %v, want %q, %v", got, changed, tt.want, tt.changed)
+			}
+		})
+	}
+}
+
+func TestColumnTypeSignature(t *testing.T) {
+	tests := []struct {
+		column model.ColumnInfo
+		want   string
+	}{
+		{model.ColumnInfo{Type: "varchar", Size: 50, Nullable: true}, "varchar(50)"},
+		{model.ColumnInfo{Type: "varchar(50)", Size: 50}, "varchar(50) NOT NULL"},
+		{model.ColumnInfo{Type: "decimal", Precision: 10, Scale: 2, Nullable: true}, "decimal(10,2)"},
+		{model.ColumnInfo{Type: "numeric", Precision: 10, Nullable: true}, "numeric(10,0)"},
+		{model.ColumnInfo{Type: "int", Precision: 32, Nullable: true}, "int"},
+	}
+	for _, tt := range tests {
+		if got := columnTypeSignature(&tt.column); got != tt.want {
+			t.Fatalf("columnTypeSignature(%+v) = %q, want %q", tt.column, got, tt.want)
+		}
+	}
+}
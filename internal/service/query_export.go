@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/datasource"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/filestore"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/tabular"
+)
+
+// 导出时每批脱敏的行数
+const exportBatchSize = 500
+
+// Export 与 ExecuteSql 一样检查并执行SQL，将完整结果写为CSV或XLSX文件保存到文件存储，返回带签名的下载路径；
+// 结果不受数据源的行数及大小限制，最多 export.max_rows 行，超时为 export.timeout
+func (s *queryService) Export(ctx context.Context, dataSource *model.DataSource, req *ExportRequest) (result *ExportResult, err error) {
+	if req.Format == "" {
+		req.Format = "xlsx"
+	}
+	if req.Format != "csv" && req.Format != "xlsx" {
+		return nil, constant.ErrInvalidParams
+	}
+
+	audit := &model.SqlAudit{
+		ApplicationID: dataSource.ApplicationID,
+		DataSourceID:  dataSource.ID,
+		CustomID:      req.Variables["custom_id"],
+		Source:        model.SqlAuditSourceExport,
+		Question:      truncateRunes(req.Question, 500),
+		Sql:           req.Sql,
+	}
+	start := time.Now()
+	defer func() {
+		audit.DurationMs = time.Since(start).Milliseconds()
+		if err != nil && audit.Error == "" {
+			audit.Error = err.Error()
+		}
+		if result != nil {
+			audit.Rows = result.Rows
+			audit.Truncated = result.Truncated
+		}
+		s.sqlAuditService.Record(ctx, audit)
+	}()
+
+	_, query, plan, err := s.prepare(ctx, dataSource, &req.QueryRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	result = new(ExportResult)
+	file, err := filestore.Save(exportFileName(req.FileName, req.Format), func(w io.Writer) error {
+		writer, err := tabular.NewWriter(req.Format, w)
+		if err != nil {
+			return err
+		}
+		var columns []string
+		batch := make([]map[string]interface{}, 0, exportBatchSize)
+		flush := func() error {
+			plan.Apply(batch)
+			for _, row := range batch {
+				values := make([]interface{}, len(columns))
+				for i, c := range columns {
+					values[i] = row[c]
+				}
+				if err := writer.WriteRow(values); err != nil {
+					return err
+				}
+			}
+			result.Rows += len(batch)
+			batch = batch[:0]
+			return nil
+		}
+		result.Truncated, err = datasource.ScanReadOnly(ctx, dataSource, datasource.QueryOptions{
+			Timeout: time.Duration(config.GetInt("export.timeout")) * time.Second,
+			MaxRows: config.GetInt("export.max_rows"),
+		}, func(cols []string) error {
+			columns = cols
+			return writer.WriteHeader(cols)
+		}, func(row map[string]interface{}) error {
+			batch = append(batch, row)
+			if len(batch) < exportBatchSize {
+				return nil
+			}
+			return flush()
+		}, query)
+		if err == nil {
+			err = flush()
+		}
+		if err != nil {
+			writer.Close()
+			return err
+		}
+		return writer.Close()
+	})
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			logger.Warn("导出sql超时", logger.F("sql", req.Sql), logger.F("err", err))
+			return nil, constant.ErrQueryTimeout
+		}
+		logger.Error("导出sql结果失败", logger.F("err", err))
+		audit.Error = err.Error()
+		return nil, constant.ErrDatabaseError
+	}
+
+	result.FileName = file.Name
+	result.Size = file.Size
+	result.ExpiresAt = time.Now().Add(time.Duration(config.GetInt("files.url_ttl")) * time.Second).Unix()
+	result.Url = FileDownloadPath(file.ID, file.Name, result.ExpiresAt)
+	return result, nil
+}
+
+// FileDownloadPath 文件存储中文件的下载路径（不含服务地址），带过期时间及签名
+func FileDownloadPath(id, name string, expires int64) string {
+	return fmt.Sprintf("/api/v1/files/%s/%s?expires=%d&sign=%s",
+		id, url.PathEscape(name), expires, filestore.Sign(id, name, expires))
+}
+
+// exportFileName 导出文件名，去掉路径分隔符等不能用于文件名的字符，为空时按时间生成
+func exportFileName(name, format string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	name = strings.TrimSuffix(name, "."+format)
+	if name == "" {
+		name = "query_" + time.Now().Format("20060102_150405")
+	}
+	return truncateRunes(name, 100) + "." + format
+}
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/datasource"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/masking"
+	"github.com/yockii/dify_tools/pkg/sqlguard"
+)
+
+// 行级过滤条件中的变量，如 {{custom_id}}
+var rowFilterVarPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+type queryService struct {
+	tableInfoService  TableInfoService
+	columnInfoService ColumnInfoService
+	sqlAuditService   SqlAuditService
+}
+
+func NewQueryService(
+	tableInfoService TableInfoService,
+	columnInfoService ColumnInfoService,
+	sqlAuditService SqlAuditService,
+) *queryService {
+	return &queryService{
+		tableInfoService:  tableInfoService,
+		columnInfoService: columnInfoService,
+		sqlAuditService:   sqlAuditService,
+	}
+}
+
+// ExecuteSql 检查并以只读方式执行SQL，检查不通过时返回 *sqlguard.Violation。
+// 结果受数据源的超时、行数及大小限制，超出时截断并返回续查游标；每次调用（包括被拒绝的）都记录SQL审计
+func (s *queryService) ExecuteSql(ctx context.Context, dataSource *model.DataSource, req *QueryRequest) (result *QueryResult, err error) {
+	audit := &model.SqlAudit{
+		ApplicationID: dataSource.ApplicationID,
+		DataSourceID:  dataSource.ID,
+		CustomID:      req.Variables["custom_id"],
+		Source:        req.Source,
+		Question:      truncateRunes(req.Question, 500),
+		Sql:           req.Sql,
+	}
+	start := time.Now()
+	defer func() {
+		audit.DurationMs = time.Since(start).Milliseconds()
+		if err != nil && audit.Error == "" {
+			audit.Error = err.Error()
+		}
+		if result != nil {
+			audit.Rows = len(result.Rows)
+			audit.Truncated = result.Truncated
+		}
+		s.sqlAuditService.Record(ctx, audit)
+	}()
+
+	stmt, query, plan, err := s.prepare(ctx, dataSource, req)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := decodeCursor(req.Cursor, stmt.SQL)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := queryLimitsOf(dataSource)
+	limited := query
+	// 未指定LIMIT时由数据库限制行数，多取一行用于判断是否还有更多结果
+	if !stmt.Limited {
+		limited = fmt.Sprintf("%s\nLIMIT %d", query, offset+limits.maxRows+1)
+	}
+	rows, hasMore, err := datasource.QueryReadOnly(ctx, dataSource, datasource.QueryOptions{
+		Timeout: limits.timeout,
+		Offset:  offset,
+		MaxRows: limits.maxRows,
+	}, limited)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			logger.Warn("执行sql超时", logger.F("sql", req.Sql), logger.F("err", err))
+			return nil, constant.ErrQueryTimeout
+		}
+		logger.Error("执行sql失败", logger.F("err", err))
+		// 审计中记录数据库返回的原始错误
+		audit.Error = err.Error()
+		req.dbError = err
+		return nil, constant.ErrDatabaseError
+	}
+
+	// 列级别脱敏，在计算结果大小之前处理
+	plan.Apply(rows)
+
+	// 结果大小限制，第一行就超出时续查游标无法前进，直接报错
+	size := 2
+	for i, row := range rows {
+		b, _ := json.Marshal(row)
+		size += len(b) + 1
+		if size > limits.maxBytes {
+			if i == 0 {
+				logger.Warn("单行结果超过返回大小限制", logger.F("size", size), logger.F("maxBytes", limits.maxBytes))
+				return nil, constant.ErrRowTooLarge
+			}
+			rows, hasMore = rows[:i], true
+			break
+		}
+	}
+
+	result = &QueryResult{
+		Rows:      rows,
+		Truncated: hasMore,
+	}
+	if result.Rows == nil {
+		result.Rows = []map[string]interface{}{}
+	}
+	if hasMore {
+		result.NextCursor = encodeCursor(offset+len(rows), stmt.SQL)
+		// 尽力统计总行数，失败时不返回
+		if total, err := datasource.CountReadOnly(ctx, dataSource, limits.timeout, query); err == nil {
+			result.Total = &total
+		} else {
+			logger.Debug("统计查询总行数失败", logger.F("err", err))
+		}
+	} else {
+		total := int64(offset + len(rows))
+		result.Total = &total
+	}
+	return result, nil
+}
+
+// prepare 检查SQL及引用的表，返回加上行级过滤后实际执行的SQL及结果脱敏计划
+func (s *queryService) prepare(ctx context.Context, dataSource *model.DataSource, req *QueryRequest) (*sqlguard.Statement, string, *masking.Plan, error) {
+	stmt, err := sqlguard.Analyze(req.Sql, sqlguard.DialectOf(dataSource.Type))
+	if err != nil {
+		logger.Warn("SQL检查未通过", logger.F("sql", req.Sql), logger.F("err", err))
+		return nil, "", nil, err
+	}
+
+	// 表级别白名单
+	tables, err := s.checkTables(ctx, dataSource, stmt)
+	if err != nil {
+		logger.Warn("SQL引用了未开放的表", logger.F("sql", req.Sql), logger.F("err", err))
+		return nil, "", nil, err
+	}
+
+	plan, err := s.maskingPlan(ctx, stmt, tables)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	// 行级过滤
+	query, err := s.applyRowFilters(stmt, tables, req.Variables)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return stmt, query, plan, nil
+}
+
+// checkTables 检查SQL引用的表是否都在数据源的白名单中，且未跨库/跨schema访问，返回引用到的表
+func (s *queryService) checkTables(ctx context.Context, dataSource *model.DataSource, stmt *sqlguard.Statement) (map[string]*model.TableInfo, error) {
+	tables, err := s.tableInfoService.ListQueryable(ctx, dataSource.ID)
+	if err != nil {
+		return nil, err
+	}
+	exposed := make(map[string]*model.TableInfo, len(tables))
+	for _, t := range tables {
+		exposed[strings.ToLower(t.Name)] = t
+	}
+
+	schema := dataSource.Schema
+	switch stmt.Dialect {
+	case sqlguard.DialectMySQL:
+		schema = dataSource.Database
+	case sqlguard.DialectSQLite:
+		schema = "main"
+	}
+	referenced := make(map[string]*model.TableInfo)
+	err = stmt.CheckTables(func(table sqlguard.TableRef) bool {
+		if table.Schema != "" && !strings.EqualFold(table.Schema, schema) {
+			return false
+		}
+		t, ok := exposed[strings.ToLower(table.Name)]
+		if ok {
+			referenced[strings.ToLower(table.Name)] = t
+		}
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	return referenced, nil
+}
+
+// maskingPlan 根据引用表的脱敏列生成结果脱敏计划
+func (s *queryService) maskingPlan(ctx context.Context, stmt *sqlguard.Statement, tables map[string]*model.TableInfo) (*masking.Plan, error) {
+	tableIDs := make([]uint64, 0, len(tables))
+	for _, t := range tables {
+		tableIDs = append(tableIDs, t.ID)
+	}
+	columns, err := s.columnInfoService.ListMaskedColumns(ctx, tableIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, nil
+	}
+
+	// 表整行引用（如 row_to_json(t)）按该表最严格的脱敏类型处理
+	tableMask := make(map[uint64]string)
+	sources := make(map[string]string)
+	for _, c := range columns {
+		name := strings.ToLower(c.Name)
+		sources[name] = masking.Stricter(sources[name], c.MaskType)
+		tableMask[c.TableID] = masking.Stricter(tableMask[c.TableID], c.MaskType)
+	}
+	for _, ref := range stmt.Tables {
+		t, ok := tables[strings.ToLower(ref.Name)]
+		if !ok || tableMask[t.ID] == "" {
+			continue
+		}
+		for _, name := range []string{ref.Name, ref.Alias} {
+			if name != "" {
+				name = strings.ToLower(name)
+				sources[name] = masking.Stricter(sources[name], tableMask[t.ID])
+			}
+		}
+	}
+	return masking.NewPlan(stmt, sources), nil
+}
+
+// applyRowFilters 将配置了行级过滤条件的表替换为过滤后的派生表，变量值作为字符串常量代入，缺少变量时拒绝执行
+func (s *queryService) applyRowFilters(stmt *sqlguard.Statement, tables map[string]*model.TableInfo, variables map[string]string) (string, error) {
+	conditions := make(map[string]string)
+	for name, t := range tables {
+		if strings.TrimSpace(t.RowFilter) == "" {
+			continue
+		}
+		var missing string
+		cond := rowFilterVarPattern.ReplaceAllStringFunc(t.RowFilter, func(m string) string {
+			key := rowFilterVarPattern.FindStringSubmatch(m)[1]
+			v, ok := variables[key]
+			if !ok || v == "" {
+				missing = key
+				return m
+			}
+			return sqlguard.QuoteString(v, stmt.Dialect)
+		})
+		if missing != "" {
+			logger.Warn("缺少行级权限变量", logger.F("table", t.Name), logger.F("variable", missing))
+			return "", constant.ErrRowFilterVarMissing
+		}
+		conditions[name] = cond
+	}
+	if len(conditions) == 0 {
+		return stmt.SQL, nil
+	}
+	return stmt.WithRowFilters(func(table sqlguard.TableRef) string {
+		return conditions[strings.ToLower(table.Name)]
+	}), nil
+}
+
+type queryLimits struct {
+	timeout  time.Duration
+	maxRows  int
+	maxBytes int
+}
+
+// queryLimitsOf 获取数据源的查询限制，未配置的项使用全局配置
+func queryLimitsOf(dataSource *model.DataSource) queryLimits {
+	pick := func(v int, key string) int {
+		if v > 0 {
+			return v
+		}
+		return config.GetInt(key)
+	}
+	return queryLimits{
+		timeout:  time.Duration(pick(dataSource.QueryTimeout, "query.timeout")) * time.Second,
+		maxRows:  pick(dataSource.MaxRows, "query.max_rows"),
+		maxBytes: pick(dataSource.MaxBytes, "query.max_bytes"),
+	}
+}
+
+// encodeCursor 生成续查游标，游标与SQL绑定，换了SQL不能继续使用
+func encodeCursor(offset int, sql string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset) + ":" + sqlDigest(sql)))
+}
+
+// decodeCursor 解析续查游标，返回已读取的行数
+func decodeCursor(cursor, sql string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, constant.ErrInvalidCursor
+	}
+	offsetStr, digest, ok := strings.Cut(string(b), ":")
+	if !ok || digest != sqlDigest(sql) {
+		return 0, constant.ErrInvalidCursor
+	}
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil || offset < 0 {
+		return 0, constant.ErrInvalidCursor
+	}
+	return offset, nil
+}
+
+func sqlDigest(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/sqlguard"
+	"gorm.io/gorm"
+)
+
+// 预置查询及参数的名称，智能体按名称调用
+var savedQueryNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,49}$`)
+
+type savedQueryService struct {
+	*BaseServiceImpl[*model.SavedQuery]
+	queryService QueryService
+}
+
+func NewSavedQueryService(queryService QueryService) *savedQueryService {
+	srv := new(savedQueryService)
+	srv.queryService = queryService
+	srv.BaseServiceImpl = NewBaseService(BaseServiceConfig[*model.SavedQuery]{
+		NewModel:       srv.NewModel,
+		CheckDuplicate: srv.CheckDuplicate,
+		BuildCondition: srv.BuildCondition,
+		ListOrder:      srv.ListOrder,
+	})
+	return srv
+}
+
+func (s *savedQueryService) NewModel() *model.SavedQuery {
+	return &model.SavedQuery{}
+}
+
+func (s *savedQueryService) CheckDuplicate(record *model.SavedQuery) (bool, error) {
+	query := s.db.Model(s.NewModel()).Where("data_source_id = ? AND name = ?", record.DataSourceID, record.Name)
+	if record.ID != 0 {
+		query = query.Where("id <> ?", record.ID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		logger.Error("查询记录失败", logger.F("error", err))
+		return false, constant.ErrDatabaseError
+	}
+	return count > 0, nil
+}
+
+func (s *savedQueryService) BuildCondition(query *gorm.DB, condition *model.SavedQuery) *gorm.DB {
+	if condition.ApplicationID != 0 {
+		query = query.Where("application_id = ?", condition.ApplicationID)
+	}
+	if condition.DataSourceID != 0 {
+		query = query.Where("data_source_id = ?", condition.DataSourceID)
+	}
+	if condition.Name != "" {
+		query = query.Where("name LIKE ?", "%"+condition.Name+"%")
+	}
+	return query
+}
+
+func (s *savedQueryService) ListOrder() string {
+	return "name, id"
+}
+
+// Create 检查查询定义后创建，应用ID取自数据源
+func (s *savedQueryService) Create(ctx context.Context, record *model.SavedQuery) error {
+	dataSource, err := s.getDataSource(record.DataSourceID)
+	if err != nil {
+		return err
+	}
+	record.ApplicationID = dataSource.ApplicationID
+	if err := validateSavedQuery(dataSource, record); err != nil {
+		return err
+	}
+	return s.BaseServiceImpl.Create(ctx, record)
+}
+
+// Update 检查修改后的查询定义，所属数据源不可修改
+func (s *savedQueryService) Update(ctx context.Context, record *model.SavedQuery) error {
+	if record.ID == 0 {
+		return constant.ErrRecordIDEmpty
+	}
+	existing, err := s.Get(ctx, record.ID)
+	if err != nil {
+		return err
+	}
+	dataSource, err := s.getDataSource(existing.DataSourceID)
+	if err != nil {
+		return err
+	}
+	record.ApplicationID = existing.ApplicationID
+	record.DataSourceID = existing.DataSourceID
+	if record.Name == "" {
+		record.Name = existing.Name
+	}
+	if record.Sql == "" {
+		record.Sql = existing.Sql
+	}
+	if record.Params == nil {
+		record.Params = existing.Params
+	}
+	if err := validateSavedQuery(dataSource, record); err != nil {
+		return err
+	}
+	return s.BaseServiceImpl.Update(ctx, record)
+}
+
+func (s *savedQueryService) getDataSource(id uint64) (*model.DataSource, error) {
+	var dataSource model.DataSource
+	if err := s.db.First(&dataSource, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, constant.ErrRecordNotFound
+		}
+		logger.Error("查询数据源失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	return &dataSource, nil
+}
+
+// ListForDify 查询数据源的预置查询，不返回SQL
+func (s *savedQueryService) ListForDify(ctx context.Context, dataSourceID uint64) ([]*model.SavedQuery, error) {
+	var list []*model.SavedQuery
+	if err := s.db.Select("id", "application_id", "data_source_id", "name", "description", "params").
+		Where("data_source_id = ?", dataSourceID).Order(s.ListOrder()).Find(&list).Error; err != nil {
+		logger.Error("查询预置查询失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	return list, nil
+}
+
+// Execute 按名称执行数据源的预置查询，参数按定义的类型转换为常量代入SQL后，与 executeSql 一样经过检查、脱敏、行级过滤及结果限制；
+// 参数不合法时返回 *QueryParamError
+func (s *savedQueryService) Execute(ctx context.Context, dataSource *model.DataSource, req *SavedQueryRequest) (*QueryResult, error) {
+	var query model.SavedQuery
+	if err := s.db.Where("data_source_id = ? AND name = ?", dataSource.ID, req.Name).First(&query).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, constant.ErrRecordNotFound
+		}
+		logger.Error("查询预置查询失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+
+	values := make(map[string]string, len(req.Params))
+	for name, v := range req.Params {
+		if v == nil {
+			continue
+		}
+		switch x := v.(type) {
+		case string:
+			values[name] = x
+		case float64:
+			values[name] = strconv.FormatFloat(x, 'f', -1, 64)
+		case bool:
+			values[name] = strconv.FormatBool(x)
+		default:
+			return nil, &QueryParamError{Param: name, Reason: "参数值只能是字符串、数字或布尔值"}
+		}
+	}
+	sql, err := bindQueryParams(query.Sql, query.Params, values, sqlguard.DialectOf(dataSource.Type))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.queryService.ExecuteSql(ctx, dataSource, &QueryRequest{
+		Sql:       sql,
+		Variables: req.Variables,
+		Cursor:    req.Cursor,
+		Source:    model.SqlAuditSourceSavedQuery,
+	})
+}
+
+// validateSavedQuery 检查查询名称、参数定义及SQL引用的参数，并用示例参数值代入后做只读检查
+func validateSavedQuery(dataSource *model.DataSource, query *model.SavedQuery) error {
+	if !savedQueryNamePattern.MatchString(query.Name) || strings.TrimSpace(query.Sql) == "" {
+		return constant.ErrInvalidParams
+	}
+	dialect := sqlguard.DialectOf(dataSource.Type)
+	declared := make(map[string]bool, len(query.Params))
+	samples := make(map[string]string, len(query.Params))
+	for _, p := range query.Params {
+		if !savedQueryNamePattern.MatchString(p.Name) {
+			return &QueryParamError{Param: p.Name, Reason: "参数名只能包含字母、数字和下划线"}
+		}
+		if declared[p.Name] {
+			return &QueryParamError{Param: p.Name, Reason: "参数名重复"}
+		}
+		sample, ok := queryParamSamples[p.Type]
+		if !ok {
+			return &QueryParamError{Param: p.Name, Reason: "不支持的参数类型"}
+		}
+		if p.Default != "" {
+			if _, err := bindQueryParam(p, p.Default, dialect); err != nil {
+				return err
+			}
+		}
+		declared[p.Name] = true
+		samples[p.Name] = sample
+	}
+	for _, m := range rowFilterVarPattern.FindAllStringSubmatch(query.Sql, -1) {
+		if !declared[m[1]] {
+			return &QueryParamError{Param: m[1], Reason: "SQL中引用的参数未定义"}
+		}
+	}
+
+	sql, err := bindQueryParams(query.Sql, query.Params, samples, dialect)
+	if err != nil {
+		return err
+	}
+	if _, err := sqlguard.Analyze(sql, dialect); err != nil {
+		return err
+	}
+	return nil
+}
+
+// 检查SQL时代入的各类型示例值
+var queryParamSamples = map[string]string{
+	model.QueryParamString:   "x",
+	model.QueryParamInteger:  "1",
+	model.QueryParamNumber:   "1.5",
+	model.QueryParamBoolean:  "true",
+	model.QueryParamDate:     "2000-01-01",
+	model.QueryParamDateTime: "2000-01-01 00:00:00",
+}
+
+// bindQueryParams 将SQL中的 {{参数名}} 替换为参数值常量，未传入的参数使用默认值，不必填且无默认值时代入NULL
+func bindQueryParams(sql string, params []model.QueryParam, values map[string]string, dialect sqlguard.Dialect) (string, error) {
+	literals := make(map[string]string, len(params))
+	for _, p := range params {
+		v, ok := values[p.Name]
+		if !ok || v == "" {
+			v = p.Default
+		}
+		if v == "" {
+			if p.Required {
+				return "", &QueryParamError{Param: p.Name, Reason: "缺少必填参数"}
+			}
+			literals[p.Name] = "NULL"
+			continue
+		}
+		literal, err := bindQueryParam(p, v, dialect)
+		if err != nil {
+			return "", err
+		}
+		literals[p.Name] = literal
+	}
+	for name := range values {
+		if _, ok := literals[name]; !ok {
+			return "", &QueryParamError{Param: name, Reason: "未定义的参数"}
+		}
+	}
+
+	return rowFilterVarPattern.ReplaceAllStringFunc(sql, func(m string) string {
+		return literals[rowFilterVarPattern.FindStringSubmatch(m)[1]]
+	}), nil
+}
+
+// bindQueryParam 按参数类型校验参数值并转换为SQL常量
+func bindQueryParam(param model.QueryParam, value string, dialect sqlguard.Dialect) (string, error) {
+	value = strings.TrimSpace(value)
+	invalid := func(format string) error {
+		return &QueryParamError{Param: param.Name, Reason: fmt.Sprintf("参数值应为%s", format)}
+	}
+	switch param.Type {
+	case model.QueryParamString:
+		return sqlguard.QuoteString(value, dialect), nil
+	case model.QueryParamInteger:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", invalid("整数")
+		}
+		return strconv.FormatInt(n, 10), nil
+	case model.QueryParamNumber:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", invalid("数字")
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case model.QueryParamBoolean:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", invalid("布尔值")
+		}
+		if dialect == sqlguard.DialectPostgres {
+			return strings.ToUpper(strconv.FormatBool(b)), nil
+		}
+		if b {
+			return "1", nil
+		}
+		return "0", nil
+	case model.QueryParamDate:
+		t, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return "", invalid("日期，格式 2006-01-02")
+		}
+		return sqlguard.QuoteString(t.Format(time.DateOnly), dialect), nil
+	case model.QueryParamDateTime:
+		t, err := time.Parse(time.DateTime, value)
+		if err != nil {
+			if t, err = time.Parse(time.RFC3339, value); err != nil {
+				return "", invalid("日期时间，格式 2006-01-02 15:04:05")
+			}
+		}
+		return sqlguard.QuoteString(t.Format(time.DateTime), dialect), nil
+	}
+	return "", &QueryParamError{Param: param.Name, Reason: "不支持的参数类型"}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/sqlguard"
+)
+
+func TestBindQueryParam(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		value   string
+		dialect sqlguard.Dialect
+		want    string
+		wantErr bool
+	}{
+		{"string", model.QueryParamString, "张三", sqlguard.DialectPostgres, "'张三'", false},
+		{"string quote", model.QueryParamString, "x' OR '1'='1", sqlguard.DialectPostgres, "'x'' OR ''1''=''1'", false},
+		{"mysql backslash", model.QueryParamString, `x\' OR 1=1 -- `, sqlguard.DialectMySQL, `'x\\'' OR 1=1 --'`, false},
+		{"postgres backslash kept", model.QueryParamString, `a\b`, sqlguard.DialectPostgres, `'a\b'`, false},
+		{"integer", model.QueryParamInteger, " 42 ", sqlguard.DialectPostgres, "42", false},
+		{"integer injection", model.QueryParamInteger, "1 OR 1=1", sqlguard.DialectPostgres, "", true},
+		{"integer float", model.QueryParamInteger, "1.5", sqlguard.DialectPostgres, "", true},
+		{"number", model.QueryParamNumber, "-1.25e2", sqlguard.DialectPostgres, "-125", false},
+		{"number nan", model.QueryParamNumber, "NaN", sqlguard.DialectPostgres, "", true},
+		{"number inf", model.QueryParamNumber, "Inf", sqlguard.DialectPostgres, "", true},
+		{"boolean postgres", model.QueryParamBoolean, "true", sqlguard.DialectPostgres, "TRUE", false},
+		{"boolean mysql", model.QueryParamBoolean, "false", sqlguard.DialectMySQL, "0", false},
+		{"boolean sqlite", model.QueryParamBoolean, "1", sqlguard.DialectSQLite, "1", false},
+		{"boolean invalid", model.QueryParamBoolean, "yes", sqlguard.DialectPostgres, "", true},
+		{"date", model.QueryParamDate, "2024-02-29", sqlguard.DialectPostgres, "'2024-02-29'", false},
+		{"date invalid", model.QueryParamDate, "2024-02-30", sqlguard.DialectPostgres, "", true},
+		{"date injection", model.QueryParamDate, "2024-01-01' OR '1'='1", sqlguard.DialectPostgres, "", true},
+		{"datetime", model.QueryParamDateTime, "2024-01-02 03:04:05", sqlguard.DialectPostgres, "'2024-01-02 03:04:05'", false},
+		{"datetime rfc3339", model.QueryParamDateTime, "2024-01-02T03:04:05+08:00", sqlguard.DialectPostgres, "'2024-01-02 03:04:05'", false},
+		{"datetime invalid", model.QueryParamDateTime, "2024-01-02", sqlguard.DialectPostgres, "", true},
+		{"unknown type", "json", "{}", sqlguard.DialectPostgres, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bindQueryParam(model.QueryParam{Name: "p", Type: tt.typ}, tt.value, tt.dialect)
+			if tt.wantErr {
+				var paramErr *QueryParamError
+				if !errors.As(err, &paramErr) || paramErr.Param != "p" {
+					t.Fatalf("bindQueryParam(%q) = %q, %v, want QueryParamError", tt.value, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("bindQueryParam(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindQueryParams(t *testing.T) {
+	params := []model.QueryParam{
+		{Name: "region", Type: model.QueryParamString, Required: true},
+		{Name: "min_amount", Type: model.QueryParamNumber, Default: "100"},
+		{Name: "since", Type: model.QueryParamDate},
+	}
+	sql := "SELECT * FROM orders WHERE region = {{region}} AND amount >= {{ min_amount }} AND ({{since}} IS NULL OR created_at >= {{since}})"
+
+	tests := []struct {
+		name    string
+		values  map[string]string
+		want    string
+		wantErr string
+	}{
+		{
+			"all values",
+			map[string]string{"region": "华东", "min_amount": "50", "since": "2024-01-01"},
+			"SELECT * FROM orders WHERE region = '华东' AND amount >= 50 AND ('2024-01-01' IS NULL OR created_at >= '2024-01-01')",
+			"",
+		},
+		{
+			"default and null",
+			map[string]string{"region": "华东"},
+			"SELECT * FROM orders WHERE region = '华东' AND amount >= 100 AND (NULL IS NULL OR created_at >= NULL)",
+			"",
+		},
+		{
+			"empty value uses default",
+			map[string]string{"region": "华东", "min_amount": ""},
+			"SELECT * FROM orders WHERE region = '华东' AND amount >= 100 AND (NULL IS NULL OR created_at >= NULL)",
+			"",
+		},
+		{"missing required", map[string]string{"min_amount": "1"}, "", "region"},
+		{"invalid value", map[string]string{"region": "x", "min_amount": "abc"}, "", "min_amount"},
+		{"undeclared value", map[string]string{"region": "x", "other": "1"}, "", "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bindQueryParams(sql, params, tt.values, sqlguard.DialectPostgres)
+			if tt.wantErr != "" {
+				var paramErr *QueryParamError
+				if !errors.As(err, &paramErr) || paramErr.Param != tt.wantErr {
+					t.Fatalf("bindQueryParams() = %q, %v, want error for %s", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("bindQueryParams() = %q, %v\nwant %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSavedQuery(t *testing.T) {
+	dataSource := &model.DataSource{Type: "postgres"}
+	param := func(name, typ string) model.QueryParam {
+		return model.QueryParam{Name: name, Type: typ}
+	}
+	tests := []struct {
+		name      string
+		query     model.SavedQuery
+		wantParam string // 为空时期望通过，"-" 表示其他错误
+	}{
+		{"valid", model.SavedQuery{Name: "orders_by_region", Sql: "SELECT * FROM orders WHERE region = {{region}}", Params: []model.QueryParam{param("region", model.QueryParamString)}}, ""},
+		{"bad name", model.SavedQuery{Name: "1orders", Sql: "SELECT 1"}, "-"},
+		{"empty sql", model.SavedQuery{Name: "q", Sql: "  "}, "-"},
+		{"bad param name", model.SavedQuery{Name: "q", Sql: "SELECT 1", Params: []model.QueryParam{param("a-b", model.QueryParamString)}}, "a-b"},
+		{"duplicate param", model.SavedQuery{Name: "q", Sql: "SELECT {{a}}", Params: []model.QueryParam{param("a", model.QueryParamString), param("a", model.QueryParamInteger)}}, "a"},
+		{"unknown type", model.SavedQuery{Name: "q", Sql: "SELECT {{a}}", Params: []model.QueryParam{param("a", "json")}}, "a"},
+		{"bad default", model.SavedQuery{Name: "q", Sql: "SELECT {{a}}", Params: []model.QueryParam{{Name: "a", Type: model.QueryParamInteger, Default: "x"}}}, "a"},
+		{"undeclared reference", model.SavedQuery{Name: "q", Sql: "SELECT * FROM t WHERE id = {{id}}"}, "id"},
+		{"write statement", model.SavedQuery{Name: "q", Sql: "DELETE FROM t WHERE id = {{id}}", Params: []model.QueryParam{param("id", model.QueryParamInteger)}}, "-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSavedQuery(dataSource, &tt.query)
+			var paramErr *QueryParamError
+			switch {
+			case tt.wantParam == "":
+				if err != nil {
+					t.Fatalf("validateSavedQuery() = %v, want nil", err)
+				}
+			case tt.wantParam == "-":
+				if err == nil || errors.As(err, &paramErr) {
+					t.Fatalf("validateSavedQuery() = %v, want a non-parameter error", err)
+				}
+			default:
+				if !errors.As(err, &paramErr) || paramErr.Param != tt.wantParam {
+					t.Fatalf("validateSavedQuery() = %v, want error for %s", err, tt.wantParam)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/dify"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// 数据字典文档的元数据类型，便于在知识库中区分
+const schemaDocumentKind = "data_dictionary"
+
+type schemaKnowledgeService struct {
+	*BaseServiceImpl[*model.SchemaDocument]
+	schemaService        SchemaService
+	knowledgeBaseService KnowledgeBaseService
+	// 同一数据源的发布串行执行，避免重复上传
+	locks sync.Map
+}
+
+func NewSchemaKnowledgeService(schemaService SchemaService, knowledgeBaseService KnowledgeBaseService) *schemaKnowledgeService {
+	srv := new(schemaKnowledgeService)
+	srv.schemaService = schemaService
+	srv.knowledgeBaseService = knowledgeBaseService
+	srv.BaseServiceImpl = NewBaseService(BaseServiceConfig[*model.SchemaDocument]{
+		NewModel:       srv.NewModel,
+		BuildCondition: srv.BuildCondition,
+		ListOrder:      srv.ListOrder,
+	})
+	return srv
+}
+
+func (s *schemaKnowledgeService) NewModel() *model.SchemaDocument {
+	return &model.SchemaDocument{}
+}
+
+func (s *schemaKnowledgeService) BuildCondition(query *gorm.DB, condition *model.SchemaDocument) *gorm.DB {
+	if condition.ApplicationID != 0 {
+		query = query.Where("application_id = ?", condition.ApplicationID)
+	}
+	if condition.DataSourceID != 0 {
+		query = query.Where("data_source_id = ?", condition.DataSourceID)
+	}
+	if condition.TableName != "" {
+		query = query.Where("table_name LIKE ?", "%"+condition.TableName+"%")
+	}
+	return query
+}
+
+func (s *schemaKnowledgeService) ListOrder() string {
+	return "table_name"
+}
+
+func (s *schemaKnowledgeService) lock(dataSourceID uint64) func() {
+	l, _ := s.locks.LoadOrStore(dataSourceID, new(sync.Mutex))
+	l.(*sync.Mutex).Lock()
+	return l.(*sync.Mutex).Unlock
+}
+
+// Publish 将数据源中开放给AI的每张表（含列、业务术语及关联）渲染为Markdown文档，上传到应用的公共知识库。
+// 内容未变化的文档不重新上传，已不再开放或已删除的表对应的文档从知识库中删除；
+// 单个文档失败不影响其余文档，失败数记录在结果中
+func (s *schemaKnowledgeService) Publish(ctx context.Context, dataSourceID uint64) (*SchemaPublishResult, error) {
+	unlock := s.lock(dataSourceID)
+	defer unlock()
+
+	var dataSource model.DataSource
+	if err := s.db.First(&dataSource, "id = ?", dataSourceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, constant.ErrRecordNotFound
+		}
+		logger.Error("查询数据源失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	tables, err := s.schemaService.ListTables(ctx, dataSource.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 与上传文档一样使用应用的公共知识库，不存在时创建
+	kb, err := s.knowledgeBaseService.GetByApplicationIDAndCustomID(ctx, dataSource.ApplicationID, "")
+	if err != nil {
+		return nil, err
+	}
+	if kb == nil {
+		kb = &model.KnowledgeBase{ApplicationID: dataSource.ApplicationID}
+		if err := s.knowledgeBaseService.Create(ctx, kb); err != nil {
+			return nil, err
+		}
+	}
+	kbClient, err := s.knowledgeBaseService.GetDifyKnowledgeBaseClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []*model.SchemaDocument
+	if err := s.db.Where("data_source_id = ?", dataSource.ID).Find(&documents).Error; err != nil {
+		logger.Error("查询数据字典文档失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	existing := make(map[uint64]*model.SchemaDocument, len(documents))
+	for _, d := range documents {
+		existing[d.TableID] = d
+	}
+
+	result := new(SchemaPublishResult)
+	for _, t := range tables {
+		content := renderSchemaDocument(&dataSource, t)
+		sum := sha256.Sum256([]byte(content))
+		hash := hex.EncodeToString(sum[:])
+		name := fmt.Sprintf("数据字典-%s-%s", dataSource.Name, t.Name)
+
+		doc := existing[t.ID]
+		delete(existing, t.ID)
+		// 知识库被重建后需要重新上传
+		if doc != nil && doc.KnowledgeBaseID != kb.ID {
+			s.deleteDocument(ctx, kbClient, doc)
+			doc = nil
+		}
+		if doc != nil && doc.ContentHash == hash && doc.TableName == t.Name {
+			result.Unchanged++
+			continue
+		}
+
+		var resp string
+		if doc != nil {
+			resp, err = kbClient.UpdateDocumentByText(kb.OuterID, doc.OuterID, name, content)
+		} else {
+			resp, err = kbClient.CreateDocumentByText(kb.OuterID, name, content, map[string]string{
+				"kind":        schemaDocumentKind,
+				"data_source": dataSource.Name,
+				"table":       t.Name,
+			})
+		}
+		outerID := gjson.Get(resp, "document.id").String()
+		if err != nil || outerID == "" {
+			logger.Error("上传数据字典文档失败", logger.F("table", t.Name), logger.F("resp", resp), logger.F("error", err))
+			result.Failed++
+			continue
+		}
+
+		if doc == nil {
+			doc = &model.SchemaDocument{
+				ApplicationID:   dataSource.ApplicationID,
+				DataSourceID:    dataSource.ID,
+				TableID:         t.ID,
+				KnowledgeBaseID: kb.ID,
+				TableName:       t.Name,
+				OuterID:         outerID,
+				ContentHash:     hash,
+			}
+			err = s.db.Create(doc).Error
+			result.Created++
+		} else {
+			err = s.db.Model(doc).Updates(&model.SchemaDocument{TableName: t.Name, OuterID: outerID, ContentHash: hash}).Error
+			result.Updated++
+		}
+		if err != nil {
+			logger.Error("保存数据字典文档失败", logger.F("table", t.Name), logger.F("error", err))
+		}
+	}
+
+	// 剩余的是已删除或不再开放给AI的表
+	for _, doc := range existing {
+		if s.deleteDocument(ctx, kbClient, doc) {
+			result.Deleted++
+		} else {
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// Unpublish 从知识库中删除数据源的全部数据字典文档，删除失败的文档保留记录，可再次调用
+func (s *schemaKnowledgeService) Unpublish(ctx context.Context, dataSourceID uint64) (int, error) {
+	unlock := s.lock(dataSourceID)
+	defer unlock()
+
+	var documents []*model.SchemaDocument
+	if err := s.db.Where("data_source_id = ?", dataSourceID).Find(&documents).Error; err != nil {
+		logger.Error("查询数据字典文档失败", logger.F("error", err))
+		return 0, constant.ErrDatabaseError
+	}
+	if len(documents) == 0 {
+		return 0, nil
+	}
+	kbClient, err := s.knowledgeBaseService.GetDifyKnowledgeBaseClient(ctx)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, doc := range documents {
+		if s.deleteDocument(ctx, kbClient, doc) {
+			deleted++
+		}
+	}
+	if deleted < len(documents) {
+		return deleted, constant.ErrInternalError
+	}
+	return deleted, nil
+}
+
+// deleteDocument 从dify及本地删除文档，知识库已不存在时只删除本地记录
+func (s *schemaKnowledgeService) deleteDocument(ctx context.Context, kbClient *dify.KnowledgeBaseClient, doc *model.SchemaDocument) bool {
+	kb, err := s.knowledgeBaseService.Get(ctx, doc.KnowledgeBaseID)
+	if err != nil && !errors.Is(err, constant.ErrRecordNotFound) {
+		return false
+	}
+	if kb != nil && kb.OuterID != "" {
+		if err := kbClient.DeleteDocument(kb.OuterID, doc.OuterID); err != nil {
+			logger.Error("删除数据字典文档失败", logger.F("table", doc.TableName), logger.F("error", err))
+			return false
+		}
+	}
+	if err := s.db.Delete(doc).Error; err != nil {
+		logger.Error("删除数据字典文档记录失败", logger.F("table", doc.TableName), logger.F("error", err))
+		return false
+	}
+	return true
+}
+
+// renderSchemaDocument 单张表的数据字典文档：数据源、表的业务说明、示例问题、列（含业务术语、单位、枚举值及取值分布）、关联及索引
+func renderSchemaDocument(dataSource *model.DataSource, t *SchemaTable) string {
+	var b strings.Builder
+	title := t.Name
+	if t.BusinessName != "" {
+		title += "（" + singleLine(t.BusinessName) + "）"
+	}
+	b.WriteString("# 数据字典：" + title + "\n\n")
+	b.WriteString("数据源：" + dataSource.Name + "（" + dataSource.Type + "），查询时使用表名 " + t.Name + "\n\n")
+	b.WriteString(renderTableMarkdown(t, t.Columns))
+	if len(t.Relations) > 0 {
+		b.WriteString("\n关联：\n")
+		for _, r := range t.Relations {
+			b.WriteString("- " + r + "\n")
+		}
+	}
+	if len(t.Indexes) > 0 {
+		b.WriteString("\n索引：\n")
+		for _, idx := range t.Indexes {
+			line := "- " + idx.Name + "(" + strings.Join(idx.Columns, ", ") + ")"
+			if idx.Unique {
+				line += " 唯一"
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
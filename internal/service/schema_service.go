@@ -0,0 +1,647 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/masking"
+	"github.com/yockii/dify_tools/pkg/search"
+)
+
+// 表结构输出格式
+const (
+	SchemaFormatJSON     = "json"     // 完整JSON（默认）
+	SchemaFormatCompact  = "compact"  // 精简JSON
+	SchemaFormatDDL      = "ddl"      // 带注释的 CREATE TABLE 语句
+	SchemaFormatMarkdown = "markdown" // Markdown表格
+)
+
+type schemaService struct {
+	tableInfoService  TableInfoService
+	columnInfoService ColumnInfoService
+}
+
+func NewSchemaService(
+	tableInfoService TableInfoService,
+	columnInfoService ColumnInfoService,
+) *schemaService {
+	return &schemaService{
+		tableInfoService:  tableInfoService,
+		columnInfoService: columnInfoService,
+	}
+}
+
+// ListTables 获取数据源中开放给AI的表及其列，关联提示只包含开放的表之间的外键；
+// 脱敏列及设置了行级过滤的表不输出取值分布（可能在同步后才修改了设置）
+func (s *schemaService) ListTables(ctx context.Context, dataSourceID uint64) ([]*SchemaTable, error) {
+	list, err := s.tableInfoService.ListSchemaForDify(ctx, &model.TableInfo{
+		DataSourceID: dataSourceID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	columns, err := s.columnInfoService.ListSchemaForDify(ctx, &model.ColumnInfo{
+		DataSourceID: dataSourceID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]*SchemaTable, 0, len(list))
+	byID := make(map[uint64]*SchemaTable, len(list))
+	exposed := make(map[string]bool, len(list))
+	filtered := make(map[uint64]bool)
+	for _, t := range list {
+		st := &SchemaTable{TableInfo: t}
+		tables = append(tables, st)
+		byID[t.ID] = st
+		exposed[t.Name] = true
+		if t.RowFilter != "" {
+			filtered[t.ID] = true
+			t.RowFilter = ""
+		}
+	}
+	for _, c := range columns {
+		if st := byID[c.TableID]; st != nil {
+			if filtered[c.TableID] || masking.IsMasked(c.MaskType) {
+				c.Profile = nil
+			}
+			st.Columns = append(st.Columns, c)
+		}
+	}
+	for _, st := range tables {
+		for _, c := range st.Columns {
+			if c.RefTable == "" || c.RefColumn == "" {
+				continue
+			}
+			if !exposed[c.RefTable] {
+				c.RefTable, c.RefColumn = "", ""
+				continue
+			}
+			st.Relations = append(st.Relations, fmt.Sprintf("%s.%s = %s.%s", st.Name, c.Name, c.RefTable, c.RefColumn))
+		}
+	}
+	return tables, nil
+}
+
+// Render 按格式输出表结构。maxTokens 大于0时按估算的token数裁剪：
+// 先省略价值较低的列（无注释、审计字段等，主键及外键列保留），仍超出时再省略关联少、无注释的表
+func (s *schemaService) Render(tables []*SchemaTable, format string, maxTokens int) (interface{}, error) {
+	var render func(t *SchemaTable, cols []*model.ColumnInfo) string
+	switch format {
+	case "", SchemaFormatJSON:
+		render = renderTableJSON
+	case SchemaFormatCompact:
+		render = renderTableCompact
+	case SchemaFormatDDL:
+		render = renderTableDDL
+	case SchemaFormatMarkdown:
+		render = renderTableMarkdown
+	default:
+		return nil, constant.ErrInvalidParams
+	}
+
+	kept, omittedTables, omittedColumns := fitSchemaBudget(tables, render, maxTokens)
+	parts := make([]string, len(kept))
+	for i, k := range kept {
+		parts[i] = render(k.table, k.columns)
+	}
+
+	switch format {
+	case SchemaFormatDDL, SchemaFormatMarkdown:
+		text := strings.Join(parts, "\n")
+		if omittedTables > 0 || omittedColumns > 0 {
+			note := fmt.Sprintf("因长度限制省略了%d张表、%d个列", omittedTables, omittedColumns)
+			if format == SchemaFormatDDL {
+				text += "\n-- " + note + "\n"
+			} else {
+				text += "\n> " + note + "\n"
+			}
+		}
+		return text, nil
+	case SchemaFormatCompact:
+		result := struct {
+			Tables         []json.RawMessage `json:"tables"`
+			OmittedTables  int               `json:"omittedTables,omitempty"`
+			OmittedColumns int               `json:"omittedColumns,omitempty"`
+		}{
+			Tables:         make([]json.RawMessage, len(parts)),
+			OmittedTables:  omittedTables,
+			OmittedColumns: omittedColumns,
+		}
+		for i, p := range parts {
+			result.Tables[i] = json.RawMessage(p)
+		}
+		return result, nil
+	default:
+		result := make([]json.RawMessage, len(parts))
+		for i, p := range parts {
+			result[i] = json.RawMessage(p)
+		}
+		return result, nil
+	}
+}
+
+// keptTable 裁剪后保留的表及列
+type keptTable struct {
+	table   *SchemaTable
+	columns []*model.ColumnInfo
+	score   int
+	cost    int
+}
+
+func fitSchemaBudget(tables []*SchemaTable, render func(t *SchemaTable, cols []*model.ColumnInfo) string, maxTokens int) (kept []*keptTable, omittedTables, omittedColumns int) {
+	// 被引用次数，被引用多的表价值高
+	referenced := make(map[string]int)
+	for _, t := range tables {
+		for _, c := range t.Columns {
+			if c.RefTable != "" {
+				referenced[c.RefTable]++
+			}
+		}
+	}
+
+	total := 0
+	for _, t := range tables {
+		k := &keptTable{
+			table:   t,
+			columns: t.Columns,
+			score:   tableScore(t, referenced[t.Name]),
+		}
+		if maxTokens > 0 {
+			k.cost = estimateTokens(render(t, k.columns))
+			total += k.cost
+		}
+		kept = append(kept, k)
+	}
+	if maxTokens <= 0 || total <= maxTokens {
+		return kept, 0, 0
+	}
+
+	// 先按价值从低到高省略列
+	type candidate struct {
+		table  *keptTable
+		column *model.ColumnInfo
+		score  int
+	}
+	var candidates []candidate
+	for _, k := range kept {
+		for _, c := range k.columns {
+			if c.PrimaryKey || c.RefTable != "" {
+				continue
+			}
+			candidates = append(candidates, candidate{table: k, column: c, score: columnScore(c)})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score < candidates[j].score
+	})
+	for _, cand := range candidates {
+		if total <= maxTokens || cand.score >= columnScoreKeep {
+			break
+		}
+		k := cand.table
+		// 每张表至少保留一列
+		if len(k.columns) <= 1 {
+			continue
+		}
+		columns := make([]*model.ColumnInfo, 0, len(k.columns)-1)
+		for _, c := range k.columns {
+			if c != cand.column {
+				columns = append(columns, c)
+			}
+		}
+		k.columns = columns
+		cost := estimateTokens(render(k.table, k.columns))
+		total += cost - k.cost
+		k.cost = cost
+		omittedColumns++
+	}
+
+	// 再按价值从低到高省略表，至少保留一张
+	if total > maxTokens {
+		order := make([]*keptTable, len(kept))
+		copy(order, kept)
+		sort.SliceStable(order, func(i, j int) bool {
+			return order[i].score < order[j].score
+		})
+		dropped := make(map[*keptTable]bool)
+		for _, k := range order {
+			if total <= maxTokens || len(dropped) == len(kept)-1 {
+				break
+			}
+			dropped[k] = true
+			total -= k.cost
+			omittedTables++
+			// 省略的列只统计保留的表中的
+			omittedColumns -= len(k.table.Columns) - len(k.columns)
+		}
+		remaining := kept[:0]
+		for _, k := range kept {
+			if !dropped[k] {
+				remaining = append(remaining, k)
+			}
+		}
+		kept = remaining
+	}
+	return kept, omittedTables, omittedColumns
+}
+
+// columnScoreKeep 不低于该分值的列（有注释的业务列）不在第一轮裁剪中省略
+const columnScoreKeep = 10
+
+// 审计类字段，对回答业务问题帮助较小
+var auditColumns = map[string]bool{
+	"created_at": true, "updated_at": true, "deleted_at": true, "create_time": true, "update_time": true,
+	"delete_time": true, "created_by": true, "updated_by": true, "deleted_by": true, "create_by": true,
+	"update_by": true, "creator": true, "modifier": true, "updater": true, "is_deleted": true,
+	"del_flag": true, "deleted": true, "version": true, "revision": true, "tenant_id": true,
+}
+
+func columnScore(c *model.ColumnInfo) int {
+	score := 5
+	if c.Comment != "" || c.BusinessName != "" || c.Description != "" {
+		score += 10
+	}
+	if auditColumns[strings.ToLower(c.Name)] {
+		score -= 10
+	}
+	if masking.IsMasked(c.MaskType) {
+		score -= 2
+	}
+	return score
+}
+
+func tableScore(t *SchemaTable, referenced int) int {
+	score := referenced*10 + len(t.Relations)*5
+	if t.Comment != "" || t.BusinessName != "" || t.Description != "" {
+		score += 10
+	}
+	if t.Kind == "view" {
+		score -= 3
+	}
+	return score
+}
+
+// estimateTokens 粗略估算token数：中日韩字符约1个token，其余约4个字符1个token
+func estimateTokens(s string) int {
+	cjk, other := 0, 0
+	for _, r := range s {
+		if r >= utf8.RuneSelf && (unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// renderTableJSON 原有的完整JSON格式
+func renderTableJSON(t *SchemaTable, cols []*model.ColumnInfo) string {
+	table := *t.TableInfo
+	table.ID = 0
+	columns := make([]*model.ColumnInfo, len(cols))
+	for i, c := range cols {
+		column := *c
+		column.TableID, column.RefTable, column.RefColumn = 0, "", ""
+		columns[i] = &column
+	}
+	b, _ := json.Marshal(&SchemaTable{
+		TableInfo: &table,
+		Columns:   columns,
+		Relations: t.Relations,
+	})
+	return string(b)
+}
+
+// renderTableCompact 精简JSON，每列为一个字符串，如 "user_id bigint PK FK→users.id 用户ID"
+func renderTableCompact(t *SchemaTable, cols []*model.ColumnInfo) string {
+	table := struct {
+		Name     string   `json:"name"`
+		Comment  string   `json:"comment,omitempty"`
+		View     bool     `json:"view,omitempty"`
+		Columns  []string `json:"columns"`
+		Examples []string `json:"examples,omitempty"`
+	}{
+		Name:     t.Name,
+		Comment:  strings.Join(tableNotes(t.TableInfo), "；"),
+		View:     t.Kind == "view",
+		Columns:  make([]string, len(cols)),
+		Examples: t.ExampleQuestions,
+	}
+	for i, c := range cols {
+		parts := []string{c.Name, c.Type}
+		if c.PrimaryKey {
+			parts = append(parts, "PK")
+		}
+		if c.RefTable != "" {
+			parts = append(parts, "FK→"+c.RefTable+"."+c.RefColumn)
+		}
+		if masking.IsMasked(c.MaskType) {
+			parts = append(parts, "脱敏")
+		}
+		if notes := columnNotes(c); len(notes) > 0 {
+			parts = append(parts, strings.Join(notes, "；"))
+		}
+		table.Columns[i] = strings.Join(parts, " ")
+	}
+	b, _ := json.Marshal(table)
+	return string(b)
+}
+
+// renderTableDDL CREATE TABLE 风格，注释以 -- 给出
+func renderTableDDL(t *SchemaTable, cols []*model.ColumnInfo) string {
+	var b strings.Builder
+	if notes := tableNotes(t.TableInfo); len(notes) > 0 {
+		b.WriteString("-- " + strings.Join(notes, "；") + "\n")
+	}
+	if len(t.ExampleQuestions) > 0 {
+		b.WriteString("-- 示例问题：" + singleLine(strings.Join(t.ExampleQuestions, "；")) + "\n")
+	}
+	kind := "TABLE"
+	if t.Kind == "view" {
+		kind = "VIEW"
+	}
+	b.WriteString("CREATE " + kind + " " + t.Name + " (\n")
+	for i, c := range cols {
+		b.WriteString("  " + c.Name + " " + c.Type)
+		if c.PrimaryKey {
+			b.WriteString(" PRIMARY KEY")
+		}
+		if c.RefTable != "" {
+			b.WriteString(" REFERENCES " + c.RefTable + "(" + c.RefColumn + ")")
+		}
+		if i < len(cols)-1 {
+			b.WriteString(",")
+		}
+		notes := columnNotes(c)
+		if masking.IsMasked(c.MaskType) {
+			notes = append(notes, "已脱敏")
+		}
+		if len(notes) > 0 {
+			b.WriteString(" -- " + strings.Join(notes, "；"))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(");\n")
+	return b.String()
+}
+
+// renderTableMarkdown 每张表一个Markdown表格
+func renderTableMarkdown(t *SchemaTable, cols []*model.ColumnInfo) string {
+	var b strings.Builder
+	b.WriteString("### " + t.Name)
+	if t.Kind == "view" {
+		b.WriteString("（视图）")
+	}
+	if notes := tableNotes(t.TableInfo); len(notes) > 0 {
+		b.WriteString(" " + strings.Join(notes, "；"))
+	}
+	b.WriteString("\n")
+	if len(t.ExampleQuestions) > 0 {
+		b.WriteString("\n示例问题：" + singleLine(strings.Join(t.ExampleQuestions, "；")) + "\n\n")
+	}
+	b.WriteString("| 列 | 类型 | 说明 |\n|---|---|---|\n")
+	for _, c := range cols {
+		var notes []string
+		if c.PrimaryKey {
+			notes = append(notes, "主键")
+		}
+		if c.RefTable != "" {
+			notes = append(notes, "关联"+c.RefTable+"."+c.RefColumn)
+		}
+		if masking.IsMasked(c.MaskType) {
+			notes = append(notes, "已脱敏")
+		}
+		notes = append(notes, columnNotes(c)...)
+		b.WriteString("| " + c.Name + " | " + c.Type + " | " + strings.ReplaceAll(strings.Join(notes, "；"), "|", "\\|") + " |\n")
+	}
+	return b.String()
+}
+
+// tableNotes 表的说明：业务名称、注释、业务说明及同义词
+func tableNotes(t *model.TableInfo) []string {
+	return semanticNotes(t.BusinessName, t.Comment, t.Description, t.Synonyms)
+}
+
+// columnNotes 列的说明：在表说明的基础上增加单位、枚举值含义及取值分布
+func columnNotes(c *model.ColumnInfo) []string {
+	notes := semanticNotes(c.BusinessName, c.Comment, c.Description, c.Synonyms)
+	if c.Unit != "" {
+		notes = append(notes, "单位："+singleLine(c.Unit))
+	}
+	if len(c.EnumValues) > 0 {
+		values := make([]string, len(c.EnumValues))
+		for i, v := range c.EnumValues {
+			values[i] = v.Value
+			if v.Meaning != "" {
+				values[i] += "=" + v.Meaning
+			}
+		}
+		notes = append(notes, "取值："+singleLine(strings.Join(values, "，")))
+	}
+	if p := c.Profile; p != nil {
+		// 已标注枚举值含义时不再列出样本中的取值
+		if len(p.Values) > 0 && len(c.EnumValues) == 0 {
+			notes = append(notes, "样本取值："+singleLine(strings.Join(p.Values, "、")))
+		}
+		if p.Min != "" || p.Max != "" {
+			notes = append(notes, "范围："+p.Min+" ~ "+p.Max)
+		}
+		if p.NullRatio > 0 {
+			if pct := p.NullRatio * 100; pct < 1 {
+				notes = append(notes, "空值<1%")
+			} else {
+				notes = append(notes, fmt.Sprintf("空值%.0f%%", pct))
+			}
+		}
+	}
+	return notes
+}
+
+func semanticNotes(businessName, comment, description, synonyms string) []string {
+	var notes []string
+	if businessName != "" {
+		notes = append(notes, singleLine(businessName))
+	}
+	if comment != "" && comment != businessName {
+		notes = append(notes, singleLine(comment))
+	}
+	if description != "" {
+		notes = append(notes, singleLine(description))
+	}
+	if synonyms != "" {
+		notes = append(notes, "又称"+strings.ReplaceAll(singleLine(synonyms), ",", "、"))
+	}
+	return notes
+}
+
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Search 按问题检索相关的表：对表名、注释、业务名称、同义词、业务说明及列的对应信息做BM25排序，
+// 返回最相关的 topN 张表及与其有外键关联的表；maxColumns 大于0时每张表只保留主键、外键及与问题最相关的列
+func (s *schemaService) Search(ctx context.Context, dataSourceID uint64, question string, topN, maxColumns int) ([]*SchemaTable, error) {
+	tables, err := s.ListTables(ctx, dataSourceID)
+	if err != nil {
+		return nil, err
+	}
+	query := search.Tokenize(question)
+	if len(tables) == 0 || len(query) == 0 || topN <= 0 {
+		return []*SchemaTable{}, nil
+	}
+
+	docs := make([][]string, len(tables))
+	for i, t := range tables {
+		// 表本身的名称、注释及同义词权重更高
+		own := search.Tokenize(t.Name + " " + t.Comment + " " + t.BusinessName + " " + t.Synonyms)
+		doc := append(append(append([]string{}, own...), own...), own...)
+		doc = append(doc, search.Tokenize(t.Description+" "+strings.Join(t.ExampleQuestions, " "))...)
+		for _, c := range t.Columns {
+			doc = append(doc, columnTokens(c)...)
+		}
+		docs[i] = doc
+	}
+	index := search.NewIndex(docs)
+	scores := index.Score(query)
+
+	ranked := make([]int, 0, len(tables))
+	for i, score := range scores {
+		if score > 0 {
+			ranked = append(ranked, i)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i]] > scores[ranked[j]]
+	})
+	if len(ranked) > topN {
+		ranked = ranked[:topN]
+	}
+
+	// 外键关联的表（引用的及被引用的），按相关度最多补充 topN 张
+	byName := make(map[string]int, len(tables))
+	for i, t := range tables {
+		byName[t.Name] = i
+	}
+	selected := make(map[int]bool, len(ranked))
+	for _, i := range ranked {
+		selected[i] = true
+	}
+	neighbourSet := make(map[int]bool)
+	for i, t := range tables {
+		for _, c := range t.Columns {
+			j, ok := byName[c.RefTable]
+			if !ok || c.RefTable == "" {
+				continue
+			}
+			switch {
+			case selected[i] && !selected[j]:
+				neighbourSet[j] = true
+			case selected[j] && !selected[i]:
+				neighbourSet[i] = true
+			}
+		}
+	}
+	neighbours := make([]int, 0, len(neighbourSet))
+	for i := range neighbourSet {
+		neighbours = append(neighbours, i)
+	}
+	sort.Slice(neighbours, func(i, j int) bool {
+		if scores[neighbours[i]] != scores[neighbours[j]] {
+			return scores[neighbours[i]] > scores[neighbours[j]]
+		}
+		return neighbours[i] < neighbours[j]
+	})
+	if len(neighbours) > topN {
+		neighbours = neighbours[:topN]
+	}
+
+	included := make(map[string]bool)
+	result := make([]*SchemaTable, 0, len(ranked)+len(neighbours))
+	for _, i := range append(ranked, neighbours...) {
+		included[tables[i].Name] = true
+		result = append(result, tables[i])
+	}
+	for _, t := range result {
+		if maxColumns > 0 && len(t.Columns) > maxColumns {
+			t.Columns = relevantColumns(t.Columns, index, query, maxColumns)
+		}
+		relations := t.Relations[:0]
+		for _, c := range t.Columns {
+			if c.RefTable != "" && !included[c.RefTable] {
+				c.RefTable, c.RefColumn = "", ""
+			}
+		}
+		for _, r := range t.Relations {
+			// 关联提示形如 a.x = b.y
+			if _, target, ok := strings.Cut(r, " = "); ok && included[strings.SplitN(target, ".", 2)[0]] {
+				relations = append(relations, r)
+			}
+		}
+		t.Relations = relations
+	}
+	return result, nil
+}
+
+func columnTokens(c *model.ColumnInfo) []string {
+	text := c.Name + " " + c.Comment + " " + c.BusinessName + " " + c.Synonyms + " " + c.Description
+	for _, v := range c.EnumValues {
+		text += " " + v.Meaning
+	}
+	if c.Profile != nil {
+		text += " " + strings.Join(c.Profile.Values, " ")
+	}
+	return search.Tokenize(text)
+}
+
+// relevantColumns 保留主键、外键及与问题最相关的列，共 maxColumns 列，保持原有顺序
+func relevantColumns(columns []*model.ColumnInfo, index *search.Index, query []string, maxColumns int) []*model.ColumnInfo {
+	terms := make(map[string]bool, len(query))
+	for _, t := range query {
+		terms[t] = true
+	}
+	type scored struct {
+		pos   int
+		score float64
+	}
+	var keep []int
+	var others []scored
+	for i, c := range columns {
+		if c.PrimaryKey || c.RefTable != "" {
+			keep = append(keep, i)
+			continue
+		}
+		score := 0.0
+		seen := make(map[string]bool)
+		for _, t := range columnTokens(c) {
+			if terms[t] && !seen[t] {
+				seen[t] = true
+				score += index.IDF(t)
+			}
+		}
+		others = append(others, scored{pos: i, score: score})
+	}
+	sort.SliceStable(others, func(i, j int) bool {
+		return others[i].score > others[j].score
+	})
+	for _, o := range others {
+		if len(keep) >= maxColumns {
+			break
+		}
+		keep = append(keep, o.pos)
+	}
+	sort.Ints(keep)
+	result := make([]*model.ColumnInfo, len(keep))
+	for i, pos := range keep {
+		result[i] = columns[pos]
+	}
+	return result
+}
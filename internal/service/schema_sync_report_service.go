@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"gorm.io/gorm"
+)
+
+type schemaSyncReportService struct {
+	*BaseServiceImpl[*model.SchemaSyncReport]
+}
+
+func NewSchemaSyncReportService() *schemaSyncReportService {
+	srv := new(schemaSyncReportService)
+	srv.BaseServiceImpl = NewBaseService(BaseServiceConfig[*model.SchemaSyncReport]{
+		NewModel:       srv.NewModel,
+		BuildCondition: srv.BuildCondition,
+	})
+	return srv
+}
+
+func (s *schemaSyncReportService) NewModel() *model.SchemaSyncReport {
+	return &model.SchemaSyncReport{}
+}
+
+func (s *schemaSyncReportService) BuildCondition(query *gorm.DB, condition *model.SchemaSyncReport) *gorm.DB {
+	if condition.ApplicationID != 0 {
+		query = query.Where("application_id = ?", condition.ApplicationID)
+	}
+	if condition.DataSourceID != 0 {
+		query = query.Where("data_source_id = ?", condition.DataSourceID)
+	}
+	if condition.Pending > 0 {
+		query = query.Where("pending > 0")
+	}
+	return query
+}
+
+// GetWithItems 获取同步报告及其差异项
+func (s *schemaSyncReportService) GetWithItems(ctx context.Context, id uint64) (*model.SchemaSyncReport, error) {
+	var report model.SchemaSyncReport
+	if err := s.db.First(&report, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, constant.ErrRecordNotFound
+		}
+		logger.Error("查询同步报告失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	if err := s.db.Where("report_id = ?", id).Order("table_name, column_name").Find(&report.Items).Error; err != nil {
+		logger.Error("查询同步差异失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	return &report, nil
+}
+
+// Resolve 确认（apply 为 true）或拒绝报告中待确认的删除，itemIDs 为空时处理全部待确认项。
+// 确认后删除对应的表/列信息，拒绝则保留，之后的同步不再重复报告
+func (s *schemaSyncReportService) Resolve(ctx context.Context, id uint64, itemIDs []uint64, apply bool) (*model.SchemaSyncReport, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var report model.SchemaSyncReport
+		if err := tx.First(&report, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return constant.ErrRecordNotFound
+			}
+			logger.Error("查询同步报告失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+
+		query := tx.Where("report_id = ? AND change_type = ? AND status = ?", id, model.SyncChangeRemoved, model.SyncItemStatusPending)
+		if len(itemIDs) > 0 {
+			query = query.Where("id IN ?", itemIDs)
+		}
+		var items []*model.SchemaSyncItem
+		if err := query.Find(&items).Error; err != nil {
+			logger.Error("查询同步差异失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		status := model.SyncItemStatusRejected
+		if apply {
+			status = model.SyncItemStatusApplied
+			if err := s.deleteRemoved(tx, items); err != nil {
+				return err
+			}
+		}
+		ids := make([]uint64, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+		if err := tx.Model(&model.SchemaSyncItem{}).Where("id IN ?", ids).Update("status", status).Error; err != nil {
+			logger.Error("更新同步差异失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+
+		var pending int64
+		if err := tx.Model(&model.SchemaSyncItem{}).
+			Where("report_id = ? AND status = ?", id, model.SyncItemStatusPending).
+			Count(&pending).Error; err != nil {
+			logger.Error("查询同步差异失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+		if err := tx.Model(&report).Update("pending", pending).Error; err != nil {
+			logger.Error("更新同步报告失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetWithItems(ctx, id)
+}
+
+// deleteRemoved 删除数据库中已不存在的表/列信息，删除表时一并删除其列信息
+func (s *schemaSyncReportService) deleteRemoved(tx *gorm.DB, items []*model.SchemaSyncItem) error {
+	var tableIDs, columnIDs []uint64
+	for _, item := range items {
+		if item.ColumnID != 0 {
+			columnIDs = append(columnIDs, item.ColumnID)
+		} else {
+			tableIDs = append(tableIDs, item.TableID)
+		}
+	}
+	if len(columnIDs) > 0 {
+		if err := tx.Where("id IN ?", columnIDs).Delete(&model.ColumnInfo{}).Error; err != nil {
+			logger.Error("删除列信息失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+	}
+	if len(tableIDs) > 0 {
+		if err := tx.Where("table_id IN ?", tableIDs).Delete(&model.ColumnInfo{}).Error; err != nil {
+			logger.Error("删除列信息失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+		if err := tx.Where("id IN ?", tableIDs).Delete(&model.TableInfo{}).Error; err != nil {
+			logger.Error("删除表信息失败", logger.F("error", err))
+			return constant.ErrDatabaseError
+		}
+	}
+	return nil
+}
@@ -2,12 +2,15 @@ package service
 
 import (
 	"context"
+	"io"
 	"mime/multipart"
 	"net/http"
 
 	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/datasource"
 	"github.com/yockii/dify_tools/internal/dify"
 	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/sqlguard"
 )
 
 const (
@@ -87,18 +90,214 @@ type ApplicationService interface {
 
 type DataSourceService interface {
 	BaseService[*model.DataSource]
-	Sync(ctx context.Context, id uint64) error
+	Sync(ctx context.Context, id uint64) (*model.SchemaSyncReport, error)
+	Test(ctx context.Context, dataSource *model.DataSource) (*datasource.TestResult, error)
+	Import(ctx context.Context, dataSource *model.DataSource, fileHeader *multipart.FileHeader) error
+	ImportGlossary(ctx context.Context, dataSourceID uint64, fileHeader *multipart.FileHeader) (*GlossaryImportResult, error)
 	ListForDify(ctx context.Context, condition *model.DataSource) ([]*model.DataSource, error)
+	ExportDictionary(ctx context.Context, dataSource *model.DataSource, all bool) (string, error)
+}
+
+// GlossaryImportResult 业务术语导入结果
+type GlossaryImportResult struct {
+	Tables    int      `json:"tables"`              // 更新的表数
+	Columns   int      `json:"columns"`             // 更新的列数
+	Unmatched []string `json:"unmatched,omitempty"` // 未找到的表或列，如 orders.status
+}
+
+type SchemaSyncReportService interface {
+	BaseService[*model.SchemaSyncReport]
+	GetWithItems(ctx context.Context, id uint64) (*model.SchemaSyncReport, error)
+	Resolve(ctx context.Context, id uint64, itemIDs []uint64, apply bool) (*model.SchemaSyncReport, error)
 }
 
 type TableInfoService interface {
 	BaseService[*model.TableInfo]
+	UpdateFields(ctx context.Context, record *model.TableInfo, fields []string) error
 	ListSchemaForDify(ctx context.Context, condition *model.TableInfo) ([]*model.TableInfo, error)
+	ListQueryable(ctx context.Context, dataSourceID uint64) ([]*model.TableInfo, error)
 }
 
 type ColumnInfoService interface {
 	BaseService[*model.ColumnInfo]
+	UpdateFields(ctx context.Context, record *model.ColumnInfo, fields []string) error
 	ListSchemaForDify(ctx context.Context, condition *model.ColumnInfo) ([]*model.ColumnInfo, error)
+	ListMaskedColumns(ctx context.Context, tableIDs []uint64) ([]*model.ColumnInfo, error)
+	ConfirmPii(ctx context.Context, ids []uint64, confirm bool, maskType string) (int, error)
+}
+
+type SchemaService interface {
+	ListTables(ctx context.Context, dataSourceID uint64) ([]*SchemaTable, error)
+	Render(tables []*SchemaTable, format string, maxTokens int) (interface{}, error)
+	Search(ctx context.Context, dataSourceID uint64, question string, topN, maxColumns int) ([]*SchemaTable, error)
+}
+
+// SchemaTable 开放给AI的表结构
+type SchemaTable struct {
+	*model.TableInfo
+	Columns   []*model.ColumnInfo `json:"columns"`
+	Relations []string            `json:"relations,omitempty"` // 关联提示，如 orders.user_id = users.id
+}
+
+type SchemaKnowledgeService interface {
+	BaseService[*model.SchemaDocument]
+	Publish(ctx context.Context, dataSourceID uint64) (*SchemaPublishResult, error)
+	Unpublish(ctx context.Context, dataSourceID uint64) (int, error)
+}
+
+// SchemaPublishResult 数据字典发布结果
+type SchemaPublishResult struct {
+	Created   int `json:"created"`   // 新上传的文档数
+	Updated   int `json:"updated"`   // 内容变化重新上传的文档数
+	Deleted   int `json:"deleted"`   // 表已删除或不再开放给AI而删除的文档数
+	Unchanged int `json:"unchanged"` // 内容未变化的文档数
+	Failed    int `json:"failed"`    // 上传或删除失败的文档数
+}
+
+type QueryService interface {
+	ExecuteSql(ctx context.Context, dataSource *model.DataSource, req *QueryRequest) (*QueryResult, error)
+	Export(ctx context.Context, dataSource *model.DataSource, req *ExportRequest) (*ExportResult, error)
+}
+
+// QueryRequest SQL执行请求
+type QueryRequest struct {
+	Sql       string
+	Variables map[string]string // 行级过滤条件引用的变量
+	Cursor    string            // 上次结果返回的续查游标
+	Source    string            // 调用来源，记录在SQL审计中
+	Question  string            // SQL对应的用户问题，记录在SQL审计中，可转为SQL示例
+
+	dbError error // 执行失败时数据库返回的原始错误，问题转SQL时反馈给SQL构建器
+}
+
+// RowFilterVariables 合并请求中的行级过滤变量，customId 为 custom_id 的简写
+func RowFilterVariables(variables map[string]string, customID string) map[string]string {
+	merged := make(map[string]string, len(variables)+1)
+	for k, v := range variables {
+		merged[k] = v
+	}
+	if customID != "" {
+		merged["custom_id"] = customID
+	}
+	return merged
+}
+
+// ExportRequest 导出SQL结果的请求，不支持续查游标
+type ExportRequest struct {
+	QueryRequest
+	Format   string // csv 或 xlsx，默认 xlsx
+	FileName string // 下载文件名，不含扩展名，为空时按时间生成
+}
+
+// ExportResult 导出结果
+type ExportResult struct {
+	FileName  string `json:"fileName"`
+	Url       string `json:"url"` // 带签名的下载地址，有效期为 files.url_ttl
+	ExpiresAt int64  `json:"expiresAt"`
+	Size      int64  `json:"size"`
+	Rows      int    `json:"rows"`
+	Truncated bool   `json:"truncated"` // 结果是否超过 export.max_rows 被截断
+}
+
+// QueryResult SQL执行结果
+type QueryResult struct {
+	Rows       []map[string]interface{} `json:"rows"`
+	Truncated  bool                     `json:"truncated"`            // 结果是否因行数或大小限制被截断
+	Total      *int64                   `json:"total,omitempty"`      // 总行数，截断时为尽力统计的结果，统计失败则不返回
+	NextCursor string                   `json:"nextCursor,omitempty"` // 续查游标，原样带上SQL再次请求可获取后续结果
+}
+
+type TextToSqlService interface {
+	Ask(ctx context.Context, dataSource *model.DataSource, req *TextToSqlRequest) (*TextToSqlResult, error)
+}
+
+// TextToSqlRequest 问题转SQL请求
+type TextToSqlRequest struct {
+	Question  string
+	Variables map[string]string // 行级过滤条件引用的变量
+}
+
+// TextToSqlResult 问题转SQL结果，SQL检查不通过时 Violation 为最后一次的原因
+type TextToSqlResult struct {
+	Sql       string              `json:"sql"`
+	Attempts  int                 `json:"attempts"` // 生成SQL的次数
+	Violation *sqlguard.Violation `json:"violation,omitempty"`
+	*QueryResult
+}
+
+type SavedQueryService interface {
+	BaseService[*model.SavedQuery]
+	ListForDify(ctx context.Context, dataSourceID uint64) ([]*model.SavedQuery, error)
+	Execute(ctx context.Context, dataSource *model.DataSource, req *SavedQueryRequest) (*QueryResult, error)
+}
+
+// SavedQueryRequest 预置查询执行请求
+type SavedQueryRequest struct {
+	Name      string
+	Params    map[string]interface{} // 参数值，可以是字符串、数字或布尔值
+	Variables map[string]string      // 行级过滤条件引用的变量
+	Cursor    string                 // 上次结果返回的续查游标
+}
+
+// QueryParamError 预置查询的参数不合法
+type QueryParamError struct {
+	Param  string `json:"param"`
+	Reason string `json:"reason"`
+}
+
+func (e *QueryParamError) Error() string {
+	return e.Param + ": " + e.Reason
+}
+
+type SqlExampleService interface {
+	BaseService[*model.SqlExample]
+	Promote(ctx context.Context, auditID uint64, question string) (*model.SqlExample, error)
+	Search(ctx context.Context, dataSourceID uint64, question string, topN int) ([]*SqlExampleMatch, error)
+}
+
+// SqlExampleMatch 与问题相似的SQL示例
+type SqlExampleMatch struct {
+	Question string  `json:"question"`
+	Sql      string  `json:"sql"`
+	Note     string  `json:"note,omitempty"`
+	Score    float64 `json:"score"`
+}
+
+type ChartService interface {
+	Render(ctx context.Context, dataSource *model.DataSource, req *ChartRequest) (*ChartResult, error)
+}
+
+// ChartRequest 图表绘制请求，数据来自 Rows，为空时执行 Query 获取
+type ChartRequest struct {
+	Rows    []map[string]interface{} // 已有的结果集
+	Query   *QueryRequest            // Rows 为空时执行的SQL，dataSource 不能为空
+	Type    string                   // bar、line 或 pie
+	Title   string
+	X       string   // 分类字段
+	Y       []string // 数值字段，为空时使用除X外的所有数值列，饼图只使用第一个
+	Format  string   // svg、png 或 mermaid，默认 svg
+	BaseURL string   // 图片地址的前缀，为空时返回相对路径
+}
+
+// ChartResult 图表绘制结果
+type ChartResult struct {
+	Format    string `json:"format"`
+	Url       string `json:"url,omitempty"` // svg、png 的带签名图片地址，有效期为 files.url_ttl
+	ExpiresAt int64  `json:"expiresAt,omitempty"`
+	Mermaid   string `json:"mermaid,omitempty"` // mermaid 代码，不含代码块标记
+	Markdown  string `json:"markdown"`          // 可直接放入回答的图片链接或mermaid代码块
+	Points    int    `json:"points"`            // 分类个数
+	Truncated bool   `json:"truncated"`         // 分类是否超过 chart.max_points 被截断
+}
+
+// ChartSpecError 图表参数不合法，如字段不存在或不是数值
+type ChartSpecError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (e *ChartSpecError) Error() string {
+	return e.Field + ": " + e.Reason
 }
 
 type KnowledgeBaseService interface {
@@ -117,6 +316,27 @@ type DocumentService interface {
 
 type AgentService interface {
 	BaseService[*model.Agent]
+	GetByCode(ctx context.Context, code string) (*model.Agent, error)
+}
+
+type SqlAuditService interface {
+	BaseService[*model.SqlAudit]
+	Record(ctx context.Context, record *model.SqlAudit)
+	Query(ctx context.Context, condition *SqlAuditCondition, offset, limit int) ([]*model.SqlAudit, int64, error)
+	Export(ctx context.Context, condition *SqlAuditCondition, w io.Writer) error
+	StartCleanup()
+}
+
+// SqlAuditCondition SQL审计查询条件
+type SqlAuditCondition struct {
+	ApplicationID uint64 `query:"applicationId"`
+	DataSourceID  uint64 `query:"dataSourceId"`
+	CustomID      string `query:"customId"`
+	Source        string `query:"source"`
+	Failed        int    `query:"failed"`    // 1: 只看失败或被拒绝的, -1: 只看成功的
+	Keyword       string `query:"keyword"`   // SQL中包含的内容
+	StartDate     string `query:"startDate"` // 开始日期，格式 2006-01-02
+	EndDate       string `query:"endDate"`   // 结束日期（包含当天）
 }
 
 type UsageService interface {
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// sqlAuditMaxSqlRunes 审计中保存的SQL最大长度
+const sqlAuditMaxSqlRunes = 20000
+
+type sqlAuditService struct {
+	*BaseServiceImpl[*model.SqlAudit]
+}
+
+func NewSqlAuditService() *sqlAuditService {
+	srv := new(sqlAuditService)
+	srv.BaseServiceImpl = NewBaseService(BaseServiceConfig[*model.SqlAudit]{
+		NewModel: srv.NewModel,
+	})
+	return srv
+}
+
+func (s *sqlAuditService) NewModel() *model.SqlAudit {
+	return &model.SqlAudit{}
+}
+
+// ///// 覆盖不允许处理的方法
+func (s *sqlAuditService) Update(ctx context.Context, record *model.SqlAudit) error {
+	return constant.ErrMethodNotAllow
+}
+
+func (s *sqlAuditService) Delete(ctx context.Context, id uint64) error {
+	return constant.ErrMethodNotAllow
+}
+
+// Record 保存审计记录，失败时只记录日志，不影响SQL执行
+func (s *sqlAuditService) Record(ctx context.Context, record *model.SqlAudit) {
+	// 超长的字段会导致保存失败（PostgreSQL或严格模式的MySQL），审计记录不能因此丢失，按列长度截断
+	record.CustomID = truncateRunes(record.CustomID, 100)
+	record.Source = truncateRunes(record.Source, 20)
+	record.Question = truncateRunes(record.Question, 500)
+	record.Sql = truncateRunes(record.Sql, sqlAuditMaxSqlRunes)
+	record.Error = truncateRunes(record.Error, 500)
+	if err := s.db.Create(record).Error; err != nil {
+		logger.Error("保存SQL审计记录失败", logger.F("error", err), logger.F("dataSourceId", record.DataSourceID))
+	}
+}
+
+// Query 按条件分页查询审计记录，按时间倒序
+func (s *sqlAuditService) Query(ctx context.Context, condition *SqlAuditCondition, offset, limit int) ([]*model.SqlAudit, int64, error) {
+	var list []*model.SqlAudit
+	var total int64
+	query, err := s.buildQuery(condition)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := query.Count(&total).Error; err != nil {
+		logger.Error("查询记录总数失败", logger.F("error", err))
+		return nil, 0, constant.ErrDatabaseError
+	}
+	if total > 0 && limit > 0 {
+		if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&list).Error; err != nil {
+			logger.Error("查询记录失败", logger.F("error", err))
+			return nil, 0, constant.ErrDatabaseError
+		}
+	}
+	return list, total, nil
+}
+
+// Export 按条件导出审计记录为CSV（带BOM，便于Excel打开），最多导出 audit.export_max_rows 条
+func (s *sqlAuditService) Export(ctx context.Context, condition *SqlAuditCondition, w io.Writer) error {
+	query, err := s.buildQuery(condition)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"时间", "应用ID", "数据源ID", "终端用户ID", "来源", "问题", "SQL", "耗时(ms)", "行数", "是否截断", "错误"})
+
+	var batch []*model.SqlAudit
+	exported := 0
+	maxRows := config.GetInt("audit.export_max_rows")
+	err = query.Order("created_at DESC, id DESC").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, a := range batch {
+			if maxRows > 0 && exported >= maxRows {
+				return io.EOF
+			}
+			writer.Write([]string{
+				a.CreatedAt.Format(time.DateTime),
+				strconv.FormatUint(a.ApplicationID, 10),
+				strconv.FormatUint(a.DataSourceID, 10),
+				a.CustomID,
+				a.Source,
+				a.Question,
+				a.Sql,
+				strconv.FormatInt(a.DurationMs, 10),
+				strconv.Itoa(a.Rows),
+				strconv.FormatBool(a.Truncated),
+				a.Error,
+			})
+			exported++
+		}
+		writer.Flush()
+		return writer.Error()
+	}).Error
+	if err != nil && !errors.Is(err, io.EOF) {
+		logger.Error("导出SQL审计记录失败", logger.F("error", err))
+		return constant.ErrDatabaseError
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (s *sqlAuditService) buildQuery(condition *SqlAuditCondition) (*gorm.DB, error) {
+	query := s.db.Model(s.NewModel())
+	if condition.ApplicationID > 0 {
+		query = query.Where("application_id = ?", condition.ApplicationID)
+	}
+	if condition.DataSourceID > 0 {
+		query = query.Where("data_source_id = ?", condition.DataSourceID)
+	}
+	if condition.CustomID != "" {
+		query = query.Where("custom_id = ?", condition.CustomID)
+	}
+	if condition.Source != "" {
+		query = query.Where("source = ?", condition.Source)
+	}
+	switch condition.Failed {
+	case 1:
+		query = query.Where("error <> ''")
+	case -1:
+		query = query.Where("error = ''")
+	}
+	if condition.Keyword != "" {
+		query = query.Where("sql_text LIKE ?", "%"+condition.Keyword+"%")
+	}
+	// 日期为 2006-01-02 格式，包含起止两天
+	if condition.StartDate != "" {
+		start, err := time.ParseInLocation(time.DateOnly, condition.StartDate, time.Local)
+		if err != nil {
+			return nil, constant.ErrInvalidParams
+		}
+		query = query.Where("created_at >= ?", start)
+	}
+	if condition.EndDate != "" {
+		end, err := time.ParseInLocation(time.DateOnly, condition.EndDate, time.Local)
+		if err != nil {
+			return nil, constant.ErrInvalidParams
+		}
+		query = query.Where("created_at < ?", end.AddDate(0, 0, 1))
+	}
+	return query, nil
+}
+
+// StartCleanup 启动后台清理，定期删除超过 audit.retention_days 天的审计记录，0为永久保留
+func (s *sqlAuditService) StartCleanup() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			s.cleanup()
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *sqlAuditService) cleanup() {
+	days := config.GetInt("audit.retention_days")
+	if days <= 0 {
+		return
+	}
+	result := s.db.Where("created_at < ?", time.Now().AddDate(0, 0, -days)).Delete(&model.SqlAudit{})
+	if result.Error != nil {
+		logger.Error("清理SQL审计记录失败", logger.F("error", result.Error))
+		return
+	}
+	if result.RowsAffected > 0 {
+		logger.Info("清理过期的SQL审计记录", logger.F("count", result.RowsAffected))
+	}
+}
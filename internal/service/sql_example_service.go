@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/search"
+	"github.com/yockii/dify_tools/pkg/sqlguard"
+	"gorm.io/gorm"
+)
+
+type sqlExampleService struct {
+	*BaseServiceImpl[*model.SqlExample]
+}
+
+func NewSqlExampleService() *sqlExampleService {
+	srv := new(sqlExampleService)
+	srv.BaseServiceImpl = NewBaseService(BaseServiceConfig[*model.SqlExample]{
+		NewModel:       srv.NewModel,
+		CheckDuplicate: srv.CheckDuplicate,
+		BuildCondition: srv.BuildCondition,
+		ListOrder:      srv.ListOrder,
+	})
+	return srv
+}
+
+func (s *sqlExampleService) NewModel() *model.SqlExample {
+	return &model.SqlExample{}
+}
+
+func (s *sqlExampleService) CheckDuplicate(record *model.SqlExample) (bool, error) {
+	query := s.db.Model(s.NewModel()).Where("data_source_id = ? AND question = ?", record.DataSourceID, record.Question)
+	if record.ID != 0 {
+		query = query.Where("id <> ?", record.ID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		logger.Error("查询记录失败", logger.F("error", err))
+		return false, constant.ErrDatabaseError
+	}
+	return count > 0, nil
+}
+
+func (s *sqlExampleService) BuildCondition(query *gorm.DB, condition *model.SqlExample) *gorm.DB {
+	if condition.ApplicationID != 0 {
+		query = query.Where("application_id = ?", condition.ApplicationID)
+	}
+	if condition.DataSourceID != 0 {
+		query = query.Where("data_source_id = ?", condition.DataSourceID)
+	}
+	if condition.Question != "" {
+		query = query.Where("question LIKE ?", "%"+condition.Question+"%")
+	}
+	return query
+}
+
+func (s *sqlExampleService) ListOrder() string {
+	return "created_at DESC, id DESC"
+}
+
+// Create 检查SQL后创建，应用ID取自数据源；SQL检查不通过时返回 *sqlguard.Violation
+func (s *sqlExampleService) Create(ctx context.Context, record *model.SqlExample) error {
+	dataSource, err := s.getDataSource(record.DataSourceID)
+	if err != nil {
+		return err
+	}
+	record.ApplicationID = dataSource.ApplicationID
+	if err := validateSqlExample(dataSource, record); err != nil {
+		return err
+	}
+	return s.BaseServiceImpl.Create(ctx, record)
+}
+
+// Update 检查修改后的SQL，所属数据源及来源审计记录不可修改
+func (s *sqlExampleService) Update(ctx context.Context, record *model.SqlExample) error {
+	if record.ID == 0 {
+		return constant.ErrRecordIDEmpty
+	}
+	existing, err := s.Get(ctx, record.ID)
+	if err != nil {
+		return err
+	}
+	dataSource, err := s.getDataSource(existing.DataSourceID)
+	if err != nil {
+		return err
+	}
+	record.ApplicationID = existing.ApplicationID
+	record.DataSourceID = existing.DataSourceID
+	record.AuditID = existing.AuditID
+	if record.Question == "" {
+		record.Question = existing.Question
+	}
+	if record.Sql == "" {
+		record.Sql = existing.Sql
+	}
+	if err := validateSqlExample(dataSource, record); err != nil {
+		return err
+	}
+	return s.BaseServiceImpl.Update(ctx, record)
+}
+
+// Promote 将执行成功的SQL审计记录转为示例，问题为空时使用审计记录中的问题
+func (s *sqlExampleService) Promote(ctx context.Context, auditID uint64, question string) (*model.SqlExample, error) {
+	var audit model.SqlAudit
+	if err := s.db.First(&audit, "id = ?", auditID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, constant.ErrRecordNotFound
+		}
+		logger.Error("查询SQL审计记录失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	// 被拒绝或执行失败的SQL不能作为示例
+	if audit.Error != "" {
+		return nil, constant.ErrInvalidOperation
+	}
+	if question = strings.TrimSpace(question); question == "" {
+		question = audit.Question
+	}
+	record := &model.SqlExample{
+		DataSourceID: audit.DataSourceID,
+		Question:     question,
+		Sql:          audit.Sql,
+		AuditID:      audit.ID,
+	}
+	if err := s.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Search 按BM25检索与问题最相似的示例，只返回有字面匹配的，topN 不大于0时使用 sql_example.search_top_n
+func (s *sqlExampleService) Search(ctx context.Context, dataSourceID uint64, question string, topN int) ([]*SqlExampleMatch, error) {
+	if topN <= 0 {
+		topN = config.GetInt("sql_example.search_top_n")
+	}
+	query := search.Tokenize(question)
+	if len(query) == 0 || topN <= 0 {
+		return []*SqlExampleMatch{}, nil
+	}
+
+	var list []*model.SqlExample
+	if err := s.db.Select("id", "question", "sql_text", "note").
+		Where("data_source_id = ?", dataSourceID).Order("id").Find(&list).Error; err != nil {
+		logger.Error("查询SQL示例失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	docs := make([][]string, len(list))
+	for i, e := range list {
+		docs[i] = search.Tokenize(e.Question)
+	}
+	scores := search.NewIndex(docs).Score(query)
+
+	ranked := make([]int, 0, len(list))
+	for i, score := range scores {
+		if score > 0 {
+			ranked = append(ranked, i)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i]] > scores[ranked[j]]
+	})
+	if len(ranked) > topN {
+		ranked = ranked[:topN]
+	}
+
+	matches := make([]*SqlExampleMatch, 0, len(ranked))
+	for _, i := range ranked {
+		matches = append(matches, &SqlExampleMatch{
+			Question: list[i].Question,
+			Sql:      list[i].Sql,
+			Note:     list[i].Note,
+			Score:    scores[i],
+		})
+	}
+	return matches, nil
+}
+
+func (s *sqlExampleService) getDataSource(id uint64) (*model.DataSource, error) {
+	var dataSource model.DataSource
+	if err := s.db.First(&dataSource, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, constant.ErrRecordNotFound
+		}
+		logger.Error("查询数据源失败", logger.F("error", err))
+		return nil, constant.ErrDatabaseError
+	}
+	return &dataSource, nil
+}
+
+// validateSqlExample 问题不能为空，SQL需通过只读检查
+func validateSqlExample(dataSource *model.DataSource, example *model.SqlExample) error {
+	example.Question = strings.TrimSpace(example.Question)
+	example.Sql = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(example.Sql), ";"))
+	if example.Question == "" || example.Sql == "" || len([]rune(example.Question)) > 500 {
+		return constant.ErrInvalidParams
+	}
+	if _, err := sqlguard.Analyze(example.Sql, sqlguard.DialectOf(dataSource.Type)); err != nil {
+		return err
+	}
+	return nil
+}
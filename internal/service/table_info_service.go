@@ -43,6 +43,9 @@ func (s *tableInfoService) BuildCondition(query *gorm.DB, record *model.TableInf
 	if record.Comment != "" {
 		query = query.Where("comment like ?", "%"+record.Comment+"%")
 	}
+	if record.ExposedToAI != 0 {
+		query = query.Where("exposed_to_ai = ?", record.ExposedToAI)
+	}
 	return query
 }
 
@@ -74,10 +77,22 @@ func (s *tableInfoService) Delete(ctx context.Context, id uint64) error {
 	return nil
 }
 
+// ListSchemaForDify 查询开放给AI的表（白名单），RowFilter 仅用于判断，不应输出给AI
 func (s *tableInfoService) ListSchemaForDify(ctx context.Context, condition *model.TableInfo) ([]*model.TableInfo, error) {
 	var list []*model.TableInfo
-	if err := s.db.Select("ID", "Name", "Comment").
-		Where(condition).Find(&list).Error; err != nil {
+	if err := s.db.Select("ID", "Name", "Comment", "Kind", "Indexes", "BusinessName", "Description", "Synonyms", "ExampleQuestions", "RowFilter").
+		Where(condition).Where("exposed_to_ai = ?", 1).Find(&list).Error; err != nil {
+		logger.Error("查询表信息失败", logger.F("err", err))
+		return nil, constant.ErrDatabaseError
+	}
+	return list, nil
+}
+
+// ListQueryable 查询开放给AI的表及其行级过滤条件，用于执行SQL前的检查
+func (s *tableInfoService) ListQueryable(ctx context.Context, dataSourceID uint64) ([]*model.TableInfo, error) {
+	var list []*model.TableInfo
+	if err := s.db.Select("ID", "Name", "RowFilter").
+		Where("data_source_id = ? AND exposed_to_ai = ?", dataSourceID, 1).Find(&list).Error; err != nil {
 		logger.Error("查询表信息失败", logger.F("err", err))
 		return nil, constant.ErrDatabaseError
 	}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/tidwall/gjson"
+	"github.com/yockii/dify_tools/internal/constant"
+	"github.com/yockii/dify_tools/internal/dify"
+	"github.com/yockii/dify_tools/internal/model"
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"github.com/yockii/dify_tools/pkg/sqlguard"
+)
+
+var (
+	// 回答中的SQL代码块
+	sqlFencePattern = regexp.MustCompile("(?s)```(?:sql|SQL)?\\s*(.*?)```")
+	// 推理模型输出的思考过程
+	thinkPattern = regexp.MustCompile(`(?s)<think>.*?</think>`)
+	// 数据库错误中引号括起的内容，可能是列值
+	quotedPattern = regexp.MustCompile(`'(?:[^']|'')*'|"(?:[^"]|"")*"`)
+)
+
+type textToSqlService struct {
+	dictService    DictService
+	agentService   AgentService
+	schemaService  SchemaService
+	queryService   QueryService
+	usageService   UsageService
+	exampleService SqlExampleService
+}
+
+func NewTextToSqlService(
+	dictService DictService,
+	agentService AgentService,
+	schemaService SchemaService,
+	queryService QueryService,
+	usageService UsageService,
+	exampleService SqlExampleService,
+) *textToSqlService {
+	return &textToSqlService{
+		dictService:    dictService,
+		agentService:   agentService,
+		schemaService:  schemaService,
+		queryService:   queryService,
+		usageService:   usageService,
+		exampleService: exampleService,
+	}
+}
+
+// Ask 检索与问题相关的表结构及相似问题的SQL示例，调用SQL构建器生成SQL并执行。SQL检查不通过或执行出错时带上原因让SQL构建器重新生成，
+// 最多重试 text_to_sql.max_retries 次，仍不通过时返回 ErrSqlNotAllowed（执行出错时为 ErrDatabaseError），结果中带有最后一次的SQL及原因
+func (s *textToSqlService) Ask(ctx context.Context, dataSource *model.DataSource, req *TextToSqlRequest) (*TextToSqlResult, error) {
+	agent, err := s.agentService.GetByCode(ctx, model.InnerAgentCodeSqlBuilder)
+	if err != nil {
+		if errors.Is(err, constant.ErrRecordNotFound) {
+			return nil, constant.ErrSqlBuilderNotReady
+		}
+		return nil, err
+	}
+	if agent.ApiSecret == "" {
+		logger.Warn("SQL构建器未配置密钥", logger.F("agentId", agent.ID))
+		return nil, constant.ErrSqlBuilderNotReady
+	}
+	client, err := s.chatClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := s.schemaService.Search(ctx, dataSource.ID, req.Question,
+		config.GetInt("text_to_sql.schema_top_n"), config.GetInt("schema.search_max_columns"))
+	if err != nil {
+		return nil, err
+	}
+	// 问题与表结构没有字面匹配时提供全部表，由token预算裁剪
+	if len(tables) == 0 {
+		if tables, err = s.schemaService.ListTables(ctx, dataSource.ID); err != nil {
+			return nil, err
+		}
+	}
+	rendered, err := s.schemaService.Render(tables, SchemaFormatDDL, config.GetInt("text_to_sql.schema_max_tokens"))
+	if err != nil {
+		return nil, err
+	}
+	schema, _ := rendered.(string)
+
+	// 相似问题的已确认SQL作为少样本示例
+	examples, err := s.exampleService.Search(ctx, dataSource.ID, req.Question, 0)
+	if err != nil {
+		return nil, err
+	}
+	var exampleText strings.Builder
+	for _, e := range examples {
+		fmt.Fprintf(&exampleText, "问题：%s\nSQL：%s\n", e.Question, e.Sql)
+		if e.Note != "" {
+			fmt.Fprintf(&exampleText, "说明：%s\n", e.Note)
+		}
+		exampleText.WriteString("\n")
+	}
+
+	user := req.Variables["custom_id"]
+	if user == "" {
+		user = "dify_tools"
+	}
+	inputs := map[string]interface{}{
+		"question": req.Question,
+		"schema":   schema,
+		"dialect":  string(sqlguard.DialectOf(dataSource.Type)),
+		"examples": strings.TrimSpace(exampleText.String()),
+		"feedback": "",
+	}
+
+	result := new(TextToSqlResult)
+	maxRetries := config.GetInt("text_to_sql.max_retries")
+	for {
+		result.Attempts++
+		sql, err := s.generate(ctx, client, agent, dataSource.ApplicationID, user, req.Question, inputs)
+		if err != nil {
+			return result, err
+		}
+		result.Sql = sql
+
+		query := &QueryRequest{
+			Sql:       sql,
+			Variables: req.Variables,
+			Source:    model.SqlAuditSourceTextToSql,
+			Question:  req.Question,
+		}
+		queryResult, err := s.queryService.ExecuteSql(ctx, dataSource, query)
+		if err == nil {
+			result.QueryResult = queryResult
+			result.Violation = nil
+			return result, nil
+		}
+		var violation *sqlguard.Violation
+		switch {
+		case errors.As(err, &violation):
+			result.Violation = violation
+			if result.Attempts > maxRetries {
+				return result, constant.ErrSqlNotAllowed
+			}
+			inputs["feedback"] = fmt.Sprintf("上次生成的SQL未通过检查，请修正后重新生成。\nSQL：%s\n原因：%s", sql, violation.Error())
+		case query.dbError != nil:
+			// 列名错误、类型不匹配等数据库错误同样反馈给SQL构建器重新生成
+			result.Violation = nil
+			if result.Attempts > maxRetries {
+				return result, err
+			}
+			inputs["feedback"] = fmt.Sprintf("上次生成的SQL执行出错，请根据数据库返回的错误修正后重新生成。\nSQL：%s\n错误：%s", sql, truncateRunes(sanitizeDbError(query.dbError, sql), 500))
+		default:
+			return result, err
+		}
+	}
+}
+
+// sanitizeDbError 反馈给SQL构建器的数据库错误：错误码加去掉引号内容的错误信息。
+// 类型转换失败等错误会带出列值（可能是脱敏列），引号内容未出现在SQL中时替换为 '?'
+func sanitizeDbError(err error, sql string) string {
+	var code, message string
+	var pgErr *pgconn.PgError
+	var mysqlErr *mysql.MySQLError
+	switch {
+	case errors.As(err, &pgErr):
+		code, message = "SQLSTATE "+pgErr.Code, pgErr.Message
+	case errors.As(err, &mysqlErr):
+		code, message = fmt.Sprintf("MySQL %d", mysqlErr.Number), mysqlErr.Message
+	default:
+		message = err.Error()
+	}
+
+	lowerSql := strings.ToLower(sql)
+	message = quotedPattern.ReplaceAllStringFunc(message, func(quoted string) string {
+		if inner := quoted[1 : len(quoted)-1]; inner != "" && strings.Contains(lowerSql, strings.ToLower(inner)) {
+			return quoted
+		}
+		return quoted[:1] + "?" + quoted[:1]
+	})
+	if code == "" {
+		return message
+	}
+	return code + ": " + message
+}
+
+// generate 以阻塞模式调用SQL构建器，从回答中提取SQL，并记录token用量
+func (s *textToSqlService) generate(ctx context.Context, client *dify.ChatClient, agent *model.Agent, applicationID uint64, user, question string, inputs map[string]interface{}) (string, error) {
+	var answer string
+	usage := &model.Usage{
+		ApplicationID: applicationID,
+		AgentID:       agent.ID,
+		Date:          time.Now().Format("2006-01-02"),
+	}
+	switch agent.Type {
+	case model.AgentTypeWorkflow:
+		body, err := client.RunWorkflow(&dify.WorkflowRunRequest{
+			Inputs: inputs,
+			User:   user,
+		}, string(agent.ApiSecret))
+		if err != nil {
+			logger.Error("调用SQL构建器失败", logger.F("err", err))
+			return "", constant.ErrSqlBuilderFailed
+		}
+		j := gjson.ParseBytes(body)
+		if status := j.Get("data.status").String(); status != "succeeded" {
+			logger.Error("SQL构建器执行失败", logger.F("status", status), logger.F("error", j.Get("data.error").String()))
+			return "", constant.ErrSqlBuilderFailed
+		}
+		// 优先取名为sql的输出，否则取第一个文本输出
+		answer = j.Get("data.outputs.sql").String()
+		if answer == "" {
+			j.Get("data.outputs").ForEach(func(_, v gjson.Result) bool {
+				if v.Type == gjson.String {
+					answer = v.String()
+					return false
+				}
+				return true
+			})
+		}
+		usage.TotalTokens = int(j.Get("data.total_tokens").Int())
+	default:
+		body, err := client.SendChatMessage(&dify.ChatMessageRequest{
+			Query:        question,
+			Inputs:       inputs,
+			ResponseMode: "blocking",
+			User:         user,
+		}, string(agent.ApiSecret), nil)
+		if err != nil {
+			logger.Error("调用SQL构建器失败", logger.F("err", err))
+			return "", constant.ErrSqlBuilderFailed
+		}
+		j := gjson.ParseBytes(body)
+		answer = j.Get("answer").String()
+		usage.PromptTokens = int(j.Get("metadata.usage.prompt_tokens").Int())
+		usage.CompletionTokens = int(j.Get("metadata.usage.completion_tokens").Int())
+		usage.TotalTokens = int(j.Get("metadata.usage.total_tokens").Int())
+	}
+	if usage.TotalTokens > 0 {
+		if err := s.usageService.Create(ctx, usage); err != nil {
+			logger.Error("创建使用记录失败", logger.F("error", err))
+		}
+	}
+	return extractSql(answer), nil
+}
+
+// extractSql 从回答中提取SQL，去掉思考过程、代码块标记及末尾分号
+func extractSql(answer string) string {
+	answer = thinkPattern.ReplaceAllString(answer, "")
+	if m := sqlFencePattern.FindStringSubmatch(answer); m != nil {
+		answer = m[1]
+	}
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(answer), ";"))
+}
+
+func (s *textToSqlService) chatClient(ctx context.Context) (*dify.ChatClient, error) {
+	chatClient := dify.GetDefaultChatClient()
+	if chatClient == nil {
+		difyBaseUrlDict, err := s.dictService.GetByCode(ctx, constant.DictCodeDifyBaseUrl)
+		if err != nil {
+			logger.Error("获取字典值失败", logger.F("err", err))
+			return nil, err
+		}
+		if difyBaseUrlDict == nil || difyBaseUrlDict.Value == "" {
+			logger.Warn("未配置dify接口地址")
+			return nil, constant.ErrDictNotConfigured
+		}
+		difyTokenDict, err := s.dictService.GetByCode(ctx, constant.DictCodeDifyToken)
+		if err != nil {
+			logger.Error("获取字典值失败", logger.F("err", err))
+			return nil, err
+		}
+		difyToken := ""
+		if difyTokenDict != nil {
+			difyToken = difyTokenDict.Value
+		}
+		chatClient = dify.InitDefaultChatClient(difyBaseUrlDict.Value, difyToken)
+	}
+	return chatClient, nil
+}
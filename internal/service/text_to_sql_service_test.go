@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestSanitizeDbError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		sql  string
+		want string
+	}{
+		{
+			"postgres cast echoes value",
+			&pgconn.PgError{Code: "22P02", Message: `invalid input syntax for type integer: "13812345678"`},
+			"SELECT CAST(phone AS int) FROM users",
+			`SQLSTATE 22P02: invalid input syntax for type integer: "?"`,
+		},
+		{
+			"postgres unknown column kept",
+			&pgconn.PgError{Code: "42703", Message: `column "phone1" does not exist`},
+			"SELECT phone1 FROM users",
+			`SQLSTATE 42703: column "phone1" does not exist`,
+		},
+		{
+			"wrapped postgres error",
+			fmt.Errorf("query failed: %w", &pgconn.PgError{Code: "22P02", Message: `invalid input syntax for type uuid: "abc"`}),
+			"SELECT * FROM users WHERE id::uuid = uid",
+			`SQLSTATE 22P02: invalid input syntax for type uuid: "?"`,
+		},
+		{
+			"mysql truncated value",
+			&mysql.MySQLError{Number: 1292, Message: "Truncated incorrect DOUBLE value: 'zhang@example.com'"},
+			"SELECT * FROM users WHERE email = 0",
+			"MySQL 1292: Truncated incorrect DOUBLE value: '?'",
+		},
+		{
+			"mysql unknown column kept",
+			&mysql.MySQLError{Number: 1054, Message: "Unknown column 'Phone1' in 'field list'"},
+			"SELECT phone1 FROM users",
+			"MySQL 1054: Unknown column 'Phone1' in '?'",
+		},
+		{
+			"literal from the sql kept",
+			&mysql.MySQLError{Number: 1525, Message: "Incorrect DATE value: '2024-13-01'"},
+			"SELECT * FROM orders WHERE created_at > DATE '2024-13-01'",
+			"MySQL 1525: Incorrect DATE value: '2024-13-01'",
+		},
+		{
+			"escaped quote",
+			errors.New(`bad value 'it''s secret' and ""`),
+			"SELECT 1",
+			`bad value '?' and "?"`,
+		},
+		{
+			"other driver",
+			errors.New("SQL logic error: no such column: phone1 (1)"),
+			"SELECT phone1 FROM users",
+			"SQL logic error: no such column: phone1 (1)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeDbError(tt.err, tt.sql); got != tt.want {
+				t.Fatalf("sanitizeDbError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
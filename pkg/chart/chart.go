@@ -0,0 +1,360 @@
+package chart
+
+import (
+	"errors"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 图表类型
+const (
+	TypeBar  = "bar"
+	TypeLine = "line"
+	TypePie  = "pie"
+)
+
+var (
+	ErrUnsupportedType = errors.New("不支持的图表类型")
+	ErrNoData          = errors.New("没有可绘制的数据")
+	ErrSeriesLength    = errors.New("系列的数据个数与分类个数不一致")
+)
+
+// 默认画布大小
+const (
+	DefaultWidth  = 800
+	DefaultHeight = 480
+)
+
+// Series 一个数据系列
+type Series struct {
+	Name   string
+	Values []float64
+}
+
+// Chart 图表定义，柱状图、折线图的每个系列与 Labels 一一对应，饼图只使用第一个系列
+type Chart struct {
+	Type   string
+	Title  string
+	Labels []string // x轴分类，饼图为扇区名称
+	Series []Series
+	Width  int
+	Height int
+}
+
+// Validate 检查图表类型及数据
+func (c *Chart) Validate() error {
+	switch c.Type {
+	case TypeBar, TypeLine, TypePie:
+	default:
+		return ErrUnsupportedType
+	}
+	if len(c.Labels) == 0 || len(c.Series) == 0 {
+		return ErrNoData
+	}
+	for _, s := range c.Series {
+		if len(s.Values) != len(c.Labels) {
+			return ErrSeriesLength
+		}
+	}
+	if c.Type == TypePie {
+		total := 0.0
+		for _, v := range c.Series[0].Values {
+			if v > 0 {
+				total += v
+			}
+		}
+		if total == 0 {
+			return ErrNoData
+		}
+	}
+	return nil
+}
+
+func (c *Chart) size() (float64, float64) {
+	w, h := c.Width, c.Height
+	if w <= 0 {
+		w = DefaultWidth
+	}
+	if h <= 0 {
+		h = DefaultHeight
+	}
+	return float64(w), float64(h)
+}
+
+// 系列配色
+var palette = []color.RGBA{
+	{0x54, 0x70, 0xc6, 0xff},
+	{0x91, 0xcc, 0x75, 0xff},
+	{0xfa, 0xc8, 0x58, 0xff},
+	{0xee, 0x66, 0x66, 0xff},
+	{0x73, 0xc0, 0xde, 0xff},
+	{0x3b, 0xa2, 0x72, 0xff},
+	{0xfc, 0x84, 0x52, 0xff},
+	{0x9a, 0x60, 0xb4, 0xff},
+	{0xea, 0x7c, 0xcc, 0xff},
+}
+
+var (
+	colorBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	colorText       = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	colorAxis       = color.RGBA{0x99, 0x99, 0x99, 0xff}
+	colorGrid       = color.RGBA{0xe6, 0xe6, 0xe6, 0xff}
+)
+
+func seriesColor(i int) color.RGBA {
+	return palette[i%len(palette)]
+}
+
+// 文字对齐方式
+const (
+	anchorStart = iota
+	anchorMiddle
+	anchorEnd
+)
+
+type point struct {
+	x, y float64
+}
+
+// canvas SVG及PNG共用的绘图接口，坐标原点在左上角
+type canvas interface {
+	rect(x, y, w, h float64, fill color.RGBA)
+	polyline(points []point, width float64, stroke color.RGBA)
+	circle(cx, cy, r float64, fill color.RGBA)
+	// wedge 扇形，角度为弧度，从12点方向顺时针计算
+	wedge(cx, cy, r, start, end float64, fill color.RGBA)
+	// text y 为基线位置
+	text(x, y float64, s string, size float64, anchor int, fill color.RGBA)
+	measure(s string, size float64) float64
+}
+
+const (
+	titleSize = 16
+	labelSize = 12
+	padding   = 16
+)
+
+// layout 计算布局并绘制到画布上
+func layout(c *Chart, cv canvas) {
+	w, h := c.size()
+	cv.rect(0, 0, w, h, colorBackground)
+
+	top := float64(padding)
+	if c.Title != "" {
+		top += titleSize
+		cv.text(w/2, top, c.Title, titleSize, anchorMiddle, colorText)
+		top += padding / 2
+	}
+
+	if c.Type == TypePie {
+		drawPie(c, cv, padding, top, w-padding, h-padding)
+		return
+	}
+
+	// 多个系列时在标题下方显示图例
+	if len(c.Series) > 1 {
+		top += labelSize
+		drawLegend(c, cv, w/2, top)
+		top += padding / 2
+	}
+	drawAxes(c, cv, padding, top+labelSize/2, w-padding, h-padding)
+}
+
+// drawLegend 居中绘制一行图例
+func drawLegend(c *Chart, cv canvas, center, baseline float64) {
+	const box, gap = 10.0, 16.0
+	total := 0.0
+	for _, s := range c.Series {
+		total += box + 4 + cv.measure(s.Name, labelSize) + gap
+	}
+	x := center - (total-gap)/2
+	for i, s := range c.Series {
+		cv.rect(x, baseline-box, box, box, seriesColor(i))
+		x += box + 4
+		cv.text(x, baseline, s.Name, labelSize, anchorStart, colorText)
+		x += cv.measure(s.Name, labelSize) + gap
+	}
+}
+
+// drawAxes 绘制柱状图或折线图，(left, top)-(right, bottom) 为可用区域
+func drawAxes(c *Chart, cv canvas, left, top, right, bottom float64) {
+	lo, hi := 0.0, 0.0
+	for _, s := range c.Series {
+		for _, v := range s.Values {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	ticks := niceTicks(lo, hi, 5)
+	lo, hi = ticks[0], ticks[len(ticks)-1]
+
+	// 左侧留出刻度文字宽度，底部留出分类文字高度
+	labelWidth := 0.0
+	for _, t := range ticks {
+		labelWidth = math.Max(labelWidth, cv.measure(formatNumber(t), labelSize))
+	}
+	plotLeft := left + labelWidth + 8
+	plotBottom := bottom - labelSize - 8
+	plotW, plotH := right-plotLeft, plotBottom-top
+	y := func(v float64) float64 {
+		return plotBottom - (v-lo)/(hi-lo)*plotH
+	}
+
+	for _, t := range ticks {
+		ty := y(t)
+		cv.polyline([]point{{plotLeft, ty}, {right, ty}}, 1, colorGrid)
+		cv.text(plotLeft-8, ty+labelSize/3, formatNumber(t), labelSize, anchorEnd, colorText)
+	}
+	zero := y(math.Max(lo, 0))
+	cv.polyline([]point{{plotLeft, zero}, {right, zero}}, 1, colorAxis)
+
+	n := len(c.Labels)
+	group := plotW / float64(n)
+	// 分类过多时间隔显示，避免文字重叠
+	step := 1
+	maxLabel := 0.0
+	for _, l := range c.Labels {
+		maxLabel = math.Max(maxLabel, cv.measure(l, labelSize))
+	}
+	if maxLabel+8 > group {
+		step = int(math.Ceil((maxLabel + 8) / group))
+	}
+	for i, l := range c.Labels {
+		if i%step != 0 {
+			continue
+		}
+		cv.text(plotLeft+group*(float64(i)+0.5), plotBottom+labelSize+6, l, labelSize, anchorMiddle, colorText)
+	}
+
+	switch c.Type {
+	case TypeBar:
+		barW := group * 0.7 / float64(len(c.Series))
+		for si, s := range c.Series {
+			for i, v := range s.Values {
+				x := plotLeft + group*float64(i) + group*0.15 + barW*float64(si)
+				y0, y1 := math.Min(y(v), zero), math.Max(y(v), zero)
+				cv.rect(x, y0, barW, y1-y0, seriesColor(si))
+			}
+		}
+	case TypeLine:
+		for si, s := range c.Series {
+			points := make([]point, len(s.Values))
+			for i, v := range s.Values {
+				points[i] = point{plotLeft + group*(float64(i)+0.5), y(v)}
+			}
+			cv.polyline(points, 2, seriesColor(si))
+			// 点较少时标出数据点
+			if n <= 50 {
+				for _, p := range points {
+					cv.circle(p.x, p.y, 3, seriesColor(si))
+				}
+			}
+		}
+	}
+}
+
+// drawPie 绘制饼图，右侧为带百分比的图例，负值及0不绘制
+func drawPie(c *Chart, cv canvas, left, top, right, bottom float64) {
+	values := c.Series[0].Values
+	total := 0.0
+	for _, v := range values {
+		if v > 0 {
+			total += v
+		}
+	}
+
+	legends := make([]string, len(c.Labels))
+	legendWidth := 0.0
+	for i, l := range c.Labels {
+		pct := 0.0
+		if values[i] > 0 {
+			pct = values[i] / total * 100
+		}
+		legends[i] = l + " " + strconv.FormatFloat(pct, 'f', 1, 64) + "%"
+		legendWidth = math.Max(legendWidth, cv.measure(legends[i], labelSize))
+	}
+	legendWidth += 14 + padding
+
+	// 饼图与图例整体水平居中
+	r := math.Min(right-left-legendWidth, bottom-top) / 2
+	cx, cy := left+(right-left-2*r-legendWidth)/2+r, top+(bottom-top)/2
+	angle := 0.0
+	for i, v := range values {
+		if v <= 0 {
+			continue
+		}
+		end := angle + v/total*2*math.Pi
+		cv.wedge(cx, cy, r, angle, end, seriesColor(i))
+		angle = end
+	}
+
+	lineHeight := float64(labelSize + 6)
+	x := cx + r + padding
+	y := cy - lineHeight*float64(len(legends))/2 + labelSize
+	for i, l := range legends {
+		cv.rect(x, y-10, 10, 10, seriesColor(i))
+		cv.text(x+14, y, l, labelSize, anchorStart, colorText)
+		y += lineHeight
+	}
+}
+
+// niceTicks 生成包含 [lo, hi] 的整齐刻度
+func niceTicks(lo, hi float64, count int) []float64 {
+	if hi == lo {
+		if hi == 0 {
+			hi = 1
+		} else if hi > 0 {
+			lo = 0
+		} else {
+			hi = 0
+		}
+	}
+	step := niceNumber((hi - lo) / float64(count))
+	start := math.Floor(lo/step) * step
+	end := math.Ceil(hi/step) * step
+	var ticks []float64
+	for v := start; v <= end+step/2; v += step {
+		// 消除浮点误差
+		ticks = append(ticks, math.Round(v/step)*step)
+	}
+	return ticks
+}
+
+func niceNumber(x float64) float64 {
+	exp := math.Floor(math.Log10(x))
+	f := x / math.Pow(10, exp)
+	var nice float64
+	switch {
+	case f <= 1:
+		nice = 1
+	case f <= 2:
+		nice = 2
+	case f <= 5:
+		nice = 5
+	default:
+		nice = 10
+	}
+	return nice * math.Pow(10, exp)
+}
+
+// formatNumber 刻度文字，大数使用K、M、B缩写
+func formatNumber(v float64) string {
+	abs := math.Abs(v)
+	for _, u := range []struct {
+		div    float64
+		suffix string
+	}{{1e9, "B"}, {1e6, "M"}, {1e3, "K"}} {
+		if abs >= u.div {
+			return trimZeros(strconv.FormatFloat(v/u.div, 'f', 2, 64)) + u.suffix
+		}
+	}
+	return trimZeros(strconv.FormatFloat(v, 'f', 2, 64))
+}
+
+func trimZeros(s string) string {
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
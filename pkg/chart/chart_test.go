@@ -0,0 +1,165 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/yockii/dify_tools/pkg/config"
+)
+
+func TestMain(m *testing.M) {
+	// 不配置 chart.font_file，PNG使用内置点阵字体
+	_ = config.Init(os.DevNull)
+	os.Exit(m.Run())
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		chart Chart
+		want  error
+	}{
+		{"bar", Chart{Type: TypeBar, Labels: []string{"a", "b"}, Series: []Series{{Values: []float64{1, 2}}}}, nil},
+		{"unknown type", Chart{Type: "radar", Labels: []string{"a"}, Series: []Series{{Values: []float64{1}}}}, ErrUnsupportedType},
+		{"no labels", Chart{Type: TypeLine, Series: []Series{{}}}, ErrNoData},
+		{"no series", Chart{Type: TypeLine, Labels: []string{"a"}}, ErrNoData},
+		{"length mismatch", Chart{Type: TypeBar, Labels: []string{"a", "b"}, Series: []Series{{Values: []float64{1}}}}, ErrSeriesLength},
+		{"pie without positive", Chart{Type: TypePie, Labels: []string{"a", "b"}, Series: []Series{{Values: []float64{0, -1}}}}, ErrNoData},
+		{"pie", Chart{Type: TypePie, Labels: []string{"a", "b"}, Series: []Series{{Values: []float64{0, 3}}}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.chart.Validate(); err != tt.want {
+				t.Fatalf("Validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNiceTicks(t *testing.T) {
+	tests := []struct {
+		lo, hi float64
+		want   []float64
+	}{
+		{0, 97, []float64{0, 20, 40, 60, 80, 100}},
+		{0, 1, []float64{0, 0.2, 0.4, 0.6, 0.8, 1}},
+		{-30, 45, []float64{-40, -20, 0, 20, 40, 60}},
+		{0, 0, []float64{0, 0.2, 0.4, 0.6, 0.8, 1}},
+		{5, 5, []float64{0, 1, 2, 3, 4, 5}},
+		{-5, -5, []float64{-5, -4, -3, -2, -1, 0}},
+	}
+	for _, tt := range tests {
+		got := niceTicks(tt.lo, tt.hi, 5)
+		if len(got) != len(tt.want) {
+			t.Fatalf("niceTicks(%v, %v) = %v, want %v", tt.lo, tt.hi, got, tt.want)
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+				t.Fatalf("niceTicks(%v, %v) = %v, want %v", tt.lo, tt.hi, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0, "0"},
+		{12.5, "12.5"},
+		{0.333, "0.33"},
+		{1500, "1.5K"},
+		{-2000000, "-2M"},
+		{3250000000, "3.25B"},
+	}
+	for _, tt := range tests {
+		if got := formatNumber(tt.v); got != tt.want {
+			t.Fatalf("formatNumber(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	tests := []struct {
+		name  string
+		chart Chart
+		want  string
+	}{
+		{
+			"bar with two series",
+			Chart{Type: TypeBar, Title: "月度\"销售\"", Labels: []string{"1月", "2月"}, Series: []Series{{Name: "收入", Values: []float64{1.5, 2}}, {Name: "成本", Values: []float64{1, 1}}}},
+			"xychart-beta\n    title \"月度'销售'\"\n    x-axis [\"1月\", \"2月\"]\n    y-axis \"收入 / 成本\"\n    bar [1.5, 2]\n    bar [1, 1]\n",
+		},
+		{
+			"line",
+			Chart{Type: TypeLine, Labels: []string{"a\nb"}, Series: []Series{{Name: "n", Values: []float64{3}}}},
+			"xychart-beta\n    x-axis [\"a b\"]\n    y-axis \"n\"\n    line [3]\n",
+		},
+		{
+			"pie skips non-positive",
+			Chart{Type: TypePie, Title: "占比", Labels: []string{"a", "b", "c"}, Series: []Series{{Values: []float64{2, 0, 1}}}},
+			"pie title 占比\n    \"a\" : 2\n    \"c\" : 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Mermaid(&tt.chart)
+			if err != nil || got != tt.want {
+				t.Fatalf("Mermaid() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+	if _, err := Mermaid(&Chart{Type: "radar"}); err != ErrUnsupportedType {
+		t.Fatalf("Mermaid(radar) err = %v", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	charts := []Chart{
+		{Type: TypeBar, Title: "<b>&", Labels: []string{"a", "b", "c"}, Series: []Series{{Name: "x", Values: []float64{1, -2, 3}}, {Name: "y", Values: []float64{2, 2, 2}}}},
+		{Type: TypeLine, Labels: []string{"a", "b"}, Series: []Series{{Name: "x", Values: []float64{5, 5}}}, Width: 300, Height: 200},
+		{Type: TypePie, Labels: []string{"a", "b"}, Series: []Series{{Values: []float64{1, 3}}}},
+	}
+	for _, c := range charts {
+		t.Run(c.Type, func(t *testing.T) {
+			var svg bytes.Buffer
+			if err := RenderSVG(&c, &svg); err != nil {
+				t.Fatal(err)
+			}
+			// SVG需为合法的XML，标题等文字已转义
+			dec := xml.NewDecoder(&svg)
+			for {
+				if _, err := dec.Token(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("invalid SVG: %v", err)
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := RenderPNG(&c, &buf); err != nil {
+				t.Fatal(err)
+			}
+			img, err := png.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, h := c.size()
+			if b := img.Bounds(); b.Dx() != int(w) || b.Dy() != int(h) {
+				t.Fatalf("PNG size = %v, want %vx%v", b, w, h)
+			}
+		})
+	}
+
+	var svg strings.Builder
+	if err := RenderSVG(&Chart{Type: TypeBar}, &svg); err != ErrNoData || svg.Len() != 0 {
+		t.Fatalf("RenderSVG(invalid) = %v, wrote %d bytes", err, svg.Len())
+	}
+}
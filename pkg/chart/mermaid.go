@@ -0,0 +1,66 @@
+package chart
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Mermaid 将图表转换为Mermaid代码（不含```包裹），柱状图、折线图使用 xychart-beta，饼图使用 pie。
+// xychart 没有图例，多个系列时系列名称合并显示在y轴标题中
+func Mermaid(c *Chart) (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if c.Type == TypePie {
+		b.WriteString("pie")
+		if c.Title != "" {
+			b.WriteString(" title " + mermaidText(c.Title))
+		}
+		b.WriteString("\n")
+		for i, v := range c.Series[0].Values {
+			// 饼图只能包含正数
+			if v <= 0 {
+				continue
+			}
+			b.WriteString("    " + mermaidQuote(c.Labels[i]) + " : " + mermaidNumber(v) + "\n")
+		}
+		return b.String(), nil
+	}
+
+	b.WriteString("xychart-beta\n")
+	if c.Title != "" {
+		b.WriteString("    title " + mermaidQuote(c.Title) + "\n")
+	}
+	labels := make([]string, len(c.Labels))
+	for i, l := range c.Labels {
+		labels[i] = mermaidQuote(l)
+	}
+	b.WriteString("    x-axis [" + strings.Join(labels, ", ") + "]\n")
+	names := make([]string, len(c.Series))
+	for i, s := range c.Series {
+		names[i] = s.Name
+	}
+	b.WriteString("    y-axis " + mermaidQuote(strings.Join(names, " / ")) + "\n")
+	for _, s := range c.Series {
+		values := make([]string, len(s.Values))
+		for i, v := range s.Values {
+			values[i] = mermaidNumber(v)
+		}
+		b.WriteString("    " + c.Type + " [" + strings.Join(values, ", ") + "]\n")
+	}
+	return b.String(), nil
+}
+
+// mermaidText 去掉换行及双引号，Mermaid文本中不支持转义
+func mermaidText(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ", `"`, "'").Replace(strings.TrimSpace(s))
+}
+
+func mermaidQuote(s string) string {
+	return `"` + mermaidText(s) + `"`
+}
+
+func mermaidNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
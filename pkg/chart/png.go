@@ -0,0 +1,195 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/logger"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// RenderPNG 将图表绘制为PNG。文字使用 chart.font_file 配置的TTF/OTF/TTC字体，
+// 未配置时使用内置的点阵字体，该字体只包含ASCII字符，中文需要配置字体文件才能显示
+func RenderPNG(c *Chart, w io.Writer) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	width, height := c.size()
+	cv := &pngCanvas{
+		img:   image.NewRGBA(image.Rect(0, 0, int(width), int(height))),
+		faces: make(map[float64]font.Face),
+	}
+	layout(c, cv)
+	return png.Encode(w, cv.img)
+}
+
+type pngCanvas struct {
+	img *image.RGBA
+	// 字体不能并发使用，每次绘制单独创建
+	faces map[float64]font.Face
+}
+
+// fill 用非零环绕规则填充由 build 描述的路径
+func (p *pngCanvas) fill(col color.RGBA, build func(r *vector.Rasterizer)) {
+	b := p.img.Bounds()
+	r := vector.NewRasterizer(b.Dx(), b.Dy())
+	r.DrawOp = draw.Over
+	build(r)
+	r.Draw(p.img, b, image.NewUniform(col), image.Point{})
+}
+
+func (p *pngCanvas) rect(x, y, w, h float64, fill color.RGBA) {
+	if w <= 0 || h <= 0 {
+		return
+	}
+	p.fill(fill, func(r *vector.Rasterizer) {
+		r.MoveTo(float32(x), float32(y))
+		r.LineTo(float32(x+w), float32(y))
+		r.LineTo(float32(x+w), float32(y+h))
+		r.LineTo(float32(x), float32(y+h))
+		r.ClosePath()
+	})
+}
+
+// polyline 每段线条绘制为矩形，折点处补圆使连接处平滑
+func (p *pngCanvas) polyline(points []point, width float64, stroke color.RGBA) {
+	half := width / 2
+	p.fill(stroke, func(r *vector.Rasterizer) {
+		for i := 1; i < len(points); i++ {
+			a, b := points[i-1], points[i]
+			dx, dy := b.x-a.x, b.y-a.y
+			length := math.Hypot(dx, dy)
+			if length == 0 {
+				continue
+			}
+			// 法线方向偏移半个线宽
+			nx, ny := -dy/length*half, dx/length*half
+			r.MoveTo(float32(a.x+nx), float32(a.y+ny))
+			r.LineTo(float32(b.x+nx), float32(b.y+ny))
+			r.LineTo(float32(b.x-nx), float32(b.y-ny))
+			r.LineTo(float32(a.x-nx), float32(a.y-ny))
+			r.ClosePath()
+		}
+		if width > 1 && len(points) > 2 {
+			for _, pt := range points[1 : len(points)-1] {
+				arcPath(r, pt.x, pt.y, half, 0, 2*math.Pi, false)
+			}
+		}
+	})
+}
+
+func (p *pngCanvas) circle(cx, cy, r float64, fill color.RGBA) {
+	p.fill(fill, func(ras *vector.Rasterizer) {
+		arcPath(ras, cx, cy, r, 0, 2*math.Pi, false)
+	})
+}
+
+func (p *pngCanvas) wedge(cx, cy, r, start, end float64, fill color.RGBA) {
+	p.fill(fill, func(ras *vector.Rasterizer) {
+		arcPath(ras, cx, cy, r, start, end, end-start < 2*math.Pi-1e-9)
+	})
+}
+
+// arcPath 用折线近似圆弧，角度从12点方向顺时针计算，withCenter 时从圆心出发形成扇形
+func arcPath(r *vector.Rasterizer, cx, cy, radius, start, end float64, withCenter bool) {
+	steps := int(math.Ceil((end - start) / (math.Pi / 90)))
+	if steps < 1 {
+		steps = 1
+	}
+	at := func(a float64) (float32, float32) {
+		return float32(cx + radius*math.Sin(a)), float32(cy - radius*math.Cos(a))
+	}
+	if withCenter {
+		r.MoveTo(float32(cx), float32(cy))
+		r.LineTo(at(start))
+	} else {
+		r.MoveTo(at(start))
+	}
+	for i := 1; i <= steps; i++ {
+		r.LineTo(at(start + (end-start)*float64(i)/float64(steps)))
+	}
+	r.ClosePath()
+}
+
+func (p *pngCanvas) text(x, y float64, s string, size float64, anchor int, fill color.RGBA) {
+	face := p.face(size)
+	switch anchor {
+	case anchorMiddle:
+		x -= p.measure(s, size) / 2
+	case anchorEnd:
+		x -= p.measure(s, size)
+	}
+	d := &font.Drawer{
+		Dst:  p.img,
+		Src:  image.NewUniform(fill),
+		Face: face,
+		Dot:  fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(y * 64)},
+	}
+	d.DrawString(s)
+}
+
+func (p *pngCanvas) measure(s string, size float64) float64 {
+	return float64(font.MeasureString(p.face(size), s)) / 64
+}
+
+var (
+	fontOnce sync.Once
+	fontData *opentype.Font
+)
+
+// face 按字号返回字体，未配置字体文件时使用内置点阵字体
+func (p *pngCanvas) face(size float64) font.Face {
+	fontOnce.Do(loadFont)
+	if fontData == nil {
+		return basicfont.Face7x13
+	}
+	if face, ok := p.faces[size]; ok {
+		return face
+	}
+	face, err := opentype.NewFace(fontData, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		logger.Error("创建图表字体失败", logger.F("size", size), logger.F("error", err))
+		face = basicfont.Face7x13
+	}
+	p.faces[size] = face
+	return face
+}
+
+// loadFont 加载 chart.font_file，字体集合（.ttc）使用其中第一个字体
+func loadFont() {
+	file := config.GetString("chart.font_file")
+	if file == "" {
+		return
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		logger.Error("读取图表字体文件失败", logger.F("file", file), logger.F("error", err))
+		return
+	}
+	if strings.EqualFold(filepath.Ext(file), ".ttc") {
+		collection, err := opentype.ParseCollection(data)
+		if err == nil {
+			fontData, err = collection.Font(0)
+		}
+		if err != nil {
+			logger.Error("解析图表字体文件失败", logger.F("file", file), logger.F("error", err))
+		}
+		return
+	}
+	if fontData, err = opentype.Parse(data); err != nil {
+		logger.Error("解析图表字体文件失败", logger.F("file", file), logger.F("error", err))
+	}
+}
@@ -0,0 +1,92 @@
+package chart
+
+import (
+	"fmt"
+	"html"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// SVG中文字体，浏览器按顺序选择已安装的字体
+const svgFontFamily = `-apple-system, "Microsoft YaHei", "PingFang SC", "Noto Sans CJK SC", sans-serif`
+
+// RenderSVG 将图表绘制为SVG
+func RenderSVG(c *Chart, w io.Writer) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	width, height := c.size()
+	cv := &svgCanvas{}
+	fmt.Fprintf(&cv.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g" font-family='%s'>`,
+		width, height, width, height, svgFontFamily)
+	cv.b.WriteString("\n")
+	layout(c, cv)
+	cv.b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, cv.b.String())
+	return err
+}
+
+type svgCanvas struct {
+	b strings.Builder
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func (s *svgCanvas) rect(x, y, w, h float64, fill color.RGBA) {
+	fmt.Fprintf(&s.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x, y, w, h, svgColor(fill))
+}
+
+func (s *svgCanvas) polyline(points []point, width float64, stroke color.RGBA) {
+	s.b.WriteString(`<polyline points="`)
+	for i, p := range points {
+		if i > 0 {
+			s.b.WriteByte(' ')
+		}
+		fmt.Fprintf(&s.b, "%.1f,%.1f", p.x, p.y)
+	}
+	fmt.Fprintf(&s.b, `" fill="none" stroke="%s" stroke-width="%g" stroke-linejoin="round"/>`+"\n", svgColor(stroke), width)
+}
+
+func (s *svgCanvas) circle(cx, cy, r float64, fill color.RGBA) {
+	fmt.Fprintf(&s.b, `<circle cx="%.1f" cy="%.1f" r="%g" fill="%s"/>`+"\n", cx, cy, r, svgColor(fill))
+}
+
+func (s *svgCanvas) wedge(cx, cy, r, start, end float64, fill color.RGBA) {
+	// 整圆无法用一段圆弧表示
+	if end-start >= 2*math.Pi-1e-9 {
+		s.circle(cx, cy, r, fill)
+		return
+	}
+	x0, y0 := cx+r*math.Sin(start), cy-r*math.Cos(start)
+	x1, y1 := cx+r*math.Sin(end), cy-r*math.Cos(end)
+	large := 0
+	if end-start > math.Pi {
+		large = 1
+	}
+	fmt.Fprintf(&s.b, `<path d="M%.1f,%.1f L%.1f,%.1f A%.1f,%.1f 0 %d 1 %.1f,%.1f Z" fill="%s" stroke="#ffffff"/>`+"\n",
+		cx, cy, x0, y0, r, r, large, x1, y1, svgColor(fill))
+}
+
+func (s *svgCanvas) text(x, y float64, text string, size float64, anchor int, fill color.RGBA) {
+	anchors := [...]string{anchorStart: "start", anchorMiddle: "middle", anchorEnd: "end"}
+	fmt.Fprintf(&s.b, `<text x="%.1f" y="%.1f" font-size="%g" text-anchor="%s" fill="%s">%s</text>`+"\n",
+		x, y, size, anchors[anchor], svgColor(fill), html.EscapeString(text))
+}
+
+// measure 估算文字宽度，全角字符按字号计算，其余按半个字号
+func (s *svgCanvas) measure(text string, size float64) float64 {
+	width := 0.0
+	for _, r := range text {
+		if utf8.RuneLen(r) > 2 {
+			width += size
+		} else {
+			width += size * 0.55
+		}
+	}
+	return width
+}
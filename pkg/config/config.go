@@ -43,6 +43,7 @@ func Init(configFiles ...string) error {
 func setDefaults() {
 	config.SetDefault("server.port", 8080)
 	config.SetDefault("server.mode", "debug")
+	config.SetDefault("server.public_url", "")
 
 	config.SetDefault("jwt.secret", "your-jwt-secret-key")
 	config.SetDefault("jwt.expire", 86400)
@@ -67,6 +68,54 @@ func setDefaults() {
 	config.SetDefault("rate_limit.enabled", true)
 	config.SetDefault("rate_limit.max_requests", 1000)
 	config.SetDefault("rate_limit.duration", 3600)
+
+	config.SetDefault("datasource.max_open_conns", 10)
+	config.SetDefault("datasource.max_idle_conns", 2)
+	config.SetDefault("datasource.conn_max_lifetime", 3600)
+	config.SetDefault("datasource.conn_max_idle_time", 300)
+	config.SetDefault("datasource.idle_timeout", 1800)
+	config.SetDefault("datasource.health_check_interval", 60)
+	config.SetDefault("datasource.test_timeout", 10)
+	config.SetDefault("datasource.sqlite_dir", "data/sqlite")
+	config.SetDefault("datasource.import_max_rows", 100000)
+
+	config.SetDefault("schema.search_top_n", 5)
+	config.SetDefault("schema.search_max_columns", 30)
+	config.SetDefault("schema.profile_sample_rows", 10000)
+	config.SetDefault("schema.profile_max_distinct", 20)
+
+	config.SetDefault("pii.enabled", true)
+	config.SetDefault("pii.sample_rows", 100)
+	config.SetDefault("pii.match_ratio", 0.8)
+
+	config.SetDefault("audit.retention_days", 180)
+	config.SetDefault("audit.export_max_rows", 100000)
+
+	config.SetDefault("query.timeout", 30)
+	config.SetDefault("query.max_rows", 500)
+	config.SetDefault("query.max_bytes", 1048576)
+
+	config.SetDefault("text_to_sql.schema_top_n", 10)
+	config.SetDefault("text_to_sql.schema_max_tokens", 6000)
+	config.SetDefault("text_to_sql.max_retries", 2)
+
+	// SQL示例
+	config.SetDefault("sql_example.search_top_n", 3)
+
+	// 结果导出及文件存储
+	config.SetDefault("export.max_rows", 100000)
+	config.SetDefault("export.timeout", 300)
+	config.SetDefault("files.dir", "data/files")
+	config.SetDefault("files.url_ttl", 3600)
+	config.SetDefault("files.retention", 86400)
+	config.SetDefault("files.sign_key", "")
+
+	// 图表
+	config.SetDefault("chart.width", 800)
+	config.SetDefault("chart.height", 480)
+	config.SetDefault("chart.font_file", "")
+	config.SetDefault("chart.max_points", 200)
+	config.SetDefault("chart.pie_max_slices", 10)
 }
 
 // Get 获取配置值
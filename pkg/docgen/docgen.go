@@ -5,6 +5,7 @@ import (
 	"io"
 
 	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/text"
 )
@@ -27,6 +28,20 @@ func NewDocGenerator() *DocGenerator {
 	}
 }
 
+// NewTechnicalDocGenerator 创建用于数据字典等技术文档的Word文档生成器，
+// 解析时识别GFM表格，代码块按等宽字体输出，文本中的 < > & 保持转义
+func NewTechnicalDocGenerator() *DocGenerator {
+	return &DocGenerator{
+		markdown: goldmark.New(
+			goldmark.WithExtensions(extension.GFM),
+			goldmark.WithParserOptions(
+				parser.WithAutoHeadingID(),
+			),
+		),
+		renderer: newWordRenderer(&WordElementHandler{technical: true}),
+	}
+}
+
 // RenderBytes 从Markdown字节数据生成Word文档
 func (g *DocGenerator) RenderBytes(source []byte) ([]byte, error) {
 	// 创建文本Reader
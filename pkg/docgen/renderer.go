@@ -14,8 +14,11 @@ type WordRenderer struct {
 
 // NewWordRenderer 创建一个新的Word渲染器
 func NewWordRenderer() *WordRenderer {
+	return newWordRenderer(NewWordElementHandler())
+}
+
+func newWordRenderer(elementHandler *WordElementHandler) *WordRenderer {
 	mermaidRenderer := NewMermaidRenderer()
-	elementHandler := NewWordElementHandler()
 	htmlConverter := NewHtmlConverter(mermaidRenderer)
 	docxBuilder := NewDocxBuilder(elementHandler)
 
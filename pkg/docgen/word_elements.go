@@ -7,7 +7,9 @@ import (
 )
 
 // WordElementHandler 处理Word文档元素
-type WordElementHandler struct{}
+type WordElementHandler struct {
+	technical bool // 技术文档：输出代码块，保留XML转义
+}
 
 // NewWordElementHandler 创建Word元素处理器
 func NewWordElementHandler() *WordElementHandler {
@@ -36,6 +38,14 @@ func (h *WordElementHandler) ConvertHtmlToWordXml(htmlContent string) string {
 	// 处理Markdown格式的表格
 	htmlContent = h.processMarkdownTables(htmlContent)
 
+	if h.technical {
+		// 处理未渲染为图片的代码块
+		htmlContent = h.processCodeBlocks(htmlContent)
+		// 行内代码按普通文本输出
+		htmlContent = strings.ReplaceAll(htmlContent, "<code>", "")
+		htmlContent = strings.ReplaceAll(htmlContent, "</code>", "")
+	}
+
 	// 处理列表 - 在处理其他标签之前
 	htmlContent = h.processLists(htmlContent)
 
@@ -65,10 +75,12 @@ func (h *WordElementHandler) ConvertHtmlToWordXml(htmlContent string) string {
 	htmlContent = strings.ReplaceAll(htmlContent, "<br>", "</w:t></w:r></w:p><w:p><w:r><w:t>")
 	htmlContent = strings.ReplaceAll(htmlContent, "<br/>", "</w:t></w:r></w:p><w:p><w:r><w:t>")
 
-	// 处理字符实体
-	htmlContent = strings.ReplaceAll(htmlContent, "&lt;", "<")
-	htmlContent = strings.ReplaceAll(htmlContent, "&gt;", ">")
-	htmlContent = strings.ReplaceAll(htmlContent, "&amp;", "&")
+	// 处理字符实体，技术文档中 &lt; &gt; &amp; 在XML中同样需要转义，保留原样
+	if !h.technical {
+		htmlContent = strings.ReplaceAll(htmlContent, "&lt;", "<")
+		htmlContent = strings.ReplaceAll(htmlContent, "&gt;", ">")
+		htmlContent = strings.ReplaceAll(htmlContent, "&amp;", "&")
+	}
 	htmlContent = strings.ReplaceAll(htmlContent, "&quot;", "\"")
 
 	// 处理图片
@@ -162,6 +174,19 @@ func (h *WordElementHandler) processImages(html string) string {
 	return html
 }
 
+// processCodeBlocks 将代码块的每一行转为等宽字体的段落，保留缩进；围栏代码块带有 class="language-xxx" 属性
+func (h *WordElementHandler) processCodeBlocks(html string) string {
+	codeRegex := regexp.MustCompile(`<pre><code(?:\s[^>]*)?>([\s\S]*?)</code></pre>`)
+	return codeRegex.ReplaceAllStringFunc(html, func(match string) string {
+		code := strings.TrimRight(codeRegex.FindStringSubmatch(match)[1], "\n")
+		var b strings.Builder
+		for _, line := range strings.Split(code, "\n") {
+			b.WriteString(`<w:p><w:pPr><w:spacing w:before="0" w:after="0"/></w:pPr><w:r><w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas"/></w:rPr><w:t xml:space="preserve">` + line + `</w:t></w:r></w:p>`)
+		}
+		return b.String()
+	})
+}
+
 // processLists 处理HTML中的列表
 func (h *WordElementHandler) processLists(html string) string {
 	// 首先移除无序列表标记
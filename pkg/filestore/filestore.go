@@ -0,0 +1,183 @@
+package filestore
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/logger"
+)
+
+// 本地文件存储，用于导出结果等临时文件。每个文件保存在 files.dir/<ID>/<文件名>，
+// 通过带过期时间及签名的链接下载，超过 files.retention 的文件定期删除
+
+var (
+	ErrNotFound      = errors.New("文件不存在")
+	ErrInvalidName   = errors.New("文件名不合法")
+	ErrInvalidSign   = errors.New("下载链接签名错误")
+	ErrLinkExpired   = errors.New("下载链接已过期")
+	errInvalidFileID = errors.New("文件ID不合法")
+)
+
+// File 已保存的文件
+type File struct {
+	ID   string
+	Name string
+	Size int64
+}
+
+// Save 生成新的文件ID并由 write 写入文件内容，写入失败时删除文件
+func Save(name string, write func(w io.Writer) error) (*File, error) {
+	name, err := cleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(b)
+	dir := filepath.Join(config.GetString("files.dir"), id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	info, err := os.Stat(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	return &File{ID: id, Name: name, Size: info.Size()}, nil
+}
+
+// Open 打开文件，调用方负责关闭
+func Open(id, name string) (*os.File, error) {
+	path, err := filePath(id, name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// Sign 生成下载签名，签名与文件ID、文件名及过期时间（Unix秒）绑定
+func Sign(id, name string, expires int64) string {
+	mac := hmac.New(sha256.New, signKey())
+	mac.Write([]byte(id + "/" + name + "/" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验下载签名及过期时间
+func Verify(id, name string, expires int64, sign string) error {
+	if !hmac.Equal([]byte(Sign(id, name, expires)), []byte(sign)) {
+		return ErrInvalidSign
+	}
+	if time.Now().Unix() > expires {
+		return ErrLinkExpired
+	}
+	return nil
+}
+
+// signKey 签名密钥，未配置 files.sign_key 时使用JWT密钥
+func signKey() []byte {
+	if key := config.GetString("files.sign_key"); key != "" {
+		return []byte(key)
+	}
+	return config.GetJWTSecret()
+}
+
+// StartCleanup 每小时删除超过 files.retention 秒的文件，0为不删除
+func StartCleanup() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			cleanup()
+			<-ticker.C
+		}
+	}()
+}
+
+func cleanup() {
+	retention := config.GetInt("files.retention")
+	if retention <= 0 {
+		return
+	}
+	root := config.GetString("files.dir")
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("读取文件存储目录失败", logger.F("dir", root), logger.F("error", err))
+		}
+		return
+	}
+	before := time.Now().Add(-time.Duration(retention) * time.Second)
+	removed := 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.ModTime().After(before) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(root, e.Name())); err != nil {
+			logger.Warn("删除过期文件失败", logger.F("id", e.Name()), logger.F("error", err))
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		logger.Info("清理过期文件", logger.F("count", removed))
+	}
+}
+
+func filePath(id, name string) (string, error) {
+	if len(id) != 32 {
+		return "", errInvalidFileID
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", errInvalidFileID
+	}
+	name, err := cleanName(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config.GetString("files.dir"), id, name), nil
+}
+
+// cleanName 文件名不能包含路径
+func cleanName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.ContainsRune(name, 0) {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
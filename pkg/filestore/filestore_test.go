@@ -0,0 +1,177 @@
+package filestore
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yockii/dify_tools/pkg/config"
+	"github.com/yockii/dify_tools/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	_ = config.Init(os.DevNull)
+	dir, err := os.MkdirTemp("", "filestore")
+	if err != nil {
+		panic(err)
+	}
+	config.Set("log.filename", filepath.Join(dir, "logs", "app.log"))
+	logger.Init()
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// useDir 使用临时目录作为文件存储目录
+func useDir(t *testing.T) string {
+	dir := t.TempDir()
+	config.Set("files.dir", dir)
+	return dir
+}
+
+func TestSaveOpen(t *testing.T) {
+	useDir(t)
+	f, err := Save("结果.csv", func(w io.Writer) error {
+		_, err := io.WriteString(w, "a,b\n1,2\n")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.ID) != 32 || f.Name != "结果.csv" || f.Size != 8 {
+		t.Fatalf("Save() = %+v", f)
+	}
+
+	r, err := Open(f.ID, f.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if b, _ := io.ReadAll(r); string(b) != "a,b\n1,2\n" {
+		t.Fatalf("Open() content = %q", b)
+	}
+
+	if _, err := Open(f.ID, "other.csv"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open(other name) err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSaveWriteFailure(t *testing.T) {
+	dir := useDir(t)
+	if _, err := Save("a.csv", func(w io.Writer) error { return io.ErrUnexpectedEOF }); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Save() err = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("failed save left %d entries", len(entries))
+	}
+}
+
+func TestInvalidPath(t *testing.T) {
+	useDir(t)
+	id := strings.Repeat("ab", 16)
+	tests := []struct {
+		name     string
+		id, file string
+		want     error
+	}{
+		{"empty name", id, " ", ErrInvalidName},
+		{"parent", id, "..", ErrInvalidName},
+		{"slash", id, "../secret", ErrInvalidName},
+		{"backslash", id, `..\secret`, ErrInvalidName},
+		{"nul", id, "a\x00.csv", ErrInvalidName},
+		{"short id", "abcd", "a.csv", errInvalidFileID},
+		{"non hex id", strings.Repeat("zz", 16), "a.csv", errInvalidFileID},
+		{"traversal id", "../../../../../../../../etc/passw", "a.csv", errInvalidFileID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Open(tt.id, tt.file); !errors.Is(err, tt.want) {
+				t.Fatalf("Open(%q, %q) err = %v, want %v", tt.id, tt.file, err, tt.want)
+			}
+		})
+	}
+	if _, err := Save("a/b.csv", func(io.Writer) error { return nil }); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("Save(a/b.csv) err = %v, want ErrInvalidName", err)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	config.Set("files.sign_key", "test-key")
+	defer config.Set("files.sign_key", "")
+	id := strings.Repeat("ab", 16)
+	expires := time.Now().Add(time.Hour).Unix()
+	sign := Sign(id, "a.csv", expires)
+
+	tests := []struct {
+		name    string
+		id      string
+		file    string
+		expires int64
+		sign    string
+		want    error
+	}{
+		{"valid", id, "a.csv", expires, sign, nil},
+		{"other id", strings.Repeat("cd", 16), "a.csv", expires, sign, ErrInvalidSign},
+		{"other name", id, "b.csv", expires, sign, ErrInvalidSign},
+		{"extended expiry", id, "a.csv", expires + 3600, sign, ErrInvalidSign},
+		{"empty sign", id, "a.csv", expires, "", ErrInvalidSign},
+		{"expired", id, "a.csv", time.Now().Add(-time.Second).Unix(), Sign(id, "a.csv", time.Now().Add(-time.Second).Unix()), ErrLinkExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.id, tt.file, tt.expires, tt.sign); err != tt.want {
+				t.Fatalf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// 更换签名密钥后旧链接失效，未配置时使用JWT密钥
+	config.Set("files.sign_key", "")
+	if err := Verify(id, "a.csv", expires, sign); err != ErrInvalidSign {
+		t.Fatalf("Verify() after key change = %v, want ErrInvalidSign", err)
+	}
+	config.Set("files.sign_key", string(config.GetJWTSecret()))
+	jwtSign := Sign(id, "a.csv", expires)
+	config.Set("files.sign_key", "")
+	if Sign(id, "a.csv", expires) != jwtSign {
+		t.Fatal("Sign() should fall back to the JWT secret")
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	dir := useDir(t)
+	save := func() *File {
+		f, err := Save("a.csv", func(w io.Writer) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	old, recent := save(), save()
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, old.ID), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	config.Set("files.retention", 0)
+	cleanup()
+	if _, err := os.Stat(filepath.Join(dir, old.ID)); err != nil {
+		t.Fatal("retention 0 should keep all files")
+	}
+
+	config.Set("files.retention", 3600)
+	defer config.Set("files.retention", 0)
+	cleanup()
+	if _, err := Open(old.ID, old.Name); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired file still exists: %v", err)
+	}
+	f, err := Open(recent.ID, recent.Name)
+	if err != nil {
+		t.Fatalf("recent file removed: %v", err)
+	}
+	f.Close()
+}
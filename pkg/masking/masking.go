@@ -0,0 +1,96 @@
+package masking
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// 脱敏类型
+const (
+	TypeNone    = "none"    // 不脱敏
+	TypeHide    = "hide"    // 完全隐藏
+	TypeNull    = "null"    // 置空
+	TypeHash    = "hash"    // 哈希
+	TypeIDCard  = "id_card" // 身份证号，保留前3后4位
+	TypePhone   = "phone"   // 手机号，保留前3后4位
+	TypePartial = "partial" // 通用部分遮盖，保留首尾各1/4
+)
+
+const hiddenValue = "******"
+
+// 严格程度，同一列命中多个规则时取最严格的
+var ranks = map[string]int{
+	TypePartial: 1,
+	TypePhone:   2,
+	TypeIDCard:  3,
+	TypeHash:    4,
+	TypeNull:    5,
+	TypeHide:    6,
+}
+
+// IsValid 是否为支持的脱敏类型
+func IsValid(maskType string) bool {
+	return maskType == TypeNone || ranks[maskType] > 0
+}
+
+// IsMasked 是否需要脱敏
+func IsMasked(maskType string) bool {
+	return ranks[maskType] > 0
+}
+
+// Stricter 返回两个脱敏类型中更严格的一个
+func Stricter(a, b string) string {
+	if ranks[b] > ranks[a] {
+		return b
+	}
+	return a
+}
+
+// Mask 按脱敏类型处理单个值
+func Mask(value interface{}, maskType string) interface{} {
+	if value == nil || !IsMasked(maskType) {
+		return value
+	}
+	if maskType == TypeNull {
+		return nil
+	}
+	if maskType == TypeHide {
+		return hiddenValue
+	}
+
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	switch maskType {
+	case TypeHash:
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:8])
+	case TypePhone, TypeIDCard:
+		return keep(s, 3, 4)
+	default:
+		n := len([]rune(s)) / 4
+		return keep(s, n, n)
+	}
+}
+
+// keep 保留前prefix位与后suffix位，其余替换为*
+func keep(s string, prefix, suffix int) string {
+	r := []rune(s)
+	if len(r) <= prefix+suffix {
+		// 过短时只保留首字符
+		if len(r) <= 1 {
+			return hiddenValue
+		}
+		return string(r[:1]) + strings.Repeat("*", len(r)-1)
+	}
+	return string(r[:prefix]) + strings.Repeat("*", len(r)-prefix-suffix) + string(r[len(r)-suffix:])
+}
@@ -0,0 +1,148 @@
+package masking
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/yockii/dify_tools/pkg/sqlguard"
+)
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		maskType string
+		want     interface{}
+	}{
+		{"none", "13812345678", TypeNone, "13812345678"},
+		{"unknown type", "13812345678", "bogus", "13812345678"},
+		{"nil value", nil, TypeHide, nil},
+		{"hide", "secret", TypeHide, "******"},
+		{"null", "secret", TypeNull, nil},
+		{"phone", "13812345678", TypePhone, "138****5678"},
+		{"id card", "11010519491231002X", TypeIDCard, "110***********002X"},
+		{"partial", "abcdefgh", TypePartial, "ab****gh"},
+		{"partial multibyte", "浙江省杭州市西湖区", TypePartial, "浙江*****湖区"},
+		{"too short", "ab", TypePhone, "a*"},
+		{"single rune", "a", TypePartial, "*"},
+		{"bytes", []byte("13812345678"), TypePhone, "138****5678"},
+		{"number", int64(13812345678), TypePhone, "138****5678"},
+		{"hash", "secret", TypeHash, "2bb80d537b1da3e3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mask(tt.value, tt.maskType); got != tt.want {
+				t.Fatalf("Mask(%v, %s) = %v, want %v", tt.value, tt.maskType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStricter(t *testing.T) {
+	tests := []struct{ a, b, want string }{
+		{"", TypePartial, TypePartial},
+		{TypePartial, "", TypePartial},
+		{TypePhone, TypeHash, TypeHash},
+		{TypeHide, TypeNull, TypeHide},
+		{TypeNone, TypeIDCard, TypeIDCard},
+	}
+	for _, tt := range tests {
+		if got := Stricter(tt.a, tt.b); got != tt.want {
+			t.Errorf("Stricter(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPlanApply(t *testing.T) {
+	sources := map[string]string{"phone": TypePhone, "id_no": TypeIDCard}
+	tests := []struct {
+		name   string
+		sql    string
+		row    map[string]interface{}
+		masked []string
+	}{
+		{"direct column", "SELECT name, phone FROM users",
+			map[string]interface{}{"name": "a", "phone": "13812345678"}, []string{"phone"}},
+		{"alias", "SELECT phone AS contact FROM users",
+			map[string]interface{}{"contact": "13812345678"}, []string{"contact"}},
+		{"implicit alias", "SELECT u.phone c FROM users u",
+			map[string]interface{}{"c": "13812345678"}, []string{"c"}},
+		{"expression", "SELECT CONCAT('+86', phone) AS p, name FROM users",
+			map[string]interface{}{"p": "+8613812345678", "name": "a"}, []string{"p"}},
+		{"through subquery", "SELECT x.c FROM (SELECT phone AS c FROM users) x",
+			map[string]interface{}{"c": "13812345678"}, []string{"c"}},
+		{"through cte", "WITH t AS (SELECT id_no AS doc FROM users) SELECT doc AS d FROM t",
+			map[string]interface{}{"d": "11010519491231002X"}, []string{"d"}},
+		{"star", "SELECT * FROM users",
+			map[string]interface{}{"name": "a", "phone": "13812345678"}, []string{"phone"}},
+		{"clean columns", "SELECT name, age FROM users",
+			map[string]interface{}{"name": "a", "age": 3}, nil},
+		{"unnamed projection masks unknown columns", "SELECT phone || '' FROM users",
+			map[string]interface{}{"?column?": "13812345678"}, []string{"?column?"}},
+		{"set operation", "SELECT name FROM users UNION SELECT phone FROM users",
+			map[string]interface{}{"name": "13812345678"}, []string{"name"}},
+		{"values column alias", "SELECT x FROM users u CROSS JOIN LATERAL (VALUES (u.phone)) AS v(x)",
+			map[string]interface{}{"x": "13812345678"}, []string{"x"}},
+		{"table function column alias", "SELECT v.x FROM users u, unnest(ARRAY[u.phone]) AS v(x)",
+			map[string]interface{}{"x": "13812345678"}, []string{"x"}},
+		{"rows from column alias", "SELECT r.x, u.name FROM users u, ROWS FROM (unnest(ARRAY[u.id_no])) r(x)",
+			map[string]interface{}{"x": "11010519491231002X", "name": "a"}, []string{"x"}},
+		{"subquery column alias", "SELECT s.c FROM (SELECT phone FROM users) s(c)",
+			map[string]interface{}{"c": "13812345678"}, []string{"c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, err := sqlguard.Analyze(tt.sql, sqlguard.DialectPostgres)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := NewPlan(stmt, sources).Apply([]map[string]interface{}{tt.row})
+			sort.Strings(got)
+			if len(got) != len(tt.masked) {
+				t.Fatalf("masked = %v, want %v", got, tt.masked)
+			}
+			for i := range got {
+				if got[i] != tt.masked[i] {
+					t.Fatalf("masked = %v, want %v", got, tt.masked)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanWholeRowReference(t *testing.T) {
+	// row_to_json(u) 整行引用时按表名/别名的脱敏类型处理
+	stmt, err := sqlguard.Analyze("SELECT row_to_json(u) AS j FROM users u", sqlguard.DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []map[string]interface{}{{"j": `{"phone":"13812345678"}`}}
+	masked := NewPlan(stmt, map[string]string{"phone": TypePhone, "u": TypePhone}).Apply(rows)
+	if len(masked) != 1 || rows[0]["j"] == `{"phone":"13812345678"}` {
+		t.Fatalf("whole row reference not masked: %v %v", masked, rows[0])
+	}
+}
+
+func TestPlanTableAliasColumns(t *testing.T) {
+	// FROM users AS u(a, b) 按位置重命名了表中的列，按该表最严格的脱敏类型处理
+	stmt, err := sqlguard.Analyze("SELECT a, b FROM users AS u(a, b)", sqlguard.DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []map[string]interface{}{{"a": "13812345678", "b": "x"}}
+	masked := NewPlan(stmt, map[string]string{"phone": TypePhone, "users": TypeHide, "u": TypeHide}).Apply(rows)
+	sort.Strings(masked)
+	if len(masked) != 2 || rows[0]["a"] != "******" || rows[0]["b"] != "******" {
+		t.Fatalf("table alias columns not masked: %v %v", masked, rows[0])
+	}
+}
+
+func TestNewPlanWithoutMaskedSources(t *testing.T) {
+	stmt, err := sqlguard.Analyze("SELECT phone FROM users", sqlguard.DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := NewPlan(stmt, map[string]string{"phone": TypeNone}); p != nil {
+		t.Fatalf("NewPlan = %v, want nil", p)
+	}
+}
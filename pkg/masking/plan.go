@@ -0,0 +1,124 @@
+package masking
+
+import (
+	"strings"
+
+	"github.com/yockii/dify_tools/pkg/sqlguard"
+)
+
+// Plan 查询结果的脱敏计划
+//
+// 根据SQL中各层SELECT列表的引用关系，把需要脱敏的源列（及整行引用的表名/别名）
+// 沿输出列名传播，得到结果中每个列名对应的脱敏类型。无法确定列名或存在集合运算时从严处理。
+type Plan struct {
+	tainted      map[string]string // 列名（小写） → 脱敏类型
+	named        map[string]bool   // 已确定名称的投影
+	clean        map[string]bool   // 确定无需脱敏的输出列
+	unnamed      string            // 未能确定列名的投影中最严格的脱敏类型
+	setOperation bool
+}
+
+// NewPlan 创建脱敏计划，sources 为源列名或表名/别名（小写）到脱敏类型的映射
+func NewPlan(stmt *sqlguard.Statement, sources map[string]string) *Plan {
+	if len(sources) == 0 {
+		return nil
+	}
+	p := &Plan{
+		tainted:      make(map[string]string, len(sources)),
+		named:        make(map[string]bool),
+		clean:        make(map[string]bool),
+		setOperation: stmt.SetOperation,
+	}
+	for name, t := range sources {
+		if IsMasked(t) {
+			p.tainted[strings.ToLower(name)] = t
+		}
+	}
+	if len(p.tainted) == 0 {
+		return nil
+	}
+
+	// 沿投影传播，直到不再变化
+	for changed := true; changed; {
+		changed = false
+		for _, pr := range stmt.Projections {
+			if pr.Star || pr.Name == "" {
+				continue
+			}
+			name := strings.ToLower(pr.Name)
+			if t := p.strictestRef(pr.Refs); t != "" && Stricter(p.tainted[name], t) != p.tainted[name] {
+				p.tainted[name] = t
+				changed = true
+			}
+		}
+	}
+
+	for _, pr := range stmt.Projections {
+		if pr.Star {
+			continue
+		}
+		if pr.Name == "" {
+			p.unnamed = Stricter(p.unnamed, p.strictestRef(pr.Refs))
+			continue
+		}
+		name := strings.ToLower(pr.Name)
+		p.named[name] = true
+		if _, ok := p.tainted[name]; !ok {
+			p.clean[name] = true
+		}
+	}
+	return p
+}
+
+func (p *Plan) strictestRef(refs []string) string {
+	t := ""
+	for _, r := range refs {
+		t = Stricter(t, p.tainted[r])
+	}
+	return t
+}
+
+// Apply 对结果集脱敏，返回被脱敏的列名
+func (p *Plan) Apply(rows []map[string]interface{}) []string {
+	if p == nil || len(rows) == 0 {
+		return nil
+	}
+
+	// 需要脱敏但未出现在结果中的投影（如别名推断有误），按未知列处理
+	fallback := p.unnamed
+	keys := make(map[string]bool, len(rows[0]))
+	for k := range rows[0] {
+		keys[strings.ToLower(k)] = true
+	}
+	for name, t := range p.tainted {
+		if p.named[name] && !keys[name] {
+			fallback = Stricter(fallback, t)
+		}
+	}
+
+	types := make(map[string]string, len(rows[0]))
+	var masked []string
+	for k := range rows[0] {
+		lk := strings.ToLower(k)
+		t, ok := p.tainted[lk]
+		if !ok && fallback != "" && (p.setOperation || !p.clean[lk]) {
+			t = fallback
+		}
+		if t != "" {
+			types[k] = t
+			masked = append(masked, k)
+		}
+	}
+	if len(types) == 0 {
+		return nil
+	}
+
+	for _, row := range rows {
+		for k, t := range types {
+			if v, ok := row[k]; ok {
+				row[k] = Mask(v, t)
+			}
+		}
+	}
+	return masked
+}
@@ -0,0 +1,233 @@
+package pii
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yockii/dify_tools/pkg/masking"
+)
+
+// 敏感信息类型
+const (
+	TypePhone    = "phone"     // 手机号、固定电话
+	TypeIDCard   = "id_card"   // 身份证号
+	TypeEmail    = "email"     // 电子邮箱
+	TypeBankCard = "bank_card" // 银行卡号
+	TypeAddress  = "address"   // 地址
+	TypeName     = "name"      // 姓名
+)
+
+// 识别依据
+const (
+	SourceValue = "value" // 样本取值
+	SourceName  = "name"  // 列名或注释
+)
+
+type rule struct {
+	typ      string
+	keywords []string          // 列名关键字（小写，去掉分隔符后匹配），5个字符以下的需与列名中的单词完全相同
+	comments []string          // 注释关键字
+	match    func(string) bool // 取值校验，为nil时只按列名及注释识别
+	mask     string            // 建议的脱敏类型
+}
+
+// 按取值识别时依次检查，身份证号需在银行卡号之前
+var rules = []*rule{
+	{
+		typ:      TypeEmail,
+		keywords: []string{"email", "mail"},
+		comments: []string{"邮箱", "邮件"},
+		match:    emailRegex.MatchString,
+		mask:     masking.TypePartial,
+	},
+	{
+		typ:      TypeIDCard,
+		keywords: []string{"idcard", "idno", "idnumber", "identity", "certno", "ssn"},
+		comments: []string{"身份证", "证件号"},
+		match:    isIDCard,
+		mask:     masking.TypeIDCard,
+	},
+	{
+		typ:      TypePhone,
+		keywords: []string{"phone", "mobile", "tel"},
+		comments: []string{"手机", "电话", "联系方式"},
+		match:    isPhone,
+		mask:     masking.TypePhone,
+	},
+	{
+		typ:      TypeBankCard,
+		keywords: []string{"bankcard", "cardno", "cardnumber", "bankaccount", "accountno", "iban"},
+		comments: []string{"银行卡", "卡号", "银行账号"},
+		match:    isBankCard,
+		mask:     masking.TypePartial,
+	},
+	{
+		typ:      TypeAddress,
+		keywords: []string{"address", "addr"},
+		comments: []string{"地址", "住址"},
+		match:    isAddress,
+		mask:     masking.TypePartial,
+	},
+	{
+		typ:      TypeName,
+		keywords: []string{"realname", "fullname", "truename", "contactname", "customername", "personname", "firstname", "lastname", "surname", "consignee"},
+		comments: []string{"姓名", "联系人", "收货人"},
+		mask:     masking.TypePartial,
+	},
+}
+
+var (
+	emailRegex    = regexp.MustCompile(`^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}$`)
+	mobileRegex   = regexp.MustCompile(`^(\+?86[- ]?)?1[3-9]\d{9}$`)
+	landlineRegex = regexp.MustCompile(`^0\d{2,3}-\d{7,8}$`)
+	idCardRegex   = regexp.MustCompile(`^\d{17}[\dXx]$`)
+	bankCardRegex = regexp.MustCompile(`^\d{16,19}$`)
+	// 地址中的行政区划及街道门牌
+	addressMarkers = []string{"省", "市", "区", "县", "镇", "乡", "村", "路", "街", "道", "号", "弄", "巷", "栋", "室"}
+)
+
+// Classify 按列名、注释及样本取值识别敏感信息类型，未识别时返回空。
+// 样本中不少于 minRatio 比例的非空值符合某类格式时按取值识别；否则按列名及注释识别，
+// 此时若该类型有格式校验且样本中没有任何一个值符合（如 phone_verified 的取值为0/1），则不认为是敏感信息
+func Classify(name, comment string, values []string, minRatio float64) (typ, source string) {
+	var samples []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			samples = append(samples, v)
+		}
+	}
+
+	matched := make(map[string]int, len(rules))
+	for _, r := range rules {
+		if r.match == nil {
+			continue
+		}
+		for _, v := range samples {
+			if r.match(v) {
+				matched[r.typ]++
+			}
+		}
+		if len(samples) > 0 && float64(matched[r.typ]) >= minRatio*float64(len(samples)) {
+			return r.typ, SourceValue
+		}
+	}
+
+	for _, r := range rules {
+		if !matchName(r, name, comment) {
+			continue
+		}
+		if r.match != nil && len(samples) > 0 && matched[r.typ] == 0 {
+			continue
+		}
+		return r.typ, SourceName
+	}
+	return "", ""
+}
+
+// SuggestMask 敏感信息类型建议使用的脱敏类型
+func SuggestMask(typ string) string {
+	for _, r := range rules {
+		if r.typ == typ {
+			return r.mask
+		}
+	}
+	return masking.TypeHide
+}
+
+func matchName(r *rule, name, comment string) bool {
+	words := splitWords(name)
+	joined := strings.Join(words, "")
+	for _, k := range r.keywords {
+		if len(k) >= 5 && strings.Contains(joined, k) {
+			return true
+		}
+		for _, w := range words {
+			if w == k {
+				return true
+			}
+		}
+	}
+	for _, k := range r.comments {
+		if strings.Contains(comment, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitWords 按下划线等分隔符及驼峰拆分列名，转为小写
+func splitWords(name string) []string {
+	var words []string
+	var cur []rune
+	prevLower := false
+	for _, r := range name {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			if len(cur) > 0 {
+				words = append(words, string(cur))
+			}
+			cur, prevLower = nil, false
+			continue
+		case unicode.IsUpper(r) && prevLower:
+			words = append(words, string(cur))
+			cur = nil
+		}
+		prevLower = unicode.IsLower(r) || unicode.IsDigit(r)
+		cur = append(cur, unicode.ToLower(r))
+	}
+	if len(cur) > 0 {
+		words = append(words, string(cur))
+	}
+	return words
+}
+
+func isPhone(v string) bool {
+	return mobileRegex.MatchString(v) || landlineRegex.MatchString(v)
+}
+
+// isIDCard 18位身份证号，校验末位校验码
+func isIDCard(v string) bool {
+	if !idCardRegex.MatchString(v) {
+		return false
+	}
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(v[i]-'0') * w
+	}
+	return strings.ToUpper(v[17:]) == string("10X98765432"[sum%11])
+}
+
+// isBankCard 16至19位数字，通过Luhn校验
+func isBankCard(v string) bool {
+	if !bankCardRegex.MatchString(v) {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(v); i++ {
+		d := int(v[len(v)-1-i] - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// isAddress 至少6个字符且包含两种以上的行政区划或门牌标记
+func isAddress(v string) bool {
+	if utf8.RuneCountInString(v) < 6 {
+		return false
+	}
+	n := 0
+	for _, m := range addressMarkers {
+		if strings.Contains(v, m) {
+			n++
+		}
+	}
+	return n >= 2
+}
@@ -0,0 +1,83 @@
+package pii
+
+import (
+	"testing"
+
+	"github.com/yockii/dify_tools/pkg/masking"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name       string
+		column     string
+		comment    string
+		values     []string
+		wantType   string
+		wantSource string
+	}{
+		{"mobile values", "c1", "", []string{"13812345678", "+86 13912345678", "15800000000"}, TypePhone, SourceValue},
+		{"landline values", "c1", "", []string{"0571-88886666", "010-12345678"}, TypePhone, SourceValue},
+		{"id card values", "c1", "", []string{"11010519491231002X", "11010519491231002x"}, TypeIDCard, SourceValue},
+		{"bad id checksum", "c1", "", []string{"110105194912310021"}, "", ""},
+		{"bank card values", "c1", "", []string{"4111111111111111", "6011000990139424"}, TypeBankCard, SourceValue},
+		{"bad luhn", "c1", "", []string{"4111111111111112"}, "", ""},
+		{"email values", "c1", "", []string{"a@example.com", "b.c@test.cn"}, TypeEmail, SourceValue},
+		{"address values", "c1", "", []string{"浙江省杭州市西湖区文三路1号", "北京市海淀区中关村大街"}, TypeAddress, SourceValue},
+		{"below ratio", "c1", "", []string{"13812345678", "n/a", "unknown"}, "", ""},
+		{"empty values ignored", "c1", "", []string{"13812345678", "", "  "}, TypePhone, SourceValue},
+		{"name keyword", "mobile", "", nil, TypePhone, SourceName},
+		{"camel case keyword", "userIdCard", "", nil, TypeIDCard, SourceName},
+		{"joined long keyword", "buyer_real_name", "", nil, TypeName, SourceName},
+		{"short keyword needs whole word", "hotel", "", nil, "", ""},
+		{"comment keyword", "c1", "收货人姓名", nil, TypeName, SourceName},
+		{"name without matching values", "phone_verified", "", []string{"0", "1"}, "", ""},
+		{"name with some matching values", "phone", "", []string{"13812345678", "-", "-", "-"}, TypePhone, SourceName},
+		{"name rule without format", "real_name", "", []string{"张三", "李四"}, TypeName, SourceName},
+		{"plain column", "amount", "金额", []string{"12.5", "30"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, source := Classify(tt.column, tt.comment, tt.values, 0.8)
+			if typ != tt.wantType || source != tt.wantSource {
+				t.Fatalf("Classify(%q, %q, %v) = %q, %q, want %q, %q", tt.column, tt.comment, tt.values, typ, source, tt.wantType, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestSuggestMask(t *testing.T) {
+	tests := []struct{ typ, want string }{
+		{TypePhone, masking.TypePhone},
+		{TypeIDCard, masking.TypeIDCard},
+		{TypeEmail, masking.TypePartial},
+		{"unknown", masking.TypeHide},
+	}
+	for _, tt := range tests {
+		if got := SuggestMask(tt.typ); got != tt.want {
+			t.Fatalf("SuggestMask(%q) = %q, want %q", tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"user_phone", []string{"user", "phone"}},
+		{"userPhoneNo", []string{"user", "phone", "no"}},
+		{"ID_Card2", []string{"id", "card2"}},
+		{"__a--b__", []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		got := splitWords(tt.name)
+		if len(got) != len(tt.want) {
+			t.Fatalf("splitWords(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Fatalf("splitWords(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package search
+
+import "math"
+
+// BM25 参数
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Index BM25索引，文档为分词后的词列表
+type Index struct {
+	docs   []map[string]int // 每个文档的词频
+	lens   []int            // 每个文档的词数
+	avgLen float64
+	df     map[string]int // 包含该词的文档数
+}
+
+// NewIndex 创建索引
+func NewIndex(docs [][]string) *Index {
+	idx := &Index{
+		docs: make([]map[string]int, len(docs)),
+		lens: make([]int, len(docs)),
+		df:   make(map[string]int),
+	}
+	total := 0
+	for i, doc := range docs {
+		tf := make(map[string]int, len(doc))
+		for _, t := range doc {
+			tf[t]++
+		}
+		for t := range tf {
+			idx.df[t]++
+		}
+		idx.docs[i] = tf
+		idx.lens[i] = len(doc)
+		total += len(doc)
+	}
+	if len(docs) > 0 {
+		idx.avgLen = float64(total) / float64(len(docs))
+	}
+	return idx
+}
+
+// IDF 词的逆文档频率，不在任何文档中出现的词为0
+func (idx *Index) IDF(term string) float64 {
+	n := idx.df[term]
+	if n == 0 {
+		return 0
+	}
+	N := float64(len(idx.docs))
+	return math.Log(1 + (N-float64(n)+0.5)/(float64(n)+0.5))
+}
+
+// Score 计算查询与每个文档的BM25分数，查询中重复的词只计一次
+func (idx *Index) Score(query []string) []float64 {
+	scores := make([]float64, len(idx.docs))
+	if idx.avgLen == 0 {
+		return scores
+	}
+	seen := make(map[string]bool, len(query))
+	for _, term := range query {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		idf := idx.IDF(term)
+		if idf == 0 {
+			continue
+		}
+		for i, tf := range idx.docs {
+			f := float64(tf[term])
+			if f == 0 {
+				continue
+			}
+			norm := 1 - b + b*float64(idx.lens[i])/idx.avgLen
+			scores[i] += idf * f * (k1 + 1) / (f + k1*norm)
+		}
+	}
+	return scores
+}
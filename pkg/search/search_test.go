@@ -0,0 +1,92 @@
+package search
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"order_item", []string{"order", "item"}},
+		{"orderItem", []string{"order", "item"}},
+		{"userID", []string{"user", "id"}},
+		{"HTTPServer", []string{"http", "server"}},
+		{"item2Price", []string{"item2", "price"}},
+		{"订单金额", []string{"订单", "单金", "金额"}},
+		{"单", []string{"单"}},
+		{"用户user表", []string{"用户", "user", "表"}},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestIDF(t *testing.T) {
+	idx := NewIndex([][]string{{"a", "b"}, {"a"}, {"c"}})
+	if idx.IDF("missing") != 0 {
+		t.Fatal("IDF of an unknown term should be 0")
+	}
+	if !(idx.IDF("c") > idx.IDF("a")) {
+		t.Fatalf("rarer term should have a higher IDF: c=%v a=%v", idx.IDF("c"), idx.IDF("a"))
+	}
+	want := math.Log(1 + (3-2+0.5)/(2+0.5))
+	if got := idx.IDF("a"); math.Abs(got-want) > 1e-12 {
+		t.Fatalf("IDF(a) = %v, want %v", got, want)
+	}
+}
+
+func TestScore(t *testing.T) {
+	docs := [][]string{
+		Tokenize("orders 订单表 order_id amount"),
+		Tokenize("users 用户表 user_id phone"),
+		Tokenize("order_items 订单明细 order_id product_id quantity price"),
+	}
+	idx := NewIndex(docs)
+
+	tests := []struct {
+		name  string
+		query string
+		best  int
+	}{
+		{"english", "user phone", 1},
+		{"chinese", "订单表", 0},
+		{"term frequency", "order", 2},
+		{"rare term", "quantity", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := idx.Score(Tokenize(tt.query))
+			for i, s := range scores {
+				if i != tt.best && s >= scores[tt.best] {
+					t.Fatalf("Score(%q) = %v, want document %d ranked first", tt.query, scores, tt.best)
+				}
+			}
+		})
+	}
+
+	// 词频相同时较短的文档分数更高
+	if scores := NewIndex([][]string{{"a", "x"}, {"a", "x", "y", "z"}}).Score([]string{"a"}); !(scores[0] > scores[1]) {
+		t.Fatalf("shorter document should rank first: %v", scores)
+	}
+
+	// 查询中重复的词只计一次
+	once, twice := idx.Score([]string{"phone"}), idx.Score([]string{"phone", "phone"})
+	if !reflect.DeepEqual(once, twice) {
+		t.Fatalf("duplicate query terms changed scores: %v vs %v", once, twice)
+	}
+	for _, s := range idx.Score([]string{"missing"}) {
+		if s != 0 {
+			t.Fatal("unknown term should score 0")
+		}
+	}
+	if got := NewIndex(nil).Score([]string{"a"}); len(got) != 0 {
+		t.Fatalf("empty index scores = %v", got)
+	}
+}
@@ -0,0 +1,203 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/yockii/dify_tools/pkg/config"
+)
+
+// 密文格式：enc:<密钥ID>:<base64(密钥nonce | 加密后的数据密钥 | 数据nonce | 数据密文)>
+//
+// 每个值使用随机生成的数据密钥（DEK）加密，数据密钥再由配置中的主密钥（KEK）加密后与密文一起存储，
+// 更换主密钥时只需用新的主密钥重新加密，旧密钥保留在配置中即可继续解密历史数据
+const prefix = "enc:"
+
+// plainPrefix 未加密存储时，本身以 enc: 或 plain: 开头的明文加上该前缀，避免读取时被当作密文
+const plainPrefix = "plain:"
+
+const dekSize = 32
+
+var (
+	ErrKeyNotConfigured = errors.New("未配置加密主密钥")
+	ErrKeyNotFound      = errors.New("加密主密钥不存在")
+	ErrInvalidCipher    = errors.New("密文格式错误")
+)
+
+var (
+	keysOnce  sync.Once
+	keys      map[string][]byte
+	activeKey string
+	keysErr   error
+)
+
+// loadKeys 从配置加载主密钥，encryption.keys 为 密钥ID → base64编码的32字节密钥，encryption.active_key 为当前用于加密的密钥ID
+func loadKeys() error {
+	keysOnce.Do(func() {
+		keys = make(map[string][]byte)
+		for id, encoded := range config.GetStringMapString("encryption.keys") {
+			if strings.Contains(id, ":") {
+				keysErr = fmt.Errorf("密钥ID不能包含冒号: %s", id)
+				return
+			}
+			key, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil || len(key) != 32 {
+				keysErr = fmt.Errorf("主密钥 %s 必须为base64编码的32字节密钥", id)
+				return
+			}
+			keys[id] = key
+		}
+		activeKey = config.GetString("encryption.active_key")
+		if activeKey != "" && keys[activeKey] == nil {
+			keysErr = fmt.Errorf("%w: %s", ErrKeyNotFound, activeKey)
+		}
+	})
+	return keysErr
+}
+
+// Enabled 是否已配置用于加密的主密钥
+func Enabled() bool {
+	return loadKeys() == nil && activeKey != ""
+}
+
+// ActiveKeyID 当前用于加密的主密钥ID
+func ActiveKeyID() string {
+	if loadKeys() != nil {
+		return ""
+	}
+	return activeKey
+}
+
+// IsEncrypted 是否为加密后的值
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// KeyID 获取密文使用的主密钥ID，非密文返回空字符串
+func KeyID(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	id, _, _ := strings.Cut(value[len(prefix):], ":")
+	return id
+}
+
+// NeedsRotation 是否需要使用当前主密钥重新加密（明文或使用了旧主密钥）
+func NeedsRotation(value string) bool {
+	return value != "" && Enabled() && KeyID(value) != activeKey
+}
+
+// Encrypt 使用当前主密钥加密
+func Encrypt(plaintext string) (string, error) {
+	if err := loadKeys(); err != nil {
+		return "", err
+	}
+	if activeKey == "" {
+		return "", ErrKeyNotConfigured
+	}
+
+	dek := make([]byte, dekSize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(keys[activeKey], dek, []byte(activeKey))
+	if err != nil {
+		return "", err
+	}
+	data, err := seal(dek, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+	return prefix + activeKey + ":" + base64.RawStdEncoding.EncodeToString(append(wrapped, data...)), nil
+}
+
+// Escape 未配置主密钥时存储的明文，以 enc: 或 plain: 开头时加上前缀，Decrypt 时去掉
+func Escape(plaintext string) string {
+	if strings.HasPrefix(plaintext, prefix) || strings.HasPrefix(plaintext, plainPrefix) {
+		return plainPrefix + plaintext
+	}
+	return plaintext
+}
+
+// Decrypt 解密，非密文（如加密功能启用前保存的明文）原样返回，经 Escape 处理的明文去掉前缀
+func Decrypt(value string) (string, error) {
+	if strings.HasPrefix(value, plainPrefix) {
+		return value[len(plainPrefix):], nil
+	}
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if err := loadKeys(); err != nil {
+		return "", err
+	}
+	id, encoded, ok := strings.Cut(value[len(prefix):], ":")
+	if !ok {
+		return "", ErrInvalidCipher
+	}
+	kek, ok := keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidCipher
+	}
+
+	wrappedSize := nonceSize + dekSize + tagSize
+	if len(raw) < wrappedSize+nonceSize+tagSize {
+		return "", ErrInvalidCipher
+	}
+	dek, err := open(kek, raw[:wrappedSize], []byte(id))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dek, raw[wrappedSize:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+const (
+	nonceSize = 12
+	tagSize   = 16
+)
+
+// seal AES-GCM加密，返回 nonce | 密文
+func seal(key, plaintext, additional []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(key, data, additional []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, data[:nonceSize], data[nonceSize:], additional)
+	if err != nil {
+		return nil, ErrInvalidCipher
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
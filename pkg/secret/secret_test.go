@@ -0,0 +1,217 @@
+package secret
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yockii/dify_tools/pkg/config"
+)
+
+var (
+	testKeyA = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	testKeyB = base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+)
+
+func TestMain(m *testing.M) {
+	// 不读取配置文件，只使用默认值及测试中设置的值
+	_ = config.Init(os.DevNull)
+	os.Exit(m.Run())
+}
+
+// useKeys 设置主密钥配置并重新加载
+func useKeys(t *testing.T, active string, ks map[string]string) {
+	t.Helper()
+	config.Set("encryption.keys", ks)
+	config.Set("encryption.active_key", active)
+	keysOnce, keys, activeKey, keysErr = sync.Once{}, nil, "", nil
+	t.Cleanup(func() {
+		config.Set("encryption.keys", map[string]string{})
+		config.Set("encryption.active_key", "")
+		keysOnce, keys, activeKey, keysErr = sync.Once{}, nil, "", nil
+	})
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	useKeys(t, "a", map[string]string{"a": testKeyA})
+
+	for _, plaintext := range []string{"", "p@ssw0rd", "中文密码", "enc:a:not-a-cipher", "plain:x"} {
+		cipher, err := Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt(%q): %v", plaintext, err)
+		}
+		if !IsEncrypted(cipher) || KeyID(cipher) != "a" {
+			t.Fatalf("Encrypt(%q) = %q, want enc:a: prefix", plaintext, cipher)
+		}
+		if plaintext != "" && strings.Contains(cipher, plaintext) {
+			t.Fatalf("Encrypt(%q) = %q leaks plaintext", plaintext, cipher)
+		}
+		got, err := Decrypt(cipher)
+		if err != nil || got != plaintext {
+			t.Fatalf("Decrypt(Encrypt(%q)) = %q, %v", plaintext, got, err)
+		}
+	}
+
+	c1, _ := Encrypt("same")
+	c2, _ := Encrypt("same")
+	if c1 == c2 {
+		t.Fatalf("Encrypt should use a random data key and nonce, got %q twice", c1)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	useKeys(t, "a", map[string]string{"a": testKeyA})
+	old, err := Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NeedsRotation(old) {
+		t.Fatalf("NeedsRotation(%q) = true with the same active key", old)
+	}
+
+	useKeys(t, "b", map[string]string{"a": testKeyA, "b": testKeyB})
+	if ActiveKeyID() != "b" {
+		t.Fatalf("ActiveKeyID() = %q, want b", ActiveKeyID())
+	}
+	if !NeedsRotation(old) || !NeedsRotation("legacy plaintext") || NeedsRotation("") {
+		t.Fatal("NeedsRotation should report old-key ciphers and plaintext only")
+	}
+	got, err := Decrypt(old)
+	if err != nil || got != "secret" {
+		t.Fatalf("Decrypt with retired key = %q, %v", got, err)
+	}
+	rotated, err := Encrypt(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KeyID(rotated) != "b" || NeedsRotation(rotated) {
+		t.Fatalf("rotated cipher %q should use key b", rotated)
+	}
+
+	// 旧密钥从配置中移除后无法解密
+	useKeys(t, "b", map[string]string{"b": testKeyB})
+	if _, err := Decrypt(old); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Decrypt without key a: err = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestDecryptRejects(t *testing.T) {
+	useKeys(t, "a", map[string]string{"a": testKeyA})
+	cipher, err := Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := cipher[len("enc:a:"):]
+	raw, _ := base64.RawStdEncoding.DecodeString(encoded)
+	flip := func(i int) string {
+		b := append([]byte(nil), raw...)
+		b[i] ^= 1
+		return "enc:a:" + base64.RawStdEncoding.EncodeToString(b)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  error
+	}{
+		{"tampered data", flip(len(raw) - 1), ErrInvalidCipher},
+		{"tampered data key", flip(nonceSize + 1), ErrInvalidCipher},
+		{"truncated", cipher[:len(cipher)-10], ErrInvalidCipher},
+		{"bad base64", "enc:a:!!!", ErrInvalidCipher},
+		{"missing key id", "enc:" + encoded, ErrInvalidCipher},
+		{"unknown key", "enc:c:" + encoded, ErrKeyNotFound},
+		// 数据密钥与密钥ID绑定，换成另一个已配置的密钥ID也无法解密
+		{"wrong key", "enc:b:" + encoded, ErrInvalidCipher},
+	}
+	useKeys(t, "a", map[string]string{"a": testKeyA, "b": testKeyB})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Decrypt(tt.value); !errors.Is(err, tt.want) {
+				t.Fatalf("Decrypt(%q) = %q, %v, want %v", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidKeyConfig(t *testing.T) {
+	useKeys(t, "a", map[string]string{"a": "c2hvcnQ="})
+	if Enabled() {
+		t.Fatal("Enabled() = true with a short key")
+	}
+	if _, err := Encrypt("x"); err == nil {
+		t.Fatal("Encrypt should fail with a short key")
+	}
+
+	useKeys(t, "missing", map[string]string{"a": testKeyA})
+	if _, err := Encrypt("x"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Encrypt with unknown active key: err = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	useKeys(t, "", map[string]string{})
+	if Enabled() {
+		t.Fatal("Enabled() = true without keys")
+	}
+	if _, err := Encrypt("x"); !errors.Is(err, ErrKeyNotConfigured) {
+		t.Fatalf("Encrypt: err = %v, want ErrKeyNotConfigured", err)
+	}
+	if NeedsRotation("x") {
+		t.Fatal("NeedsRotation should be false when encryption is disabled")
+	}
+}
+
+func TestEscapedPlaintext(t *testing.T) {
+	tests := []struct {
+		plaintext string
+		stored    string
+	}{
+		{"p@ssw0rd", "p@ssw0rd"},
+		{"enc:looks-like-cipher", "plain:enc:looks-like-cipher"},
+		{"enc:a:AAAA", "plain:enc:a:AAAA"},
+		{"plain:text", "plain:plain:text"},
+		{"", ""},
+	}
+	useKeys(t, "", map[string]string{})
+	for _, tt := range tests {
+		t.Run(tt.plaintext, func(t *testing.T) {
+			if got := Escape(tt.plaintext); got != tt.stored {
+				t.Fatalf("Escape(%q) = %q, want %q", tt.plaintext, got, tt.stored)
+			}
+			got, err := Decrypt(tt.stored)
+			if err != nil || got != tt.plaintext {
+				t.Fatalf("Decrypt(%q) = %q, %v, want %q", tt.stored, got, err, tt.plaintext)
+			}
+		})
+	}
+}
+
+func TestStringValueScan(t *testing.T) {
+	values := []string{"", "p@ssw0rd", "enc:a:AAAA", "plain:x"}
+	for _, active := range []string{"", "a"} {
+		useKeys(t, active, map[string]string{"a": testKeyA})
+		for _, v := range values {
+			stored, err := String(v).Value()
+			if err != nil {
+				t.Fatalf("active=%q Value(%q): %v", active, v, err)
+			}
+			var got String
+			if err := got.Scan([]byte(stored.(string))); err != nil || string(got) != v {
+				t.Fatalf("active=%q Scan(Value(%q)) = %q, %v", active, v, got, err)
+			}
+		}
+	}
+
+	// 启用加密前保存的明文可直接读取
+	useKeys(t, "a", map[string]string{"a": testKeyA})
+	var got String
+	if err := got.Scan("legacy"); err != nil || got != "legacy" {
+		t.Fatalf("Scan(legacy) = %q, %v", got, err)
+	}
+	if err := got.Scan(nil); err != nil || got != "" {
+		t.Fatalf("Scan(nil) = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,41 @@
+package secret
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// String 数据库中加密存储的字符串字段，写入时使用当前主密钥加密，读取时自动解密，
+// 未配置主密钥时按明文存储（见 Escape）
+type String string
+
+// Value 实现 driver.Valuer
+func (s String) Value() (driver.Value, error) {
+	if s == "" {
+		return "", nil
+	}
+	if !Enabled() {
+		return Escape(string(s)), nil
+	}
+	return Encrypt(string(s))
+}
+
+// Scan 实现 sql.Scanner
+func (s *String) Scan(src interface{}) error {
+	var value string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("unsupported type for secret.String: %T", src)
+	}
+	plaintext, err := Decrypt(value)
+	if err != nil {
+		return err
+	}
+	*s = String(plaintext)
+	return nil
+}
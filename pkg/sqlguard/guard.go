@@ -0,0 +1,516 @@
+package sqlguard
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 违规类型代码，供调用方（如AI智能体）识别拒绝原因
+const (
+	CodeEmpty              = "empty_statement"
+	CodeSyntax             = "syntax_error"
+	CodeMultipleStatements = "multiple_statements"
+	CodeNotSelect          = "not_select"
+	CodeForbiddenKeyword   = "forbidden_keyword"
+	CodeForbiddenFunction  = "forbidden_function"
+	CodeForbiddenSyntax    = "forbidden_syntax"
+	CodeLockingClause      = "locking_clause"
+	CodeTableNotAllowed    = "table_not_allowed"
+)
+
+// Violation SQL检查不通过的原因
+type Violation struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+	Token  string `json:"token,omitempty"`
+	Offset int    `json:"offset"`
+}
+
+func newViolation(code, reason, token string, offset int) *Violation {
+	return &Violation{
+		Code:   code,
+		Reason: reason,
+		Token:  token,
+		Offset: offset,
+	}
+}
+
+func (v *Violation) Error() string {
+	if v.Token != "" {
+		return fmt.Sprintf("%s: %s (%s)", v.Code, v.Reason, v.Token)
+	}
+	return fmt.Sprintf("%s: %s", v.Code, v.Reason)
+}
+
+// TableRef SQL中引用的表
+type TableRef struct {
+	Schema string `json:"schema,omitempty"`
+	Name   string `json:"name"`
+	Alias  string `json:"alias,omitempty"`
+
+	start, end int      // 表名在Statement.SQL中的位置
+	nameText   string   // 表名的原始文本（含引号）
+	columns    []string // 别名后的列别名，如 FROM t AS a(x, y)
+}
+
+func (t TableRef) String() string {
+	if t.Schema != "" {
+		return t.Schema + "." + t.Name
+	}
+	return t.Name
+}
+
+// Statement 通过检查的只读查询语句
+type Statement struct {
+	Dialect Dialect
+	// SQL 去掉末尾分号后的语句
+	SQL string
+	// Tables 语句中引用的物理表（不含CTE），按出现顺序，同一表多次引用会重复出现
+	Tables []TableRef
+	// Projections 各层SELECT列表
+	Projections []Projection
+	// SetOperation 是否包含UNION/INTERSECT/EXCEPT，此时结果列名只来自第一个分支
+	SetOperation bool
+	// Limited 最外层是否已有LIMIT/FETCH子句
+	Limited bool
+}
+
+// CheckTables 检查引用的表是否都被允许访问
+func (s *Statement) CheckTables(allow func(table TableRef) bool) error {
+	for _, t := range s.Tables {
+		if !allow(t) {
+			return &Violation{
+				Code:   CodeTableNotAllowed,
+				Reason: "该表不存在或未开放查询",
+				Token:  t.String(),
+			}
+		}
+	}
+	return nil
+}
+
+// WithRowFilters 将引用的表替换为带行级过滤条件的派生表，即 t [AS] a 改写为 (SELECT * FROM t WHERE cond) [AS] a，
+// 未指定别名时以表名作为别名。filter 返回表的过滤条件，返回空字符串表示该表不过滤
+func (s *Statement) WithRowFilters(filter func(table TableRef) string) string {
+	var b strings.Builder
+	pos := 0
+	for _, t := range s.Tables {
+		cond := filter(t)
+		if cond == "" {
+			continue
+		}
+		b.WriteString(s.SQL[pos:t.start])
+		b.WriteString("(SELECT * FROM ")
+		b.WriteString(s.SQL[t.start:t.end])
+		b.WriteString(" WHERE (")
+		b.WriteString(cond)
+		b.WriteString("))")
+		if t.Alias == "" {
+			b.WriteString(" ")
+			b.WriteString(t.nameText)
+		}
+		pos = t.end
+	}
+	b.WriteString(s.SQL[pos:])
+	return b.String()
+}
+
+// CheckCondition 检查用作WHERE条件的表达式，括号必须成对且不能提前闭合，也不能包含禁止的关键字或函数
+func CheckCondition(cond string, dialect Dialect) error {
+	tokens, err := lex(cond, dialect)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return newViolation(CodeEmpty, "条件表达式为空", "", 0)
+	}
+	depth := 0
+	for _, t := range tokens {
+		switch {
+		case t.isPunct(";"):
+			return newViolation(CodeMultipleStatements, "条件表达式中不允许出现分号", ";", t.offset)
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			depth--
+			if depth < 0 {
+				return newViolation(CodeSyntax, "括号不匹配", ")", t.offset)
+			}
+		}
+	}
+	if depth != 0 {
+		return newViolation(CodeSyntax, "括号不匹配", "(", len(cond))
+	}
+	return checkTokens(tokens)
+}
+
+// QuoteString 将值转义为SQL字符串常量
+func QuoteString(value string, dialect Dialect) string {
+	value = strings.ReplaceAll(value, "'", "''")
+	if dialect == DialectMySQL {
+		value = strings.ReplaceAll(value, "\\", "\\\\")
+	}
+	return "'" + value + "'"
+}
+
+// 会修改数据、结构或权限的关键字，语句已限定以SELECT/WITH开头，
+// 这里主要拦截数据修改型CTE及 SELECT ... INTO
+var forbiddenKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true, "REPLACE": true,
+	"INTO": true, "OUTFILE": true, "DUMPFILE": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true, "GRANT": true, "REVOKE": true,
+	"CALL": true, "EXEC": true, "EXECUTE": true, "COPY": true,
+}
+
+// 具有副作用或可访问服务器资源的函数
+var forbiddenFunctions = map[string]bool{
+	// PostgreSQL
+	"PG_SLEEP": true, "PG_SLEEP_FOR": true, "PG_SLEEP_UNTIL": true,
+	"PG_READ_FILE": true, "PG_READ_BINARY_FILE": true, "PG_LS_DIR": true, "PG_STAT_FILE": true,
+	"PG_TERMINATE_BACKEND": true, "PG_CANCEL_BACKEND": true, "PG_RELOAD_CONF": true,
+	"PG_ADVISORY_LOCK": true, "PG_ADVISORY_XACT_LOCK": true, "PG_TRY_ADVISORY_LOCK": true,
+	"LO_IMPORT": true, "LO_EXPORT": true, "SET_CONFIG": true, "NEXTVAL": true, "SETVAL": true,
+	// 执行以字符串传入的SQL，可绕过表白名单、脱敏及行级过滤
+	"QUERY_TO_XML": true, "QUERY_TO_XML_AND_XMLSCHEMA": true, "QUERY_TO_XMLSCHEMA": true,
+	"TS_STAT": true, "TS_REWRITE": true,
+	// MySQL
+	"SLEEP": true, "BENCHMARK": true, "LOAD_FILE": true, "GET_LOCK": true, "RELEASE_LOCK": true,
+	"RELEASE_ALL_LOCKS": true, "MASTER_POS_WAIT": true, "SOURCE_POS_WAIT": true,
+	// SQLite
+	"LOAD_EXTENSION": true, "READFILE": true, "WRITEFILE": true, "EDIT": true, "FTS3_TOKENIZER": true,
+}
+
+// 按前缀禁止的函数：PostgreSQL导出表/库为XML及dblink系列，SQLite的 pragma_xxx() 可读取库结构
+var forbiddenFunctionPrefixes = []string{
+	"TABLE_TO_XML", "CURSOR_TO_XML", "DATABASE_TO_XML", "SCHEMA_TO_XML", "DBLINK", "PRAGMA_",
+}
+
+// isForbiddenFunction 函数名（大写）是否禁止调用
+func isForbiddenFunction(name string) bool {
+	if forbiddenFunctions[name] {
+		return true
+	}
+	for _, prefix := range forbiddenFunctionPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 出现在左括号前但不是函数调用的关键字
+var nonFunctionKeywords = map[string]bool{
+	"SELECT": true, "FROM": true, "JOIN": true, "WHERE": true, "ON": true, "USING": true,
+	"IN": true, "EXISTS": true, "ANY": true, "ALL": true, "SOME": true, "AS": true, "AND": true,
+	"OR": true, "NOT": true, "UNION": true, "INTERSECT": true, "EXCEPT": true, "LATERAL": true,
+	"WITH": true, "RECURSIVE": true, "MATERIALIZED": true, "HAVING": true, "BY": true,
+	"WHEN": true, "THEN": true, "ELSE": true, "CASE": true, "IS": true, "LIKE": true,
+	"BETWEEN": true, "VALUES": true, "DISTINCT": true, "RETURN": true, "LIMIT": true, "OFFSET": true,
+}
+
+// 表名之后不会作为别名的关键字
+var tableFollowKeywords = map[string]bool{
+	"WHERE": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true, "FULL": true,
+	"OUTER": true, "CROSS": true, "NATURAL": true, "STRAIGHT_JOIN": true, "ON": true, "USING": true,
+	"GROUP": true, "ORDER": true, "HAVING": true, "LIMIT": true, "OFFSET": true, "UNION": true,
+	"INTERSECT": true, "EXCEPT": true, "WINDOW": true, "FETCH": true, "FOR": true, "LOCK": true,
+	"FORCE": true, "IGNORE": true, "USE": true, "PARTITION": true, "TABLESAMPLE": true,
+	"SELECT": true, "RETURNING": true, "QUALIFY": true,
+}
+
+// 结束FROM表列表的子句关键字
+var clauseKeywords = map[string]bool{
+	"WHERE": true, "GROUP": true, "ORDER": true, "HAVING": true, "LIMIT": true, "OFFSET": true,
+	"UNION": true, "INTERSECT": true, "EXCEPT": true, "WINDOW": true, "FETCH": true, "FOR": true,
+	"SELECT": true, "RETURNING": true, "QUALIFY": true,
+}
+
+// Analyze 检查SQL是否为单条只读查询（SELECT/WITH），并解析引用的表
+func Analyze(sql string, dialect Dialect) (*Statement, error) {
+	tokens, err := lex(sql, dialect)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, newViolation(CodeEmpty, "SQL语句为空", "", 0)
+	}
+
+	// 仅允许末尾存在分号
+	end := len(tokens)
+	for end > 0 && tokens[end-1].isPunct(";") {
+		end--
+	}
+	for _, t := range tokens[:end] {
+		if t.isPunct(";") {
+			return nil, newViolation(CodeMultipleStatements, "只允许执行单条SQL语句", ";", t.offset)
+		}
+	}
+	tokens = tokens[:end]
+	if len(tokens) == 0 {
+		return nil, newViolation(CodeEmpty, "SQL语句为空", "", 0)
+	}
+
+	first := 0
+	for first < len(tokens) && tokens[first].isPunct("(") {
+		first++
+	}
+	if first >= len(tokens) || !tokens[first].isWord("SELECT", "WITH") {
+		t := tokens[min(first, len(tokens)-1)]
+		return nil, newViolation(CodeNotSelect, "只允许执行SELECT或WITH查询语句", t.text, t.offset)
+	}
+
+	if err := checkTokens(tokens); err != nil {
+		return nil, err
+	}
+
+	head, last := tokens[0], tokens[len(tokens)-1]
+	stmt := &Statement{
+		Dialect:     dialect,
+		SQL:         sql[head.offset : last.offset+len(last.text)],
+		Tables:      extractTables(tokens),
+		Projections: extractProjections(tokens),
+	}
+	for i, t := range stmt.Tables {
+		stmt.Tables[i].start -= head.offset
+		stmt.Tables[i].end -= head.offset
+		// 表别名的列别名按位置重命名了表中的列，无法对应到具体的列，视为整行引用
+		refs := []string{strings.ToLower(t.Name), strings.ToLower(t.Alias)}
+		for _, c := range t.columns {
+			stmt.Projections = append(stmt.Projections, Projection{Name: c, Refs: refs})
+		}
+	}
+	depth := 0
+	for i, t := range tokens {
+		switch {
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			depth--
+		case t.isWord("UNION", "INTERSECT", "EXCEPT"):
+			stmt.SetOperation = true
+		case depth == 0 && t.isWord("LIMIT", "FETCH") && !tokens[i-1].isPunct("."):
+			stmt.Limited = true
+		}
+	}
+	return stmt, nil
+}
+
+// checkTokens 检查禁止的关键字、函数及锁定子句
+func checkTokens(tokens []token) error {
+	for i, t := range tokens {
+		nextIsParen := i+1 < len(tokens) && tokens[i+1].isPunct("(")
+		// 函数调用，包括限定名（如 pg_catalog.pg_sleep）及加引号的函数名
+		if nextIsParen && t.isTableName() && isForbiddenFunction(strings.ToUpper(identName(t))) {
+			return newViolation(CodeForbiddenFunction, "不允许调用该函数", t.text, t.offset)
+		}
+		if t.kind != tokenWord {
+			continue
+		}
+		// 限定名中的部分（如 t.update）不是关键字
+		if i > 0 && tokens[i-1].isPunct(".") {
+			continue
+		}
+		switch t.value {
+		case "FOR":
+			if i+1 < len(tokens) && tokens[i+1].isWord("UPDATE", "SHARE", "NO", "KEY") {
+				return newViolation(CodeLockingClause, "不允许使用加锁查询", t.text+" "+tokens[i+1].text, t.offset)
+			}
+			continue
+		case "LOCK":
+			// MySQL的 LOCK IN SHARE MODE
+			if i+1 < len(tokens) && tokens[i+1].isWord("IN") {
+				return newViolation(CodeLockingClause, "不允许使用加锁查询", t.text, t.offset)
+			}
+			continue
+		case "TABLE":
+			// TABLE t 是 SELECT * FROM t 的简写，无法改写为带行级过滤的派生表
+			return newViolation(CodeForbiddenSyntax, "不允许使用TABLE简写，请改用SELECT * FROM", t.text, t.offset)
+		}
+		// 与关键字同名的函数（如MySQL的 REPLACE()、INSERT()）不视为关键字
+		if nextIsParen && t.value != "INTO" {
+			continue
+		}
+		if forbiddenKeywords[t.value] {
+			return newViolation(CodeForbiddenKeyword, "只读查询中不允许使用该关键字", t.text, t.offset)
+		}
+	}
+	return nil
+}
+
+// extractTables 解析FROM/JOIN后引用的表，忽略CTE名称、子查询及表函数
+func extractTables(tokens []token) []TableRef {
+	cteNames := collectCTENames(tokens)
+
+	type level struct {
+		function bool // 函数调用的括号
+		fromList bool // 处于FROM表列表中
+	}
+	levels := []level{{}}
+	expectTable := false
+	var tables []TableRef
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		cur := &levels[len(levels)-1]
+
+		if expectTable {
+			switch {
+			case t.isWord("LATERAL", "ONLY"):
+				continue
+			case t.isWord("ROWS") && i+1 < len(tokens) && tokens[i+1].isWord("FROM"):
+				// PostgreSQL的 ROWS FROM (f(...), ...)，括号内与表函数一样按函数调用处理
+				expectTable = false
+				i++
+				continue
+			case t.isPunct("(") && !(i+1 < len(tokens) && tokens[i+1].isWord("SELECT", "WITH")):
+				// 括号中的表或连接，如 FROM (a CROSS JOIN b)，括号内仍是表列表
+				levels = append(levels, level{fromList: true})
+				continue
+			case t.isTableName():
+				parts := []string{identName(t)}
+				j := i
+				for j+2 < len(tokens) && tokens[j+1].isPunct(".") && tokens[j+2].isTableName() {
+					parts = append(parts, identName(tokens[j+2]))
+					j += 2
+				}
+				expectTable = false
+				// 表函数，如 generate_series(...)
+				if j+1 < len(tokens) && tokens[j+1].isPunct("(") {
+					i = j
+					continue
+				}
+				i = j
+				ref := TableRef{
+					Name:     parts[len(parts)-1],
+					start:    t.offset,
+					end:      tokens[j].offset + len(tokens[j].text),
+					nameText: tokens[j].text,
+				}
+				if len(parts) > 1 {
+					ref.Schema = parts[len(parts)-2]
+				}
+				if ref.Schema == "" && (cteNames[strings.ToLower(ref.Name)] || strings.EqualFold(ref.Name, "DUAL")) {
+					continue
+				}
+				// 别名
+				alias := -1
+				if k := i + 1; k < len(tokens) {
+					if tokens[k].isWord("AS") && k+1 < len(tokens) && tokens[k+1].isTableName() {
+						alias = k + 1
+					} else if tokens[k].isTableName() && !(tokens[k].kind == tokenWord && tableFollowKeywords[tokens[k].value]) {
+						alias = k
+					}
+				}
+				if alias >= 0 {
+					ref.Alias = identName(tokens[alias])
+					if alias+1 < len(tokens) && tokens[alias+1].isPunct("(") {
+						for k := alias + 2; k < len(tokens) && !tokens[k].isPunct(")"); k++ {
+							if tokens[k].isTableName() {
+								ref.columns = append(ref.columns, identName(tokens[k]))
+							}
+						}
+					}
+				}
+				tables = append(tables, ref)
+				continue
+			default:
+				expectTable = false
+			}
+		}
+
+		switch {
+		case t.isPunct("("):
+			function := false
+			if i > 0 && tokens[i-1].isIdent() && !(tokens[i-1].kind == tokenWord && nonFunctionKeywords[tokens[i-1].value]) {
+				function = true
+			}
+			if i+1 < len(tokens) && tokens[i+1].isWord("SELECT", "WITH") {
+				function = false
+			}
+			levels = append(levels, level{function: function})
+		case t.isPunct(")"):
+			if len(levels) > 1 {
+				levels = levels[:len(levels)-1]
+			}
+		case t.isPunct(","):
+			if cur.fromList && !cur.function {
+				expectTable = true
+			}
+		case t.kind == tokenWord && !(i > 0 && tokens[i-1].isPunct(".")):
+			if cur.function {
+				// 如 EXTRACT(YEAR FROM col)、SUBSTRING(s FROM 1 FOR 2)
+				continue
+			}
+			switch {
+			case t.value == "FROM":
+				cur.fromList = true
+				expectTable = true
+			case t.value == "JOIN" || t.value == "STRAIGHT_JOIN":
+				expectTable = true
+			case clauseKeywords[t.value]:
+				cur.fromList = false
+			}
+		}
+	}
+	return tables
+}
+
+// collectCTENames 收集WITH子句中定义的CTE名称
+func collectCTENames(tokens []token) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < len(tokens); i++ {
+		if !tokens[i].isWord("WITH") {
+			continue
+		}
+		j := i + 1
+		if j < len(tokens) && tokens[j].isWord("RECURSIVE") {
+			j++
+		}
+		for j < len(tokens) && tokens[j].isIdent() {
+			names[strings.ToLower(identName(tokens[j]))] = true
+			j++
+			// 可选的列名列表
+			if j < len(tokens) && tokens[j].isPunct("(") {
+				j = skipParens(tokens, j)
+			}
+			if j < len(tokens) && tokens[j].isWord("AS") {
+				j++
+			}
+			for j < len(tokens) && tokens[j].isWord("NOT", "MATERIALIZED") {
+				j++
+			}
+			if j < len(tokens) && tokens[j].isPunct("(") {
+				j = skipParens(tokens, j)
+			}
+			if j < len(tokens) && tokens[j].isPunct(",") {
+				j++
+				continue
+			}
+			break
+		}
+	}
+	return names
+}
+
+// skipParens 跳过从i开始的一组括号，返回右括号之后的位置
+func skipParens(tokens []token, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		if tokens[i].isPunct("(") {
+			depth++
+		} else if tokens[i].isPunct(")") {
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+func identName(t token) string {
+	if t.kind == tokenWord {
+		return t.text
+	}
+	return t.value
+}
@@ -0,0 +1,208 @@
+package sqlguard
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		dialect Dialect
+		code    string
+	}{
+		{"empty", "  ; ", DialectPostgres, CodeEmpty},
+		{"comment only", "-- select 1", DialectPostgres, CodeEmpty},
+		{"delete", "DELETE FROM orders", DialectPostgres, CodeNotSelect},
+		{"update", "update orders set amount = 0", DialectMySQL, CodeNotSelect},
+		{"insert in parens", "(INSERT INTO t VALUES (1))", DialectPostgres, CodeNotSelect},
+		{"multiple statements", "SELECT 1; DROP TABLE orders", DialectPostgres, CodeMultipleStatements},
+		{"semicolon in comment is ignored but second statement is not", "SELECT 1 /* ; */; SELECT 2", DialectPostgres, CodeMultipleStatements},
+		{"data modifying cte", "WITH d AS (DELETE FROM orders RETURNING *) SELECT * FROM d", DialectPostgres, CodeForbiddenKeyword},
+		{"select into", "SELECT * INTO backup FROM orders", DialectPostgres, CodeForbiddenKeyword},
+		{"for update", "SELECT * FROM orders FOR UPDATE", DialectPostgres, CodeLockingClause},
+		{"for share", "SELECT * FROM orders FOR SHARE", DialectPostgres, CodeLockingClause},
+		{"lock in share mode", "SELECT * FROM orders LOCK IN SHARE MODE", DialectMySQL, CodeLockingClause},
+		{"pg_sleep", "SELECT pg_sleep(10)", DialectPostgres, CodeForbiddenFunction},
+		{"qualified function", "SELECT pg_catalog.pg_terminate_backend(123)", DialectPostgres, CodeForbiddenFunction},
+		{"quoted function", `SELECT "pg_read_file"('/etc/passwd')`, DialectPostgres, CodeForbiddenFunction},
+		{"query_to_xml", "SELECT query_to_xml('select * from secret', true, false, '')", DialectPostgres, CodeForbiddenFunction},
+		{"qualified query_to_xml", "SELECT pg_catalog.query_to_xml_and_xmlschema('select 1', true, false, '')", DialectPostgres, CodeForbiddenFunction},
+		{"table_to_xml", "SELECT table_to_xml('secret', true, false, '')", DialectPostgres, CodeForbiddenFunction},
+		{"table_to_xmlschema", "SELECT table_to_xmlschema('secret', true, false, '')", DialectPostgres, CodeForbiddenFunction},
+		{"cursor_to_xml", "SELECT cursor_to_xml('c', 10, true, false, '')", DialectPostgres, CodeForbiddenFunction},
+		{"database_to_xml", "SELECT database_to_xml(true, false, '')", DialectPostgres, CodeForbiddenFunction},
+		{"schema_to_xml", "SELECT schema_to_xml('public', true, false, '')", DialectPostgres, CodeForbiddenFunction},
+		{"dblink", "SELECT * FROM dblink('host=evil', 'select 1') AS t(x int)", DialectPostgres, CodeForbiddenFunction},
+		{"dblink_connect", "SELECT dblink_connect('host=evil')", DialectPostgres, CodeForbiddenFunction},
+		{"ts_stat", "SELECT * FROM ts_stat('select body from secret')", DialectPostgres, CodeForbiddenFunction},
+		{"sleep", "SELECT SLEEP(5)", DialectMySQL, CodeForbiddenFunction},
+		{"benchmark in where", "SELECT 1 FROM dual WHERE BENCHMARK(1000000, MD5('a'))", DialectMySQL, CodeForbiddenFunction},
+		{"load_extension", "SELECT load_extension('evil.so')", DialectSQLite, CodeForbiddenFunction},
+		{"pragma function", "SELECT * FROM pragma_table_info('secret')", DialectSQLite, CodeForbiddenFunction},
+		{"table in cte", "WITH x AS (TABLE secret) SELECT * FROM x", DialectPostgres, CodeForbiddenSyntax},
+		{"table in set operation", "SELECT 1 UNION TABLE secret", DialectPostgres, CodeForbiddenSyntax},
+		{"table in subquery", "SELECT * FROM ok WHERE id IN (TABLE secret)", DialectPostgres, CodeForbiddenSyntax},
+		{"mysql table statement", "SELECT * FROM (TABLE secret) t", DialectMySQL, CodeForbiddenSyntax},
+		{"unclosed string", "SELECT 'abc", DialectPostgres, CodeSyntax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Analyze(tt.sql, tt.dialect)
+			var v *Violation
+			if !errors.As(err, &v) {
+				t.Fatalf("Analyze(%q) error = %v, want violation %s", tt.sql, err, tt.code)
+			}
+			if v.Code != tt.code {
+				t.Fatalf("Analyze(%q) code = %s, want %s (%v)", tt.sql, v.Code, tt.code, v)
+			}
+		})
+	}
+}
+
+func TestAnalyzeAllows(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		dialect Dialect
+	}{
+		{"trailing semicolons", "SELECT 1;;", DialectPostgres},
+		{"keyword in string", "SELECT * FROM logs WHERE msg = 'DELETE FROM orders; DROP TABLE x'", DialectPostgres},
+		{"keyword as column", "SELECT t.update, t.delete FROM audit t", DialectPostgres},
+		{"keyword-named function", "SELECT REPLACE(name, 'a', 'b'), INSERT(name, 1, 2, 'x') FROM users", DialectMySQL},
+		{"dollar quoted string", "SELECT $$DROP TABLE x$$ AS s", DialectPostgres},
+		{"parenthesized select", "(SELECT 1) UNION (SELECT 2)", DialectPostgres},
+		{"extract from", "SELECT EXTRACT(YEAR FROM created_at) FROM orders", DialectPostgres},
+		{"column named like banned function", "SELECT sleep FROM stats", DialectMySQL},
+		{"qualified column named table", "SELECT t.table FROM stats t", DialectPostgres},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Analyze(tt.sql, tt.dialect); err != nil {
+				t.Fatalf("Analyze(%q) error = %v", tt.sql, err)
+			}
+		})
+	}
+}
+
+func TestAnalyzeTables(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		dialect Dialect
+		tables  []string // schema.name alias
+	}{
+		{"single", "SELECT * FROM orders", DialectPostgres, []string{"orders"}},
+		{"alias", "SELECT o.id FROM orders o", DialectPostgres, []string{"orders o"}},
+		{"as alias", "SELECT o.id FROM orders AS o WHERE o.id = 1", DialectPostgres, []string{"orders o"}},
+		{"schema", "SELECT * FROM sales.orders", DialectPostgres, []string{"sales.orders"}},
+		{"quoted", `SELECT * FROM "Order Items" oi`, DialectPostgres, []string{"Order Items oi"}},
+		{"backtick", "SELECT * FROM `orders`", DialectMySQL, []string{"orders"}},
+		{"bracket", "SELECT * FROM [orders]", DialectSQLite, []string{"orders"}},
+		{"comma list", "SELECT * FROM orders o, users u WHERE o.user_id = u.id", DialectPostgres, []string{"orders o", "users u"}},
+		{"joins", "SELECT * FROM orders o LEFT JOIN users u ON u.id = o.user_id INNER JOIN items i USING (order_id)", DialectPostgres,
+			[]string{"orders o", "users u", "items i"}},
+		{"subquery", "SELECT * FROM (SELECT * FROM secret) s", DialectPostgres, []string{"secret"}},
+		{"where subquery", "SELECT * FROM orders WHERE user_id IN (SELECT id FROM users)", DialectPostgres, []string{"orders", "users"}},
+		{"cte excluded", "WITH recent AS (SELECT * FROM orders) SELECT * FROM recent", DialectPostgres, []string{"orders"}},
+		{"table function skipped", "SELECT * FROM generate_series(1, 10) g", DialectPostgres, nil},
+		{"lateral", "SELECT * FROM users u, LATERAL (SELECT * FROM orders o WHERE o.user_id = u.id) x", DialectPostgres,
+			[]string{"users u", "orders o"}},
+		{"repeated", "SELECT * FROM orders a JOIN orders b ON a.id = b.parent_id", DialectPostgres, []string{"orders a", "orders b"}},
+		{"dual", "SELECT 1 FROM dual", DialectMySQL, nil},
+		{"mysql double quoted table", `SELECT * FROM "secret"`, DialectMySQL, []string{"secret"}},
+		{"parenthesized table", "SELECT * FROM (secret)", DialectPostgres, []string{"secret"}},
+		{"parenthesized table with alias", "SELECT s.* FROM (secret) AS s", DialectPostgres, []string{"secret"}},
+		{"parenthesized cross join", "SELECT * FROM (secret CROSS JOIN exposed)", DialectPostgres, []string{"secret", "exposed"}},
+		{"nested parenthesized joins", "SELECT * FROM ((secret s JOIN a ON a.id = s.id) LEFT JOIN (b JOIN c ON b.id = c.id) ON b.id = a.id)", DialectPostgres,
+			[]string{"secret s", "a", "b", "c"}},
+		{"parenthesized join after comma", "SELECT * FROM exposed, (secret JOIN a USING (id))", DialectPostgres, []string{"exposed", "secret", "a"}},
+		{"parenthesized join after join", "SELECT * FROM exposed e JOIN (secret s JOIN a ON a.id = s.id) ON s.id = e.id", DialectPostgres,
+			[]string{"exposed e", "secret s", "a"}},
+		{"mysql parenthesized list", "SELECT * FROM (exposed, secret)", DialectMySQL, []string{"exposed", "secret"}},
+		{"rows from", "SELECT * FROM ROWS FROM (generate_series(1, 2)) g", DialectPostgres, nil},
+		{"rows from with join", "SELECT * FROM ROWS FROM (generate_series(1, 2), unnest(ARRAY[1])) AS g(a, b) JOIN orders o ON o.id = g.a", DialectPostgres,
+			[]string{"orders o"}},
+		{"subquery in rows from", "SELECT * FROM ROWS FROM (unnest(ARRAY(SELECT id FROM secret))) g", DialectPostgres, []string{"secret"}},
+		{"parenthesized subquery", "SELECT * FROM ((SELECT * FROM secret)) x", DialectPostgres, []string{"secret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, err := Analyze(tt.sql, tt.dialect)
+			if err != nil {
+				t.Fatalf("Analyze(%q) error = %v", tt.sql, err)
+			}
+			var got []string
+			for _, ref := range stmt.Tables {
+				s := ref.String()
+				if ref.Alias != "" {
+					s += " " + ref.Alias
+				}
+				got = append(got, s)
+			}
+			if !reflect.DeepEqual(got, tt.tables) {
+				t.Fatalf("Analyze(%q) tables = %q, want %q", tt.sql, got, tt.tables)
+			}
+		})
+	}
+}
+
+func TestCheckTables(t *testing.T) {
+	stmt, err := Analyze("SELECT * FROM orders o JOIN secret s ON s.id = o.id", DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stmt.CheckTables(func(table TableRef) bool { return table.Name == "orders" })
+	var v *Violation
+	if !errors.As(err, &v) || v.Code != CodeTableNotAllowed || v.Token != "secret" {
+		t.Fatalf("CheckTables error = %v, want table_not_allowed for secret", err)
+	}
+	if err := stmt.CheckTables(func(TableRef) bool { return true }); err != nil {
+		t.Fatalf("CheckTables error = %v", err)
+	}
+}
+
+func TestCheckTablesParenthesized(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT * FROM (secret)",
+		"SELECT * FROM (secret CROSS JOIN exposed)",
+		"SELECT * FROM ((exposed JOIN (secret) ON true))",
+	} {
+		stmt, err := Analyze(sql, DialectPostgres)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stmt.CheckTables(func(table TableRef) bool { return table.Name == "exposed" }); err == nil {
+			t.Errorf("CheckTables(%q) allowed a table that is not exposed", sql)
+		}
+	}
+}
+
+func TestWithRowFilters(t *testing.T) {
+	stmt, err := Analyze("SELECT o.id, u.name FROM orders o JOIN users u ON u.id = o.user_id;", DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := stmt.WithRowFilters(func(table TableRef) string {
+		if table.Name == "orders" {
+			return "tenant_id = '42'"
+		}
+		return ""
+	})
+	want := "SELECT o.id, u.name FROM (SELECT * FROM orders WHERE (tenant_id = '42')) o JOIN users u ON u.id = o.user_id"
+	if got != want {
+		t.Fatalf("WithRowFilters = %q, want %q", got, want)
+	}
+
+	stmt, err = Analyze("SELECT * FROM orders", DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = stmt.WithRowFilters(func(TableRef) string { return "tenant_id = '42'" })
+	if !strings.Contains(got, "(SELECT * FROM orders WHERE (tenant_id = '42'))") || !strings.HasSuffix(got, "orders") {
+		t.Fatalf("WithRowFilters without alias = %q", got)
+	}
+}
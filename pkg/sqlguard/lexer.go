@@ -0,0 +1,280 @@
+package sqlguard
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Dialect SQL方言
+type Dialect string
+
+const (
+	DialectMySQL    Dialect = "mysql"
+	DialectPostgres Dialect = "postgres"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+// DialectOf 根据数据源类型获取SQL方言
+func DialectOf(dataSourceType string) Dialect {
+	switch strings.ToLower(dataSourceType) {
+	case "mysql":
+		return DialectMySQL
+	case "postgres", "postgresql":
+		return DialectPostgres
+	case "sqlite", "sqlite3":
+		return DialectSQLite
+	}
+	return Dialect(strings.ToLower(dataSourceType))
+}
+
+type tokenKind int
+
+const (
+	tokenWord       tokenKind = iota // 关键字或未加引号的标识符
+	tokenQuotedWord                  // 加引号的标识符
+	tokenString                      // 字符串常量
+	tokenNumber                      // 数字常量
+	tokenParam                       // 参数占位符
+	tokenPunct                       // 运算符及标点
+)
+
+type token struct {
+	kind   tokenKind
+	text   string // 原始文本
+	value  string // 关键字为大写形式，标识符为去掉引号后的名称
+	offset int
+}
+
+func (t token) isWord(words ...string) bool {
+	if t.kind != tokenWord {
+		return false
+	}
+	for _, w := range words {
+		if t.value == w {
+			return true
+		}
+	}
+	return false
+}
+
+func (t token) isPunct(p string) bool {
+	return t.kind == tokenPunct && t.text == p
+}
+
+func (t token) isIdent() bool {
+	return t.kind == tokenWord || t.kind == tokenQuotedWord
+}
+
+// isTableName 可能作为表名的token，MySQL中的双引号字符串按标识符处理以免漏检
+func (t token) isTableName() bool {
+	return t.isIdent() || (t.kind == tokenString && strings.HasPrefix(t.text, "\""))
+}
+
+// lex 将SQL拆分为token，注释会被丢弃
+func lex(sql string, dialect Dialect) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(sql) {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			i = skipLine(sql, i)
+		case c == '#' && dialect == DialectMySQL:
+			i = skipLine(sql, i)
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			// MySQL的 /*! ... */ 可执行注释会被服务端执行，不能当作普通注释忽略
+			if dialect == DialectMySQL && i+2 < len(sql) && (sql[i+2] == '!' || sql[i+2] == '+') {
+				return nil, newViolation(CodeForbiddenSyntax, "不允许使用MySQL可执行注释", "/*"+string(sql[i+2]), i)
+			}
+			end, ok := skipBlockComment(sql, i, dialect == DialectPostgres)
+			if !ok {
+				return nil, newViolation(CodeSyntax, "注释未闭合", "/*", i)
+			}
+			i = end
+		case c == '\'':
+			end, ok := skipQuoted(sql, i, '\'', dialect == DialectMySQL)
+			if !ok {
+				return nil, newViolation(CodeSyntax, "字符串未闭合", "'", i)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: sql[i:end], offset: i})
+			i = end
+		case c == '"':
+			end, ok := skipQuoted(sql, i, '"', dialect == DialectMySQL)
+			if !ok {
+				return nil, newViolation(CodeSyntax, "引号未闭合", "\"", i)
+			}
+			if dialect == DialectMySQL {
+				// MySQL默认模式下双引号为字符串，ANSI_QUOTES模式下则为标识符
+				tokens = append(tokens, token{kind: tokenString, text: sql[i:end], value: unquote(sql[i:end], '"'), offset: i})
+			} else {
+				tokens = append(tokens, token{kind: tokenQuotedWord, text: sql[i:end], value: unquote(sql[i:end], '"'), offset: i})
+			}
+			i = end
+		case c == '`' && (dialect == DialectMySQL || dialect == DialectSQLite):
+			end, ok := skipQuoted(sql, i, '`', false)
+			if !ok {
+				return nil, newViolation(CodeSyntax, "标识符引号未闭合", "`", i)
+			}
+			tokens = append(tokens, token{kind: tokenQuotedWord, text: sql[i:end], value: unquote(sql[i:end], '`'), offset: i})
+			i = end
+		case c == '[' && dialect == DialectSQLite:
+			// SQLite兼容SQL Server的 [标识符]
+			end := strings.IndexByte(sql[i:], ']')
+			if end < 0 {
+				return nil, newViolation(CodeSyntax, "标识符引号未闭合", "[", i)
+			}
+			end += i + 1
+			tokens = append(tokens, token{kind: tokenQuotedWord, text: sql[i:end], value: sql[i+1 : end-1], offset: i})
+			i = end
+		case c == '$' && dialect == DialectPostgres && i+1 < len(sql) && !isDigit(sql[i+1]):
+			// 美元符号引用字符串 $tag$...$tag$
+			tag, ok := dollarTag(sql, i)
+			if !ok {
+				tokens = append(tokens, token{kind: tokenPunct, text: "$", offset: i})
+				i++
+				continue
+			}
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				return nil, newViolation(CodeSyntax, "字符串未闭合", tag, i)
+			}
+			end = i + len(tag) + end + len(tag)
+			tokens = append(tokens, token{kind: tokenString, text: sql[i:end], offset: i})
+			i = end
+		case (c == 'E' || c == 'e') && dialect == DialectPostgres && i+1 < len(sql) && sql[i+1] == '\'':
+			end, ok := skipQuoted(sql, i+1, '\'', true)
+			if !ok {
+				return nil, newViolation(CodeSyntax, "字符串未闭合", "E'", i)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: sql[i:end], offset: i})
+			i = end
+		case c == '?' || (c == '$' && i+1 < len(sql) && isDigit(sql[i+1])):
+			end := i + 1
+			for end < len(sql) && isDigit(sql[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenParam, text: sql[i:end], offset: i})
+			i = end
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			end := i + 1
+			for end < len(sql) && (isDigit(sql[end]) || sql[end] == '.' || sql[end] == 'e' || sql[end] == 'E' ||
+				((sql[end] == '+' || sql[end] == '-') && (sql[end-1] == 'e' || sql[end-1] == 'E'))) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: sql[i:end], offset: i})
+			i = end
+		case isWordStart(sql, i):
+			end := i
+			for end < len(sql) {
+				r, size := utf8.DecodeRuneInString(sql[end:])
+				if !(r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+					break
+				}
+				end += size
+			}
+			text := sql[i:end]
+			tokens = append(tokens, token{kind: tokenWord, text: text, value: strings.ToUpper(text), offset: i})
+			i = end
+		default:
+			// 多字符运算符
+			if i+1 < len(sql) {
+				two := sql[i : i+2]
+				switch two {
+				case "::", "<=", ">=", "<>", "!=", "||", "&&", "->", "=>", ":=", "<<", ">>":
+					tokens = append(tokens, token{kind: tokenPunct, text: two, offset: i})
+					i += 2
+					continue
+				}
+			}
+			_, size := utf8.DecodeRuneInString(sql[i:])
+			tokens = append(tokens, token{kind: tokenPunct, text: sql[i : i+size], offset: i})
+			i += size
+		}
+	}
+	return tokens, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordStart(s string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func skipLine(s string, i int) int {
+	if end := strings.IndexByte(s[i:], '\n'); end >= 0 {
+		return i + end + 1
+	}
+	return len(s)
+}
+
+// skipBlockComment 跳过块注释，PostgreSQL支持嵌套注释
+func skipBlockComment(s string, i int, nested bool) (int, bool) {
+	depth := 0
+	for i < len(s)-1 {
+		switch {
+		case s[i] == '/' && s[i+1] == '*':
+			if depth == 0 || nested {
+				depth++
+			}
+			i += 2
+		case s[i] == '*' && s[i+1] == '/':
+			depth--
+			i += 2
+			if depth == 0 {
+				return i, true
+			}
+		default:
+			i++
+		}
+	}
+	return len(s), false
+}
+
+// skipQuoted 跳过引号包裹的内容，重复的引号视为转义，backslash为true时反斜杠也作为转义符
+func skipQuoted(s string, i int, quote byte, backslash bool) (int, bool) {
+	i++
+	for i < len(s) {
+		switch {
+		case backslash && s[i] == '\\':
+			i += 2
+		case s[i] == quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i += 2
+				continue
+			}
+			return i + 1, true
+		default:
+			i++
+		}
+	}
+	return len(s), false
+}
+
+func dollarTag(s string, i int) (string, bool) {
+	end := i + 1
+	for end < len(s) && s[end] != '$' {
+		c := s[end]
+		if !(c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80) {
+			return "", false
+		}
+		end++
+	}
+	if end >= len(s) {
+		return "", false
+	}
+	return s[i : end+1], true
+}
+
+func unquote(s string, quote byte) string {
+	if len(s) >= 2 {
+		s = s[1 : len(s)-1]
+	}
+	q := string(quote)
+	return strings.ReplaceAll(s, q+q, q)
+}
@@ -0,0 +1,216 @@
+package sqlguard
+
+import "strings"
+
+// Projection SELECT列表中的一项（包含子查询及CTE中的SELECT列表）
+type Projection struct {
+	// Name 输出列名，无法确定时为空
+	Name string `json:"name,omitempty"`
+	// Star 是否为 * 或 t.*
+	Star bool `json:"star,omitempty"`
+	// Refs 表达式中引用的标识符（小写），限定名前缀不计入，整行引用（如 to_json(t.*)）记为表名或别名
+	Refs []string `json:"refs,omitempty"`
+}
+
+// 结束SELECT列表的关键字
+var selectListEnd = map[string]bool{
+	"FROM": true, "INTO": true, "WHERE": true, "GROUP": true, "HAVING": true, "ORDER": true,
+	"LIMIT": true, "OFFSET": true, "UNION": true, "INTERSECT": true, "EXCEPT": true,
+	"WINDOW": true, "FETCH": true, "FOR": true, "LOCK": true,
+}
+
+// SELECT后的修饰词
+var selectModifiers = map[string]bool{
+	"DISTINCT": true, "ALL": true, "DISTINCTROW": true, "HIGH_PRIORITY": true, "STRAIGHT_JOIN": true,
+	"SQL_SMALL_RESULT": true, "SQL_BIG_RESULT": true, "SQL_BUFFER_RESULT": true,
+	"SQL_NO_CACHE": true, "SQL_CACHE": true, "SQL_CALC_FOUND_ROWS": true,
+}
+
+// 不会作为隐式别名的关键字
+var nonAliasWords = map[string]bool{
+	"END": true, "NULL": true, "TRUE": true, "FALSE": true, "UNKNOWN": true,
+	"ASC": true, "DESC": true, "AND": true, "OR": true, "NOT": true, "ELSE": true, "THEN": true,
+}
+
+// extractProjections 解析所有SELECT列表，并把CTE及派生表的列别名作为引用整个子查询的投影
+func extractProjections(tokens []token) []Projection {
+	var projections []Projection
+	for i, t := range tokens {
+		if !t.isWord("SELECT") || (i > 0 && tokens[i-1].isPunct(".")) {
+			continue
+		}
+		j := i + 1
+		for j < len(tokens) && tokens[j].kind == tokenWord && selectModifiers[tokens[j].value] {
+			j++
+			// PostgreSQL的 DISTINCT ON (...)
+			if tokens[j-1].value == "DISTINCT" && j+1 < len(tokens) && tokens[j].isWord("ON") && tokens[j+1].isPunct("(") {
+				j = skipParens(tokens, j+1)
+			}
+		}
+		depth, start := 0, j
+		for ; j <= len(tokens); j++ {
+			end := j == len(tokens)
+			if !end {
+				tk := tokens[j]
+				switch {
+				case tk.isPunct("("):
+					depth++
+					continue
+				case tk.isPunct(")"):
+					if depth > 0 {
+						depth--
+						continue
+					}
+					end = true
+				case depth > 0:
+					continue
+				case tk.isPunct(","):
+					if p, ok := projectionOf(tokens[start:j]); ok {
+						projections = append(projections, p)
+					}
+					start = j + 1
+					continue
+				case tk.kind == tokenWord && selectListEnd[tk.value] && !tokens[j-1].isPunct("."):
+					end = true
+				}
+			}
+			if end {
+				if p, ok := projectionOf(tokens[start:j]); ok {
+					projections = append(projections, p)
+				}
+				break
+			}
+		}
+	}
+	return append(projections, columnAliasProjections(tokens)...)
+}
+
+// projectionOf 解析SELECT列表中的一项
+func projectionOf(item []token) (Projection, bool) {
+	n := len(item)
+	if n == 0 {
+		return Projection{}, false
+	}
+	if item[n-1].isPunct("*") && (n == 1 || item[n-2].isPunct(".")) {
+		return Projection{Star: true}, true
+	}
+
+	var p Projection
+	expr := item
+	switch {
+	case n >= 2 && item[n-2].isWord("AS") && (item[n-1].isTableName() || item[n-1].kind == tokenString):
+		p.Name = aliasName(item[n-1])
+		expr = item[:n-2]
+	case n >= 2 && item[n-1].isTableName() && !(item[n-1].kind == tokenWord && nonAliasWords[item[n-1].value]) && endsExpression(item[n-2]):
+		p.Name = aliasName(item[n-1])
+		expr = item[:n-1]
+	case isColumnRef(item):
+		p.Name = identName(item[n-1])
+	}
+	p.Refs = identRefs(expr)
+	return p, true
+}
+
+// columnAliasProjections 处理 WITH c(x, y) AS (...) 与 FROM中 (...) AS s(x, y)、f(...) AS s(x, y) 形式的列别名，
+// 括号中可以是子查询、VALUES或表函数，无法按位置对应时，每个别名都视为引用了括号中的全部标识符
+func columnAliasProjections(tokens []token) []Projection {
+	var projections []Projection
+	aliasColumns := func(listStart int, body []token) {
+		refs := identRefs(body)
+		for k := listStart + 1; k < len(tokens) && !tokens[k].isPunct(")"); k++ {
+			if tokens[k].isTableName() {
+				projections = append(projections, Projection{Name: identName(tokens[k]), Refs: refs})
+			}
+		}
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		// WITH name (cols) AS (body)
+		if t.isTableName() && i+1 < len(tokens) && tokens[i+1].isPunct("(") && i > 0 &&
+			(tokens[i-1].isWord("WITH", "RECURSIVE") || tokens[i-1].isPunct(",")) {
+			listEnd := skipParens(tokens, i+1)
+			j := listEnd
+			if j < len(tokens) && tokens[j].isWord("AS") {
+				j++
+				for j < len(tokens) && tokens[j].isWord("NOT", "MATERIALIZED") {
+					j++
+				}
+				if j < len(tokens) && tokens[j].isPunct("(") {
+					aliasColumns(i+1, tokens[j:skipParens(tokens, j)])
+				}
+			}
+			continue
+		}
+		// (subquery) [AS] alias (cols)、(VALUES ...) [AS] alias (cols)、f(args) [AS] alias (cols)
+		if t.isPunct("(") && isFromItemStart(tokens, i) {
+			end := skipParens(tokens, i)
+			j := end
+			if j < len(tokens) && tokens[j].isWord("AS") {
+				j++
+			}
+			// 排除 agg(...) FILTER (...)、OVER (...)、WITHIN GROUP (...)
+			if j+1 < len(tokens) && tokens[j].isTableName() && !tokens[j].isWord("FILTER", "OVER", "WITHIN") && tokens[j+1].isPunct("(") {
+				aliasColumns(j+1, tokens[i:end])
+			}
+		}
+	}
+	return projections
+}
+
+// isFromItemStart 位于i的左括号是否开始一个FROM项（括号本身或表函数的参数），
+// 即括号或函数名之前为 FROM、JOIN、LATERAL、逗号或左括号（如 ROWS FROM (f(...))）
+func isFromItemStart(tokens []token, i int) bool {
+	k := i - 1
+	// 函数名，可以是限定名
+	if k >= 0 && tokens[k].isTableName() && !(tokens[k].kind == tokenWord && nonFunctionKeywords[tokens[k].value]) {
+		k--
+		for k >= 1 && tokens[k].isPunct(".") && tokens[k-1].isTableName() {
+			k -= 2
+		}
+	}
+	return k >= 0 && (tokens[k].isWord("FROM", "JOIN", "LATERAL") || tokens[k].isPunct(",") || tokens[k].isPunct("("))
+}
+
+func aliasName(t token) string {
+	if t.kind == tokenString && len(t.text) >= 2 {
+		return unquote(t.text, t.text[0])
+	}
+	return identName(t)
+}
+
+func endsExpression(t token) bool {
+	return t.isTableName() || t.kind == tokenNumber || t.kind == tokenString || t.isPunct(")")
+}
+
+// isColumnRef 是否为简单的列引用，如 col、t.col、s.t.col
+func isColumnRef(item []token) bool {
+	for k, t := range item {
+		if k%2 == 0 && !t.isTableName() {
+			return false
+		}
+		if k%2 == 1 && !t.isPunct(".") {
+			return false
+		}
+	}
+	return len(item)%2 == 1
+}
+
+// identRefs 收集表达式中引用的标识符
+func identRefs(expr []token) []string {
+	var refs []string
+	for k, t := range expr {
+		if !t.isTableName() {
+			continue
+		}
+		if k+1 < len(expr) && expr[k+1].isPunct(".") {
+			// t.* 为整行引用，t.col 中的 t 为限定名
+			if k+2 < len(expr) && expr[k+2].isPunct("*") {
+				refs = append(refs, strings.ToLower(identName(t)))
+			}
+			continue
+		}
+		refs = append(refs, strings.ToLower(identName(t)))
+	}
+	return refs
+}
@@ -0,0 +1,105 @@
+package tabular
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 推断的列类型，对应SQLite的声明类型
+const (
+	TypeInteger  = "INTEGER"
+	TypeReal     = "REAL"
+	TypeDate     = "DATE"
+	TypeDatetime = "DATETIME"
+	TypeText     = "TEXT"
+)
+
+// Column 列定义
+type Column struct {
+	Name   string // 规范化后的列名
+	Header string // 原始表头
+	Type   string // 推断的类型
+}
+
+var dateLayouts = []string{"2006-01-02", "2006/01/02", "2006/1/2", "2006-1-2", "2006.01.02", "2006年1月2日"}
+
+var datetimeLayouts = []string{
+	"2006-01-02 15:04:05", "2006-01-02 15:04", "2006/01/02 15:04:05", "2006/01/02 15:04",
+	"2006/1/2 15:04:05", "2006/1/2 15:04", "2006-01-02T15:04:05", time.RFC3339,
+}
+
+// InferType 根据全部非空值推断列类型，无法统一时为TEXT
+func InferType(values []string) string {
+	candidates := []string{TypeInteger, TypeReal, TypeDate, TypeDatetime}
+	seen := false
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		seen = true
+		kept := candidates[:0]
+		for _, t := range candidates {
+			if _, ok := convert(v, t); ok {
+				kept = append(kept, t)
+			}
+		}
+		candidates = kept
+		if len(candidates) == 0 {
+			return TypeText
+		}
+	}
+	if !seen {
+		return TypeText
+	}
+	return candidates[0]
+}
+
+// Convert 将单元格文本转换为列类型对应的值，空值为 nil，无法转换时保留原文本
+func (c *Column) Convert(value string) interface{} {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return nil
+	}
+	if c.Type == TypeText {
+		return value
+	}
+	if v, ok := convert(trimmed, c.Type); ok {
+		return v
+	}
+	return value
+}
+
+func convert(v, typ string) (interface{}, bool) {
+	switch typ {
+	case TypeInteger:
+		// 前导0（如编号、手机号）及超长数字（如身份证号、银行卡号）按文本处理
+		digits := strings.TrimPrefix(strings.TrimPrefix(v, "-"), "+")
+		if len(digits) > 15 || (len(digits) > 1 && digits[0] == '0') {
+			return nil, false
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	case TypeReal:
+		digits := strings.TrimLeft(v, "+-")
+		if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+			return nil, false
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	case TypeDate:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.Format("2006-01-02"), true
+			}
+		}
+	case TypeDatetime:
+		for _, layout := range datetimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.Format("2006-01-02 15:04:05"), true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,256 @@
+package tabular
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("仅支持CSV及XLSX文件")
+	ErrEmptySheet        = errors.New("表格没有数据")
+	ErrTooManyRows       = errors.New("表格行数超过限制")
+)
+
+// Sheet 表格数据，第一行为表头
+type Sheet struct {
+	Name    string          // 规范化后的表名，可直接用作SQL标识符
+	Title   string          // 原始名称（工作表名或文件名）
+	Columns []*Column       // 列定义
+	Rows    [][]interface{} // 按推断的类型转换后的值，空单元格为 nil
+}
+
+// Read 读取CSV或XLSX文件，XLSX的每个非空工作表为一个Sheet，maxRows 为每个Sheet允许的最大数据行数，0为不限制
+func Read(filename string, r io.Reader, maxRows int) ([]*Sheet, error) {
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		records, err := readCSV(r)
+		if err != nil {
+			return nil, err
+		}
+		sheet, err := newSheet(base, records, maxRows)
+		if err != nil {
+			return nil, err
+		}
+		return []*Sheet{sheet}, nil
+	case ".xlsx":
+		return readXLSX(r, maxRows)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// ReadRecords 读取CSV或XLSX（第一个非空工作表）的原始单元格，不做类型推断
+func ReadRecords(filename string, r io.Reader) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return readCSV(r)
+	case ".xlsx":
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		for _, name := range f.GetSheetList() {
+			records, err := f.GetRows(name)
+			if err != nil {
+				return nil, err
+			}
+			if len(records) > 0 {
+				return records, nil
+			}
+		}
+		return nil, ErrEmptySheet
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// readCSV 读取CSV，支持UTF-8（含BOM）及Excel导出的GBK编码
+func readCSV(r io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		if decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data); err == nil {
+			data = decoded
+		}
+	}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	return reader.ReadAll()
+}
+
+func readXLSX(r io.Reader, maxRows int) ([]*Sheet, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sheets []*Sheet
+	names := make(map[string]int)
+	for _, name := range f.GetSheetList() {
+		records, err := f.GetRows(name)
+		if err != nil {
+			return nil, err
+		}
+		sheet, err := newSheet(name, records, maxRows)
+		if errors.Is(err, ErrEmptySheet) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		// 规范化后可能重名
+		if n := names[sheet.Name]; n > 0 {
+			names[sheet.Name]++
+			sheet.Name = numbered(sheet.Name, n+1)
+		} else {
+			names[sheet.Name] = 1
+		}
+		sheets = append(sheets, sheet)
+	}
+	if len(sheets) == 0 {
+		return nil, ErrEmptySheet
+	}
+	return sheets, nil
+}
+
+func newSheet(title string, records [][]string, maxRows int) (*Sheet, error) {
+	// 去掉末尾的空行
+	for len(records) > 0 && isBlank(records[len(records)-1]) {
+		records = records[:len(records)-1]
+	}
+	if len(records) < 1 || isBlank(records[0]) {
+		return nil, ErrEmptySheet
+	}
+	if maxRows > 0 && len(records)-1 > maxRows {
+		return nil, ErrTooManyRows
+	}
+
+	header, data := records[0], records[1:]
+	width := len(header)
+	for _, row := range data {
+		width = max(width, len(row))
+	}
+
+	sheet := &Sheet{
+		Name:  Identifier(title, "sheet"),
+		Title: title,
+	}
+	used := make(map[string]int)
+	for i := 0; i < width; i++ {
+		h := ""
+		if i < len(header) {
+			h = strings.TrimSpace(header[i])
+		}
+		name := Identifier(h, fmt.Sprintf("col_%d", i+1))
+		if n := used[name]; n > 0 {
+			used[name]++
+			name = numbered(name, n+1)
+		} else {
+			used[name] = 1
+		}
+		values := make([]string, len(data))
+		for j, row := range data {
+			if i < len(row) {
+				values[j] = row[i]
+			}
+		}
+		sheet.Columns = append(sheet.Columns, &Column{
+			Name:   name,
+			Header: h,
+			Type:   InferType(values),
+		})
+	}
+
+	sheet.Rows = make([][]interface{}, 0, len(data))
+	for _, row := range data {
+		if isBlank(row) {
+			continue
+		}
+		values := make([]interface{}, width)
+		for i, c := range sheet.Columns {
+			if i < len(row) {
+				values[i] = c.Convert(row[i])
+			}
+		}
+		sheet.Rows = append(sheet.Rows, values)
+	}
+	return sheet, nil
+}
+
+func isBlank(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// 规范化后需要避开的SQL关键字
+var reservedWords = map[string]bool{
+	"select": true, "from": true, "where": true, "order": true, "group": true, "by": true, "limit": true,
+	"offset": true, "table": true, "index": true, "key": true, "values": true, "join": true, "on": true,
+	"and": true, "or": true, "not": true, "null": true, "case": true, "when": true, "then": true,
+	"else": true, "end": true, "as": true, "in": true, "is": true, "like": true, "asc": true, "desc": true,
+	"union": true, "having": true, "default": true, "check": true, "primary": true, "references": true,
+	"distinct": true, "all": true, "between": true, "exists": true, "with": true, "to": true, "into": true,
+}
+
+// maxIdentifierRunes 标识符的最大长度，与表信息、列信息中名称的长度一致
+const maxIdentifierRunes = 64
+
+// Identifier 将表头或名称规范化为无需引号的SQL标识符：保留字母（含中文）、数字和下划线，
+// 其他字符替换为下划线，以数字开头或为关键字时加前缀/后缀，最长64个字符，结果为空时使用 fallback
+func Identifier(name, fallback string) string {
+	var b strings.Builder
+	lastUnderscore := false
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+			lastUnderscore = false
+		case !lastUnderscore && b.Len() > 0:
+			b.WriteByte('_')
+			lastUnderscore = true
+		}
+	}
+	id := strings.TrimRight(b.String(), "_")
+	if id == "" {
+		return fallback
+	}
+	if r, _ := utf8.DecodeRuneInString(id); unicode.IsDigit(r) {
+		id = "c_" + id
+	}
+	if reservedWords[id] {
+		id += "_"
+	}
+	return truncateIdentifier(id, maxIdentifierRunes)
+}
+
+// numbered 重名时加上序号，超长时截断原名以保证不超过最大长度
+func numbered(name string, n int) string {
+	suffix := fmt.Sprintf("_%d", n)
+	return truncateIdentifier(name, maxIdentifierRunes-len(suffix)) + suffix
+}
+
+func truncateIdentifier(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return strings.TrimRight(string(r[:n]), "_")
+	}
+	return s
+}
@@ -0,0 +1,171 @@
+package tabular
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Excel数字精度为15位，超出的整数（如雪花ID）按文本写入
+const maxExactInteger = 1e15
+
+// Writer 按行写出表格，第一行为表头
+type Writer interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []interface{}) error
+	// Close 写出剩余内容，XLSX在此时写入 w
+	Close() error
+}
+
+// NewWriter 创建CSV或XLSX写入器，format 为 csv 或 xlsx
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case "csv":
+		// 带BOM，Excel打开时按UTF-8识别
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case "xlsx":
+		f := excelize.NewFile()
+		x := &xlsxWriter{f: f, w: w}
+		dateFmt, dateTimeFmt := "yyyy-mm-dd", "yyyy-mm-dd hh:mm:ss"
+		var err error
+		if x.dateStyle, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateFmt}); err == nil {
+			x.dateTimeStyle, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateTimeFmt})
+		}
+		if err == nil {
+			x.sw, err = f.NewStreamWriter("Sheet1")
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return x, nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) WriteHeader(columns []string) error {
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = formatCell(v)
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type xlsxWriter struct {
+	f             *excelize.File
+	sw            *excelize.StreamWriter
+	w             io.Writer
+	row           int
+	dateStyle     int
+	dateTimeStyle int
+}
+
+func (x *xlsxWriter) WriteHeader(columns []string) error {
+	values := make([]interface{}, len(columns))
+	for i, c := range columns {
+		values[i] = c
+	}
+	return x.setRow(values)
+}
+
+func (x *xlsxWriter) WriteRow(values []interface{}) error {
+	cells := make([]interface{}, len(values))
+	for i, v := range values {
+		if t, ok := v.(time.Time); ok {
+			style := x.dateTimeStyle
+			if isDate(t) {
+				style = x.dateStyle
+			}
+			cells[i] = excelize.Cell{StyleID: style, Value: t}
+			continue
+		}
+		cells[i] = xlsxCell(v)
+	}
+	return x.setRow(cells)
+}
+
+func (x *xlsxWriter) setRow(values []interface{}) error {
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	return x.sw.SetRow(cell, values)
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.f.Close()
+	if err := x.sw.Flush(); err != nil {
+		return err
+	}
+	_, err := x.f.WriteTo(x.w)
+	return err
+}
+
+// xlsxCell 数字及布尔值保留类型，其余按文本写入
+func xlsxCell(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case int64:
+		if x >= maxExactInteger || x <= -maxExactInteger {
+			return strconv.FormatInt(x, 10)
+		}
+		return x
+	case uint64:
+		if x >= maxExactInteger {
+			return strconv.FormatUint(x, 10)
+		}
+		return x
+	case int, int32, int16, int8, uint, uint32, uint16, uint8, float64, float32, bool:
+		return x
+	}
+	return formatCell(v)
+}
+
+// formatCell 单元格的文本形式，NULL为空
+func formatCell(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case []byte:
+		return string(x)
+	case time.Time:
+		if isDate(x) {
+			return x.Format(time.DateOnly)
+		}
+		return x.Format(time.DateTime)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	}
+	return fmt.Sprint(v)
+}
+
+// isDate 时间部分为0的按日期处理
+func isDate(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}